@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/go-logr/logr"
+
+	"github.com/observeinc/aws-sam-apps/pkg/backfill"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/logging"
+)
+
+var (
+	errInvalidSource = errors.New("exactly one of -manifest or -bucket must be provided")
+	errMissingQueue  = errors.New("missing -queue-url")
+)
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func realMain(ctx context.Context) error {
+	var (
+		verbosity         = flag.Int("verbosity", 1, "Log verbosity")
+		manifest          = flag.String("manifest", "", "S3 URI for an S3 Inventory manifest.json, for CSV or Parquet reports")
+		bucket            = flag.String("bucket", "", "Bucket to list objects from, if no manifest is provided")
+		prefix            = flag.String("prefix", "", "Prefix to list objects under")
+		queueURL          = flag.String("queue-url", "", "Forwarder SQS queue URL")
+		sourceBucketNames = flag.String("source-bucket-names", "", "Comma separated list of bucket name patterns to forward")
+		sourceObjectKeys  = flag.String("source-object-keys", "", "Comma separated list of object key patterns to forward")
+		maxFileSize       = flag.Int64("max-file-size", 0, "Maximum object size in bytes. A zero value indicates no limit.")
+		batchSize         = flag.Int("batch-size", 0, "Number of objects per SQS message")
+		rate              = flag.Float64("rate", 0, "Maximum number of SQS messages sent per second. A zero value indicates no limit.")
+		dryRun            = flag.Bool("dry-run", false, "Print objects which would be forwarded, without sending any messages")
+	)
+	flag.Parse()
+
+	logger := logging.New(&logging.Config{
+		Verbosity: *verbosity,
+	})
+	ctx = logr.NewContext(ctx, logger)
+
+	if (*manifest == "") == (*bucket == "") {
+		return errInvalidSource
+	}
+
+	if *queueURL == "" && !*dryRun {
+		return errMissingQueue
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	s3Client := s3.NewFromConfig(awsCfg)
+
+	var source backfill.Source = &backfill.ListSource{
+		Client: s3Client,
+		Bucket: *bucket,
+		Prefix: *prefix,
+	}
+
+	if *manifest != "" {
+		manifestURI, err := url.Parse(*manifest)
+		if err != nil || manifestURI.Scheme != "s3" {
+			return fmt.Errorf("invalid manifest URI %q", *manifest)
+		}
+		source = &backfill.InventorySource{
+			Client:      s3Client,
+			ManifestURI: manifestURI,
+		}
+	}
+
+	var queue forwarder.Queue
+	if !*dryRun {
+		queue, err = forwarder.NewQueue(sqs.NewFromConfig(awsCfg), *queueURL)
+		if err != nil {
+			return fmt.Errorf("failed to load queue: %w", err)
+		}
+	}
+
+	b, err := backfill.New(&backfill.Config{
+		SourceBucketNames: splitList(*sourceBucketNames),
+		SourceObjectKeys:  splitList(*sourceObjectKeys),
+		MaxFileSize:       *maxFileSize,
+		BatchSize:         *batchSize,
+		Rate:              *rate,
+		Queue:             queue,
+		DryRun:            *dryRun,
+		Output:            os.Stdout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure backfill: %w", err)
+	}
+
+	summary, err := b.Run(ctx, source)
+	if summary != nil {
+		fmt.Fprintf(os.Stderr, "objects: %d, total size: %d bytes, skipped: %d, messages: %d\n",
+			summary.Objects, summary.Bytes, summary.Skipped, summary.Messages)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to backfill: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	if err := realMain(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/go-logr/logr"
+	"github.com/sethvargo/go-envconfig"
+
+	"github.com/observeinc/aws-sam-apps/pkg/backfill"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+	forwarderlambda "github.com/observeinc/aws-sam-apps/pkg/lambda/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/logging"
+)
+
+const usage = `usage: overrides <command> [flags]
+
+Rules are read from the same environment variables as the forwarder:
+CONTENT_TYPE_OVERRIDES, PRESET_OVERRIDES, DESTINATION_OVERRIDES,
+KEY_OVERRIDES, RULE_SET_URIS, DESTINATION_URI and DESTINATION_URIS.
+
+commands:
+  explain  report which rules apply to a set of S3 URIs
+  lint     report unreachable, overlapping and ineffective rules
+`
+
+var (
+	errUsage         = errors.New(usage)
+	errInvalidSource = errors.New("only one of -file, -manifest or URI arguments may be provided")
+	errNoSource      = errors.New("no URIs provided")
+	errLint          = errors.New("lint found issues")
+)
+
+// ruleConfig mirrors the forwarder configuration relevant to override rules.
+type ruleConfig struct {
+	DestinationURI string                         `env:"DESTINATION_URI"`
+	Destinations   []*forwarder.DestinationConfig `env:"DESTINATION_URIS"`
+	forwarderlambda.OverrideConfig
+}
+
+func loadSets(ctx context.Context, logger logr.Logger) (*ruleConfig, override.Sets, error) {
+	var cfg ruleConfig
+	if err := envconfig.Process(ctx, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to load environment variables: %w", err)
+	}
+
+	var remoteSets []*override.RemoteSet
+	if len(cfg.RuleSetURIs) > 0 {
+		awsCfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		}
+		remoteSets, err = cfg.RemoteSets(ctx, logger, cfg.Destinations, s3.NewFromConfig(awsCfg), ssm.NewFromConfig(awsCfg))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load rule sets: %w", err)
+		}
+	}
+
+	sets, err := cfg.OverrideSets(logger, cfg.Destinations, remoteSets...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cfg, sets, nil
+}
+
+// walkLines calls fn for each non-empty line in r.
+func walkLines(r io.Reader, fn func(*backfill.Object) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		object, err := parseURI(line)
+		if err != nil {
+			return err
+		}
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read URIs: %w", err)
+	}
+	return nil
+}
+
+func parseURI(s string) (*backfill.Object, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 URI %q", s)
+	}
+	return &backfill.Object{Bucket: u.Host, Key: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+func explain(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	var (
+		verbosity      = fs.Int("verbosity", 0, "Log verbosity")
+		file           = fs.String("file", "", "File containing one S3 URI per line, or - for stdin")
+		manifest       = fs.String("manifest", "", "S3 URI for an S3 Inventory manifest.json")
+		destinationURI = fs.String("destination-uri", "", "Destination URI used to compute keys. Defaults to DESTINATION_URI")
+		jsonOutput     = fs.Bool("json", false, "Print one JSON object per URI")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	logger := logging.New(&logging.Config{Verbosity: *verbosity})
+	ctx = logr.NewContext(ctx, logger)
+
+	cfg, sets, err := loadSets(ctx, logger)
+	if err != nil {
+		return err
+	}
+
+	if *destinationURI == "" {
+		*destinationURI = cfg.DestinationURI
+	}
+	destination := &url.URL{Scheme: "s3"}
+	if *destinationURI != "" {
+		if destination, err = url.Parse(*destinationURI); err != nil {
+			return fmt.Errorf("invalid destination URI: %w", err)
+		}
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	s3Client := s3.NewFromConfig(awsCfg)
+
+	var sources int
+	for _, provided := range []bool{*file != "", *manifest != "", fs.NArg() > 0} {
+		if provided {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errInvalidSource
+	}
+
+	var walk func(context.Context, func(*backfill.Object) error) error
+	switch {
+	case *file == "-":
+		walk = func(_ context.Context, fn func(*backfill.Object) error) error {
+			return walkLines(os.Stdin, fn)
+		}
+	case *file != "":
+		walk = func(_ context.Context, fn func(*backfill.Object) error) error {
+			f, err := os.Open(*file)
+			if err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			}
+			defer f.Close()
+			return walkLines(f, fn)
+		}
+	case *manifest != "":
+		manifestURI, err := url.Parse(*manifest)
+		if err != nil || manifestURI.Scheme != "s3" {
+			return fmt.Errorf("invalid manifest URI %q", *manifest)
+		}
+		walk = (&backfill.InventorySource{Client: s3Client, ManifestURI: manifestURI}).Walk
+	case fs.NArg() > 0:
+		walk = func(_ context.Context, fn func(*backfill.Object) error) error {
+			return walkLines(strings.NewReader(strings.Join(fs.Args(), "\n")), fn)
+		}
+	default:
+		return errNoSource
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	return walk(ctx, func(object *backfill.Object) error {
+		sourceURL, err := url.Parse(object.URI())
+		if err != nil {
+			return fmt.Errorf("invalid object URI: %w", err)
+		}
+
+		// object attributes are only retrieved if a rule requires them
+		attributes := &override.Object{
+			HeadObject: func(ctx context.Context) (*s3.HeadObjectOutput, error) {
+				return s3Client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(object.Bucket), Key: aws.String(object.Key)})
+			},
+			GetObjectTagging: func(ctx context.Context) (*s3.GetObjectTaggingOutput, error) {
+				return s3Client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(object.Bucket), Key: aws.String(object.Key)})
+			},
+		}
+		if object.Size > 0 {
+			attributes.Size = aws.Int64(object.Size)
+		}
+
+		input := forwarder.GetCopyObjectInput(sourceURL, destination)
+		explanation := override.Explain(override.NewObjectContext(ctx, attributes), sets, input)
+
+		if *jsonOutput {
+			return enc.Encode(explanation)
+		}
+		printExplanation(os.Stdout, object.URI(), explanation)
+		return nil
+	})
+}
+
+func printExplanation(w io.Writer, uri string, e *override.Explanation) {
+	fmt.Fprintln(w, uri)
+	for _, m := range e.Matches {
+		var notes []string
+		if !m.Modified {
+			notes = append(notes, "key or destination only")
+		}
+		if m.Continue && m.Modified {
+			notes = append(notes, "continue")
+		}
+		line := fmt.Sprintf("  set %s, rule %s", m.Set, m.RuleID)
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	if len(e.Matches) == 0 {
+		fmt.Fprintln(w, "  no matching rules")
+	}
+	if e.Ignored {
+		fmt.Fprintln(w, "  ignored")
+		return
+	}
+	fmt.Fprintf(w, "  content-type: %q\n", e.ContentType)
+	fmt.Fprintf(w, "  content-encoding: %q\n", e.ContentEncoding)
+	if e.Destination != "" {
+		fmt.Fprintf(w, "  destination: %s\n", e.Destination)
+	}
+	fmt.Fprintf(w, "  key: %s\n", e.Key)
+}
+
+func lint(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "Print one JSON object per issue")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	_, sets, err := loadSets(ctx, logr.Discard())
+	if err != nil {
+		return err
+	}
+
+	issues := override.Lint(sets)
+	enc := json.NewEncoder(os.Stdout)
+	for _, issue := range issues {
+		if *jsonOutput {
+			if err := enc.Encode(issue); err != nil {
+				return fmt.Errorf("failed to encode issue: %w", err)
+			}
+			continue
+		}
+		fmt.Fprintln(os.Stdout, issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%w: %d", errLint, len(issues))
+	}
+	return nil
+}
+
+func realMain(ctx context.Context) error {
+	if len(os.Args) < 2 {
+		return errUsage
+	}
+	switch os.Args[1] {
+	case "explain":
+		return explain(ctx, os.Args[2:])
+	case "lint":
+		return lint(ctx, os.Args[2:])
+	default:
+		return errUsage
+	}
+}
+
+func main() {
+	if err := realMain(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
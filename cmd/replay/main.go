@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/go-logr/logr"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/logging"
+	"github.com/observeinc/aws-sam-apps/pkg/replay"
+)
+
+var (
+	errInvalidSource   = errors.New("exactly one of -archive or -dlq-url must be provided")
+	errMissingQueue    = errors.New("missing -queue-url")
+	errDLQErrorClasses = errors.New("dead letter queue messages have no error class")
+)
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("failed to parse time: %w", err)
+	}
+	return t, nil
+}
+
+func realMain(ctx context.Context) error {
+	var (
+		verbosity    = flag.Int("verbosity", 1, "Log verbosity")
+		archive      = flag.String("archive", "", "S3 URI of archived failures, e.g. s3://bucket/prefix/errors/")
+		dlqURL       = flag.String("dlq-url", "", "Forwarder dead letter queue URL to drain")
+		queueURL     = flag.String("queue-url", "", "Forwarder SQS queue URL")
+		errorClasses = flag.String("error-class", "", "Comma separated list of error classes to replay")
+		since        = flag.String("since", "", "Only replay failures at or after this time (RFC3339)")
+		until        = flag.String("until", "", "Only replay failures before this time (RFC3339)")
+		batchSize    = flag.Int("batch-size", 0, "Number of records per SQS message")
+		rate         = flag.Float64("rate", 0, "Maximum number of SQS messages sent per second. A zero value indicates no limit.")
+		dryRun       = flag.Bool("dry-run", false, "Print records which would be replayed, without sending or deleting any messages")
+	)
+	flag.Parse()
+
+	logger := logging.New(&logging.Config{
+		Verbosity: *verbosity,
+	})
+	ctx = logr.NewContext(ctx, logger)
+
+	if (*archive == "") == (*dlqURL == "") {
+		return errInvalidSource
+	}
+
+	if *queueURL == "" && !*dryRun {
+		return errMissingQueue
+	}
+
+	if *dlqURL != "" && *errorClasses != "" {
+		return errDLQErrorClasses
+	}
+
+	sinceTime, err := parseTime(*since)
+	if err != nil {
+		return err
+	}
+
+	untilTime, err := parseTime(*until)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	sqsClient := sqs.NewFromConfig(awsCfg)
+
+	var source replay.Source = &replay.DeadLetterSource{
+		Client: sqsClient,
+		URL:    *dlqURL,
+	}
+
+	if *archive != "" {
+		archiveURI, err := url.Parse(*archive)
+		if err != nil || archiveURI.Scheme != "s3" {
+			return fmt.Errorf("invalid archive URI %q", *archive)
+		}
+		source = &replay.ArchiveSource{
+			Client: s3.NewFromConfig(awsCfg),
+			Bucket: archiveURI.Host,
+			Prefix: strings.TrimLeft(archiveURI.Path, "/"),
+		}
+	}
+
+	var queue forwarder.Queue
+	if !*dryRun {
+		queue, err = forwarder.NewQueue(sqsClient, *queueURL)
+		if err != nil {
+			return fmt.Errorf("failed to load queue: %w", err)
+		}
+	}
+
+	r, err := replay.New(&replay.Config{
+		Filter: replay.Filter{
+			ErrorClasses: splitList(*errorClasses),
+			Since:        sinceTime,
+			Until:        untilTime,
+		},
+		BatchSize: *batchSize,
+		Rate:      *rate,
+		Queue:     queue,
+		DryRun:    *dryRun,
+		Output:    os.Stdout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure replay: %w", err)
+	}
+
+	summary, err := r.Run(ctx, source)
+	if summary != nil {
+		fmt.Fprintf(os.Stderr, "records: %d, skipped: %d, messages: %d\n",
+			summary.Records, summary.Skipped, summary.Messages)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to replay: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	if err := realMain(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,202 @@
+// Package backfill enqueues existing objects for processing by the forwarder.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+)
+
+// defaultBatchSize keeps messages well below the SQS message size limit.
+const defaultBatchSize = 100
+
+var (
+	ErrMissingQueue     = errors.New("missing queue")
+	ErrInvalidBatchSize = errors.New("batch size must be positive")
+	ErrInvalidRate      = errors.New("rate must not be negative")
+	ErrInvalidFilter    = errors.New("invalid filter")
+)
+
+// Object is a candidate for backfilling.
+type Object struct {
+	Bucket    string
+	Key       string
+	Size      int64
+	ETag      string
+	VersionID string
+}
+
+// URI returns the S3 URI for the object.
+func (o *Object) URI() string {
+	u := &url.URL{Scheme: "s3", Host: o.Bucket, Path: "/" + o.Key}
+	return u.String()
+}
+
+// Source enumerates objects.
+type Source interface {
+	Walk(ctx context.Context, fn func(*Object) error) error
+}
+
+type Config struct {
+	SourceBucketNames []string
+	SourceObjectKeys  []string
+	MaxFileSize       int64
+	BatchSize         int
+	// Rate limits the number of messages sent per second.
+	// A zero value indicates no limit.
+	Rate float64
+
+	Queue  forwarder.Queue
+	DryRun bool
+	// Output receives the list of objects in dry-run mode.
+	Output io.Writer
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Queue == nil && !c.DryRun {
+		errs = append(errs, ErrMissingQueue)
+	}
+
+	if c.BatchSize < 0 {
+		errs = append(errs, ErrInvalidBatchSize)
+	}
+
+	if c.Rate < 0 {
+		errs = append(errs, ErrInvalidRate)
+	}
+
+	if _, err := forwarder.NewObjectFilter(c.SourceBucketNames, c.SourceObjectKeys); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalidFilter, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Summary of a backfill.
+type Summary struct {
+	Objects  int64
+	Bytes    int64
+	Skipped  int64
+	Messages int64
+}
+
+type Backfill struct {
+	ObjectPolicy interface {
+		Allow(string) bool
+	}
+	MaxFileSize int64
+	BatchSize   int
+	Limiter     *rate.Limiter
+	Queue       forwarder.Queue
+	DryRun      bool
+	Output      io.Writer
+}
+
+// Run enqueues all allowed objects from source.
+func (b *Backfill) Run(ctx context.Context, source Source) (*Summary, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	var (
+		summary Summary
+		batch   []forwarder.CopyRecord
+	)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := b.Limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to wait for rate limiter: %w", err)
+		}
+		if err := b.Queue.Put(ctx, &forwarder.CopyEvent{Copy: batch}); err != nil {
+			return fmt.Errorf("failed to enqueue objects: %w", err)
+		}
+		logger.V(3).Info("enqueued objects", "count", len(batch))
+		summary.Messages++
+		batch = nil
+		return nil
+	}
+
+	err := source.Walk(ctx, func(o *Object) error {
+		if !b.ObjectPolicy.Allow(o.Bucket + "/" + o.Key) {
+			summary.Skipped++
+			return nil
+		}
+
+		if b.MaxFileSize > 0 && o.Size > b.MaxFileSize {
+			logger.V(1).Info("object size exceeds the maximum file size limit", "uri", o.URI(), "size", o.Size)
+			summary.Skipped++
+			return nil
+		}
+
+		summary.Objects++
+		summary.Bytes += o.Size
+
+		if b.DryRun {
+			if _, err := fmt.Fprintf(b.Output, "%s\t%d\n", o.URI(), o.Size); err != nil {
+				return fmt.Errorf("failed to write object: %w", err)
+			}
+			return nil
+		}
+
+		size := o.Size
+		batch = append(batch, forwarder.CopyRecord{
+			URI:       o.URI(),
+			Size:      &size,
+			ETag:      o.ETag,
+			VersionID: o.VersionID,
+		})
+		if len(batch) >= b.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return &summary, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	if err := flush(); err != nil {
+		return &summary, err
+	}
+	return &summary, nil
+}
+
+func New(cfg *Config) (*Backfill, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	objectFilter, _ := forwarder.NewObjectFilter(cfg.SourceBucketNames, cfg.SourceObjectKeys)
+
+	batchSize := cfg.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	limit := rate.Inf
+	if cfg.Rate > 0 {
+		limit = rate.Limit(cfg.Rate)
+	}
+
+	output := cfg.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	return &Backfill{
+		ObjectPolicy: objectFilter,
+		MaxFileSize:  cfg.MaxFileSize,
+		BatchSize:    batchSize,
+		Limiter:      rate.NewLimiter(limit, 1),
+		Queue:        cfg.Queue,
+		DryRun:       cfg.DryRun,
+		Output:       output,
+	}, nil
+}
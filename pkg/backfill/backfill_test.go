@@ -0,0 +1,238 @@
+package backfill_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/observeinc/aws-sam-apps/pkg/backfill"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+var errSentinel = errors.New("sentinel error")
+
+type fakeQueue struct {
+	Items []any
+	Err   error
+}
+
+func (q *fakeQueue) Put(_ context.Context, items ...any) error {
+	if q.Err != nil {
+		return q.Err
+	}
+	q.Items = append(q.Items, items...)
+	return nil
+}
+
+type fakeSource []*backfill.Object
+
+func (s fakeSource) Walk(_ context.Context, fn func(*backfill.Object) error) error {
+	for _, o := range s {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var objects = fakeSource{
+	{Bucket: "bucket", Key: "AWSLogs/a.json", Size: 10, ETag: "a"},
+	{Bucket: "bucket", Key: "AWSLogs/b.json", Size: 20, VersionID: "1"},
+	{Bucket: "bucket", Key: "other/c.json", Size: 30},
+	{Bucket: "bucket", Key: "AWSLogs/large.json", Size: 1000},
+	{Bucket: "bucket", Key: "AWSLogs/d e.json", Size: 40},
+}
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		backfill.Config
+		ExpectError error
+	}{
+		{
+			ExpectError: backfill.ErrMissingQueue,
+		},
+		{
+			Config: backfill.Config{
+				DryRun: true,
+			},
+		},
+		{
+			Config: backfill.Config{
+				Queue:     &fakeQueue{},
+				BatchSize: -1,
+			},
+			ExpectError: backfill.ErrInvalidBatchSize,
+		},
+		{
+			Config: backfill.Config{
+				Queue: &fakeQueue{},
+				Rate:  -1,
+			},
+			ExpectError: backfill.ErrInvalidRate,
+		},
+		{
+			Config: backfill.Config{
+				Queue:             &fakeQueue{},
+				SourceBucketNames: []string{"bucket["},
+			},
+			ExpectError: backfill.ErrInvalidFilter,
+		},
+	}
+
+	for _, tc := range testcases {
+		err := tc.Validate()
+		if diff := cmp.Diff(err, tc.ExpectError, cmpopts.EquateErrors()); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	t.Parallel()
+
+	queue := &fakeQueue{}
+	b, err := backfill.New(&backfill.Config{
+		SourceObjectKeys: []string{"AWSLogs/*"},
+		MaxFileSize:      100,
+		BatchSize:        2,
+		Rate:             1000,
+		Queue:            queue,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := b.Run(context.Background(), objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectSummary := &backfill.Summary{
+		Objects:  3,
+		Bytes:    70,
+		Skipped:  2,
+		Messages: 2,
+	}
+	if diff := cmp.Diff(summary, expectSummary); diff != "" {
+		t.Error("unexpected summary", diff)
+	}
+
+	expectItems := []any{
+		&forwarder.CopyEvent{
+			Copy: []forwarder.CopyRecord{
+				{URI: "s3://bucket/AWSLogs/a.json", Size: aws.Int64(10), ETag: "a"},
+				{URI: "s3://bucket/AWSLogs/b.json", Size: aws.Int64(20), VersionID: "1"},
+			},
+		},
+		&forwarder.CopyEvent{
+			Copy: []forwarder.CopyRecord{
+				{URI: "s3://bucket/AWSLogs/d%20e.json", Size: aws.Int64(40)},
+			},
+		},
+	}
+	if diff := cmp.Diff(queue.Items, expectItems); diff != "" {
+		t.Error("unexpected messages", diff)
+	}
+}
+
+func TestBackfillDryRun(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	b, err := backfill.New(&backfill.Config{
+		SourceObjectKeys: []string{"AWSLogs/*"},
+		MaxFileSize:      100,
+		DryRun:           true,
+		Output:           &output,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := b.Run(context.Background(), objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Messages != 0 || summary.Bytes != 70 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	expect := "s3://bucket/AWSLogs/a.json\t10\ns3://bucket/AWSLogs/b.json\t20\ns3://bucket/AWSLogs/d%20e.json\t40\n"
+	if diff := cmp.Diff(output.String(), expect); diff != "" {
+		t.Error("unexpected output", diff)
+	}
+}
+
+func TestBackfillQueueError(t *testing.T) {
+	t.Parallel()
+
+	b, err := backfill.New(&backfill.Config{
+		Queue: &fakeQueue{Err: errSentinel},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Run(context.Background(), objects); !errors.Is(err, errSentinel) {
+		t.Fatalf("expected sentinel error, got %v", err)
+	}
+}
+
+func TestListSource(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]*s3.ListObjectsV2Output{
+		"": {
+			Contents: []types.Object{
+				{Key: aws.String("prefix/a.json"), Size: aws.Int64(1), ETag: aws.String(`"abc"`)},
+			},
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("next"),
+		},
+		"next": {
+			Contents: []types.Object{
+				{Key: aws.String("prefix/b.json"), Size: aws.Int64(2)},
+			},
+		},
+	}
+
+	source := &backfill.ListSource{
+		Client: &awstest.S3Client{
+			ListObjectsV2Func: func(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				if aws.ToString(input.Prefix) != "prefix/" {
+					t.Errorf("unexpected prefix %q", aws.ToString(input.Prefix))
+				}
+				return pages[aws.ToString(input.ContinuationToken)], nil
+			},
+		},
+		Bucket: "bucket",
+		Prefix: "prefix/",
+	}
+
+	var got []*backfill.Object
+	if err := source.Walk(context.Background(), func(o *backfill.Object) error {
+		got = append(got, o)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []*backfill.Object{
+		{Bucket: "bucket", Key: "prefix/a.json", Size: 1, ETag: "abc"},
+		{Bucket: "bucket", Key: "prefix/b.json", Size: 2},
+	}
+	if diff := cmp.Diff(got, expect); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,253 @@
+package backfill
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/seekable"
+)
+
+// inventoryMemoryLimitBytes bounds how much of a Parquet file is kept in
+// memory before spilling to disk.
+const inventoryMemoryLimitBytes int64 = 64 * 1024 * 1024
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported inventory format")
+	ErrMissingColumn     = errors.New("missing column")
+)
+
+type GetObjectAPIClient interface {
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Manifest describes an S3 Inventory report.
+type Manifest struct {
+	SourceBucket      string         `json:"sourceBucket"`
+	DestinationBucket string         `json:"destinationBucket"`
+	FileFormat        string         `json:"fileFormat"`
+	FileSchema        string         `json:"fileSchema"`
+	Files             []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// Bucket returns the name of the bucket containing inventory files.
+func (m *Manifest) Bucket() string {
+	// arn:partition:s3:::bucket
+	if _, bucket, ok := strings.Cut(m.DestinationBucket, ":::"); ok {
+		return bucket
+	}
+	return m.DestinationBucket
+}
+
+// InventorySource enumerates objects listed in an S3 Inventory report.
+// Only CSV and Parquet reports are supported. ORC reports are rejected
+// with ErrUnsupportedFormat.
+type InventorySource struct {
+	Client      GetObjectAPIClient
+	ManifestURI *url.URL
+}
+
+var _ Source = &InventorySource{}
+
+func (s *InventorySource) getObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	output, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get s3://%s/%s: %w", bucket, key, err)
+	}
+	return output.Body, nil
+}
+
+// GetManifest retrieves the inventory manifest.
+func (s *InventorySource) GetManifest(ctx context.Context) (*Manifest, error) {
+	body, err := s.getObject(ctx, s.ManifestURI.Host, strings.TrimLeft(s.ManifestURI.Path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+func (s *InventorySource) Walk(ctx context.Context, fn func(*Object) error) error {
+	manifest, err := s.GetManifest(ctx)
+	if err != nil {
+		return err
+	}
+
+	var walkFile func(io.Reader, *Manifest, func(*Object) error) error
+	switch manifest.FileFormat {
+	case "CSV":
+		walkFile = walkCSV
+	case "Parquet":
+		walkFile = walkParquet
+	default:
+		return fmt.Errorf("%w: %q, configure the inventory report as CSV or Parquet, or list objects by prefix", ErrUnsupportedFormat, manifest.FileFormat)
+	}
+
+	for _, file := range manifest.Files {
+		body, err := s.getObject(ctx, manifest.Bucket(), file.Key)
+		if err != nil {
+			return err
+		}
+		err = walkFile(body, manifest, fn)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to process %s: %w", file.Key, err)
+		}
+	}
+	return nil
+}
+
+// walkCSV processes a gzip compressed CSV inventory file.
+// Columns are listed in the manifest file schema, and object keys are URL encoded.
+func walkCSV(r io.Reader, manifest *Manifest, fn func(*Object) error) error {
+	columns := make(map[string]int)
+	for i, name := range strings.Split(manifest.FileSchema, ",") {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range []string{"Bucket", "Key"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+	}
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+	defer gr.Close()
+
+	reader := csv.NewReader(gr)
+	reader.FieldsPerRecord = len(columns)
+
+	get := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record: %w", err)
+		}
+
+		if get(record, "IsDeleteMarker") == "true" {
+			continue
+		}
+
+		key, err := url.QueryUnescape(get(record, "Key"))
+		if err != nil {
+			return fmt.Errorf("failed to decode key: %w", err)
+		}
+
+		obj := &Object{
+			Bucket:    get(record, "Bucket"),
+			Key:       key,
+			ETag:      get(record, "ETag"),
+			VersionID: get(record, "VersionId"),
+		}
+
+		if size := get(record, "Size"); size != "" {
+			if obj.Size, err = strconv.ParseInt(size, 10, 64); err != nil {
+				return fmt.Errorf("failed to parse size: %w", err)
+			}
+		}
+
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+}
+
+// parquetRecord contains the subset of inventory columns we require.
+type parquetRecord struct {
+	Bucket         string `parquet:"bucket"`
+	Key            string `parquet:"key"`
+	VersionID      string `parquet:"version_id,optional"`
+	IsDeleteMarker bool   `parquet:"is_delete_marker,optional"`
+	Size           int64  `parquet:"size,optional"`
+	ETag           string `parquet:"e_tag,optional"`
+}
+
+// walkParquet processes a Parquet inventory file.
+func walkParquet(r io.Reader, _ *Manifest, fn func(*Object) error) error {
+	body, cleanup, err := seekable.FromReader(r, inventoryMemoryLimitBytes)
+	if err != nil {
+		return fmt.Errorf("failed to buffer file: %w", err)
+	}
+	defer func() {
+		_ = cleanup()
+	}()
+
+	size, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to seek: %w", err)
+	}
+
+	readerAt, ok := body.(io.ReaderAt)
+	if !ok {
+		return fmt.Errorf("failed to read file: %T is not a ReaderAt", body)
+	}
+
+	file, err := parquet.OpenFile(readerAt, size)
+	if err != nil {
+		return fmt.Errorf("failed to open parquet file: %w", err)
+	}
+
+	reader := parquet.NewGenericReader[parquetRecord](file)
+	defer reader.Close()
+
+	records := make([]parquetRecord, 100)
+	for {
+		n, err := reader.Read(records)
+		for _, record := range records[:n] {
+			if record.IsDeleteMarker {
+				continue
+			}
+			if err := fn(&Object{
+				Bucket:    record.Bucket,
+				Key:       record.Key,
+				Size:      record.Size,
+				ETag:      record.ETag,
+				VersionID: record.VersionID,
+			}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read records: %w", err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package backfill_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/observeinc/aws-sam-apps/pkg/backfill"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+// inventoryRecord mirrors the column names used in Parquet inventory reports.
+type inventoryRecord struct {
+	Bucket         string `parquet:"bucket"`
+	Key            string `parquet:"key"`
+	VersionID      string `parquet:"version_id,optional"`
+	IsLatest       bool   `parquet:"is_latest,optional"`
+	IsDeleteMarker bool   `parquet:"is_delete_marker,optional"`
+	Size           int64  `parquet:"size,optional"`
+	StorageClass   string `parquet:"storage_class,optional"`
+}
+
+func gzipString(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func parquetRecords(t *testing.T, records []inventoryRecord) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInventorySource(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Files       map[string][]byte
+		Expect      []*backfill.Object
+		ExpectError error
+	}{
+		{
+			Files: map[string][]byte{
+				"manifest.json": []byte(`{
+					"sourceBucket": "source",
+					"destinationBucket": "arn:aws:s3:::inventory",
+					"fileFormat": "CSV",
+					"fileSchema": "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, ETag",
+					"files": [{"key": "data/1.csv.gz", "size": 100}, {"key": "data/2.csv.gz", "size": 100}]
+				}`),
+				"data/1.csv.gz": gzipString(t, "\"source\",\"AWSLogs/a%2Bb+c.json\",\"v1\",\"true\",\"false\",\"10\",\"abc\"\n"+
+					"\"source\",\"AWSLogs/deleted.json\",\"v2\",\"true\",\"true\",\"\",\"\"\n"),
+				"data/2.csv.gz": gzipString(t, "\"source\",\"AWSLogs/b.json\",\"\",\"true\",\"false\",\"20\",\"def\"\n"),
+			},
+			Expect: []*backfill.Object{
+				{Bucket: "source", Key: "AWSLogs/a+b c.json", Size: 10, ETag: "abc", VersionID: "v1"},
+				{Bucket: "source", Key: "AWSLogs/b.json", Size: 20, ETag: "def"},
+			},
+		},
+		{
+			Files: map[string][]byte{
+				"manifest.json": []byte(`{
+					"sourceBucket": "source",
+					"destinationBucket": "arn:aws:s3:::inventory",
+					"fileFormat": "Parquet",
+					"fileSchema": "message s3.inventory { required binary bucket (STRING); required binary key (STRING); optional binary version_id (STRING); optional boolean is_latest; optional boolean is_delete_marker; optional int64 size; optional binary storage_class (STRING);}",
+					"files": [{"key": "data/1.parquet", "size": 100}]
+				}`),
+				"data/1.parquet": parquetRecords(t, []inventoryRecord{
+					{Bucket: "source", Key: "AWSLogs/a+b c.json", VersionID: "v1", IsLatest: true, Size: 10, StorageClass: "STANDARD"},
+					{Bucket: "source", Key: "AWSLogs/deleted.json", IsDeleteMarker: true},
+					{Bucket: "source", Key: "AWSLogs/b.json", IsLatest: true, Size: 20},
+				}),
+			},
+			Expect: []*backfill.Object{
+				{Bucket: "source", Key: "AWSLogs/a+b c.json", Size: 10, VersionID: "v1"},
+				{Bucket: "source", Key: "AWSLogs/b.json", Size: 20},
+			},
+		},
+		{
+			Files: map[string][]byte{
+				"manifest.json": []byte(`{"destinationBucket": "arn:aws:s3:::inventory", "fileFormat": "ORC"}`),
+			},
+			ExpectError: backfill.ErrUnsupportedFormat,
+		},
+		{
+			Files: map[string][]byte{
+				"manifest.json": []byte(`{
+					"destinationBucket": "arn:aws:s3:::inventory",
+					"fileFormat": "CSV",
+					"fileSchema": "Bucket, Size",
+					"files": [{"key": "data/1.csv.gz"}]
+				}`),
+				"data/1.csv.gz": gzipString(t, "\"source\",\"10\"\n"),
+			},
+			ExpectError: backfill.ErrMissingColumn,
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			source := &backfill.InventorySource{
+				Client: &awstest.S3Client{
+					GetObjectFunc: func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+						if bucket := aws.ToString(input.Bucket); bucket != "inventory" {
+							return nil, fmt.Errorf("unexpected bucket %q", bucket)
+						}
+						data, ok := tc.Files[aws.ToString(input.Key)]
+						if !ok {
+							return nil, errSentinel
+						}
+						return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+					},
+				},
+				ManifestURI: &url.URL{Scheme: "s3", Host: "inventory", Path: "/manifest.json"},
+			}
+
+			var got []*backfill.Object
+			err := source.Walk(context.Background(), func(o *backfill.Object) error {
+				got = append(got, o)
+				return nil
+			})
+			if !errors.Is(err, tc.ExpectError) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(got, tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ListSource enumerates objects under a bucket prefix.
+type ListSource struct {
+	Client s3.ListObjectsV2APIClient
+	Bucket string
+	Prefix string
+}
+
+var _ Source = &ListSource{}
+
+func (l *ListSource) Walk(ctx context.Context, fn func(*Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(l.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(l.Bucket),
+		Prefix: aws.String(l.Prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get page: %w", err)
+		}
+		for _, obj := range page.Contents {
+			if err := fn(&Object{
+				Bucket: l.Bucket,
+				Key:    aws.ToString(obj.Key),
+				Size:   aws.ToInt64(obj.Size),
+				ETag:   strings.Trim(aws.ToString(obj.ETag), `"`),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
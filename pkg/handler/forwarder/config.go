@@ -3,8 +3,14 @@ package forwarder
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/dedup"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
 )
 
 var (
@@ -12,32 +18,108 @@ var (
 	ErrInvalidFilter      = errors.New("invalid source filter")
 	ErrMissingS3Client    = errors.New("missing S3 client")
 	ErrPresetNotFound     = errors.New("not found")
+	ErrInvalidName        = errors.New("invalid destination name")
+	ErrDuplicateName      = errors.New("duplicate destination name")
 )
 
 type Config struct {
-	DestinationURI     string // S3 URI to write messages and copy files to
-	MaxFileSize        int64  // maximum file size in bytes for the files to be processed
+	DestinationURI     string        // S3 URI to write messages and copy files to
+	MaxFileSize        int64         // maximum file size in bytes for the files to be processed
+	OversizedMode      OversizedMode // how to handle files exceeding MaxFileSize
 	SourceBucketNames  []string
 	SourceObjectKeys   []string
 	Override           Override
 	S3Client           S3Client
 	GetTime            func() *time.Time
 	MaxConcurrentTasks int // fan out limit
+
+	// MultipartThreshold is the object size in bytes above which objects
+	// are copied to S3 destinations in parts. It defaults to, and must
+	// not exceed, the 5 GiB limit for a single CopyObject request.
+	MultipartThreshold int64
+
+	// Sources reads source objects using credentials and region specific
+	// to each bucket. If unset, destination clients read source objects.
+	Sources *SourceClients
+
+	// Destinations contains additional locations to copy files to. Files are
+	// copied to DestinationURI first, followed by each destination in order.
+	// Named destinations only receive files routed to them by override rules.
+	Destinations []*DestinationConfig
+
+	// Queue is used to resubmit copy records which failed for a subset of
+	// destinations. If unset, the whole message is retried.
+	Queue Queue
+
+	// DedupStore records copied objects, so that repeated notifications
+	// for the same object revision are not copied twice.
+	DedupStore dedup.Store
+	DedupTTL   time.Duration // how long to remember copied objects
+
+	// MaxReceiveCount is the number of times a message is delivered before
+	// SQS moves it to the dead letter queue. Copy records which still fail
+	// on the final delivery are archived under the destination. If zero,
+	// failed copy records are not archived.
+	MaxReceiveCount int
+}
+
+// DestinationConfig describes an additional destination for copied files.
+type DestinationConfig struct {
+	Name     string // name used by override rules to route files
+	URI      string // S3 or HTTPS URI to copy files to
+	S3Client S3Client
+}
+
+// UnmarshalText populates a destination from either a bare URI or a
+// "name=uri" pair.
+func (d *DestinationConfig) UnmarshalText(text []byte) error {
+	s := string(text)
+	if i := strings.Index(s, "="); i > 0 && !strings.Contains(s[:i], "://") {
+		d.Name, d.URI = s[:i], s[i+1:]
+		return nil
+	}
+	d.URI = s
+	return nil
+}
+
+func validateDestinationURI(s string) error {
+	if s == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidDestination, s)
+	}
+	u, err := url.ParseRequestURI(s)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: %w", ErrInvalidDestination, err)
+	case u.Scheme == "s3":
+	case u.Scheme == "https":
+	default:
+		return fmt.Errorf("%w: scheme must be \"s3\" or \"https\"", ErrInvalidDestination)
+	}
+	return nil
 }
 
 func (c *Config) Validate() error {
 	var errs []error
-	if c.DestinationURI == "" {
-		errs = append(errs, fmt.Errorf("%w: %q", ErrInvalidDestination, c.DestinationURI))
-	} else {
-		u, err := url.ParseRequestURI(c.DestinationURI)
-		switch {
-		case err != nil:
-			errs = append(errs, fmt.Errorf("%w: %w", ErrInvalidDestination, err))
-		case u.Scheme == "s3":
-		case u.Scheme == "https":
-		default:
-			errs = append(errs, fmt.Errorf("%w: scheme must be \"s3\" or \"https\"", ErrInvalidDestination))
+	if err := validateDestinationURI(c.DestinationURI); err != nil {
+		errs = append(errs, err)
+	}
+
+	names := make(map[string]struct{}, len(c.Destinations))
+	for i, d := range c.Destinations {
+		if d.Name != "" {
+			if !override.DestinationName.MatchString(d.Name) {
+				errs = append(errs, fmt.Errorf("destination %d: %w: %q", i, ErrInvalidName, d.Name))
+			}
+			if _, ok := names[d.Name]; ok {
+				errs = append(errs, fmt.Errorf("destination %d: %w: %q", i, ErrDuplicateName, d.Name))
+			}
+			names[d.Name] = struct{}{}
+		}
+		if err := validateDestinationURI(d.URI); err != nil {
+			errs = append(errs, fmt.Errorf("destination %d: %w", i, err))
+		}
+		if d.S3Client == nil {
+			errs = append(errs, fmt.Errorf("destination %d: %w", i, ErrMissingS3Client))
 		}
 	}
 
@@ -49,5 +131,21 @@ func (c *Config) Validate() error {
 		errs = append(errs, ErrMissingS3Client)
 	}
 
+	if err := c.OversizedMode.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.MultipartThreshold < 0 || c.MultipartThreshold > maxCopyObjectSize {
+		errs = append(errs, fmt.Errorf("%w: %d", ErrInvalidMultipartThreshold, c.MultipartThreshold))
+	}
+
+	// rules must not route objects to destinations which do not exist,
+	// since such objects would not be copied anywhere
+	if v, ok := c.Override.(interface{ ValidateDestinations([]string) error }); ok {
+		if err := v.ValidateDestinations(slices.Collect(maps.Keys(names))); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	return errors.Join(errs...)
 }
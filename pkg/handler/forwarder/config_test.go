@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
 	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
 )
 
@@ -43,6 +45,81 @@ func TestConfig(t *testing.T) {
 				SourceObjectKeys:  []string{"*/te?t/*"},
 			},
 		},
+		{
+			Config: forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations: []*forwarder.DestinationConfig{
+					{URI: "https://example.com", S3Client: &awstest.S3Client{}},
+				},
+			},
+		},
+		{
+			Config: forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations: []*forwarder.DestinationConfig{
+					{URI: "ftp://example.com", S3Client: &awstest.S3Client{}},
+				},
+			},
+			ExpectError: forwarder.ErrInvalidDestination,
+		},
+		{
+			Config: forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations: []*forwarder.DestinationConfig{
+					{URI: "https://example.com"},
+				},
+			},
+			ExpectError: forwarder.ErrMissingS3Client,
+		},
+		{
+			Config: forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations: []*forwarder.DestinationConfig{
+					{Name: "logs/vpc", URI: "https://example.com", S3Client: &awstest.S3Client{}},
+				},
+			},
+			ExpectError: forwarder.ErrInvalidName,
+		},
+		{
+			Config: forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations: []*forwarder.DestinationConfig{
+					{Name: "archive", URI: "s3://archive", S3Client: &awstest.S3Client{}},
+					{Name: "archive", URI: "https://example.com", S3Client: &awstest.S3Client{}},
+				},
+			},
+			ExpectError: forwarder.ErrDuplicateName,
+		},
+		{
+			Config: forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations: []*forwarder.DestinationConfig{
+					{Name: "archive", URI: "s3://archive", S3Client: &awstest.S3Client{}},
+				},
+				Override: override.Sets{
+					{Rules: []*override.Rule{{Override: override.Action{Destination: aws.String("archive")}}}},
+				},
+			},
+		},
+		{
+			Config: forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations: []*forwarder.DestinationConfig{
+					{Name: "archive", URI: "s3://archive", S3Client: &awstest.S3Client{}},
+				},
+				Override: override.Sets{
+					{Rules: []*override.Rule{{Override: override.Action{Destination: aws.String("archvie")}}}},
+				},
+			},
+			ExpectError: override.ErrUnknownDestination,
+		},
 	}
 
 	for i, tc := range testcases {
@@ -55,3 +132,72 @@ func TestConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDestinationConfigText(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Text        string
+		Expect      forwarder.DestinationConfig
+		ExpectError error
+	}{
+		{
+			Text:   "s3://bucket/prefix/",
+			Expect: forwarder.DestinationConfig{URI: "s3://bucket/prefix/"},
+		},
+		{
+			Text:   "archive=s3://bucket/prefix/",
+			Expect: forwarder.DestinationConfig{Name: "archive", URI: "s3://bucket/prefix/"},
+		},
+		{
+			Text:   "https://example.com/v1/http?token=abc",
+			Expect: forwarder.DestinationConfig{URI: "https://example.com/v1/http?token=abc"},
+		},
+		{
+			Text:   "a=s3://bucket/",
+			Expect: forwarder.DestinationConfig{Name: "a", URI: "s3://bucket/"},
+		},
+		{
+			Text:   "vpc-flow=s3://bucket/",
+			Expect: forwarder.DestinationConfig{Name: "vpc-flow", URI: "s3://bucket/"},
+		},
+		{
+			Text:   "vpc_flow=s3://bucket/",
+			Expect: forwarder.DestinationConfig{Name: "vpc_flow", URI: "s3://bucket/"},
+		},
+		{
+			Text:        "logs/vpc=s3://bucket/",
+			Expect:      forwarder.DestinationConfig{Name: "logs/vpc", URI: "s3://bucket/"},
+			ExpectError: forwarder.ErrInvalidName,
+		},
+		{
+			Text:        "-vpc=s3://bucket/",
+			Expect:      forwarder.DestinationConfig{Name: "-vpc", URI: "s3://bucket/"},
+			ExpectError: forwarder.ErrInvalidName,
+		},
+	}
+
+	for i, tc := range testcases {
+		tc := tc
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+			var got forwarder.DestinationConfig
+			if err := got.UnmarshalText([]byte(tc.Text)); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+
+			got.S3Client = &awstest.S3Client{}
+			cfg := forwarder.Config{
+				DestinationURI: "s3://test",
+				S3Client:       &awstest.S3Client{},
+				Destinations:   []*forwarder.DestinationConfig{&got},
+			}
+			if diff := cmp.Diff(cfg.Validate(), tc.ExpectError, cmpopts.EquateErrors()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
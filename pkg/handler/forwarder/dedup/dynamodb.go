@@ -0,0 +1,136 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// attribute names for DynamoDB items. The expiry attribute should be
+	// configured as the TTL attribute of the table so that DynamoDB
+	// eventually removes stale items.
+	keyAttribute    = "key"
+	expiryAttribute = "expiresAt"
+)
+
+var ErrMissingTableName = errors.New("missing table name")
+
+type DynamoDBClient interface {
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// DynamoDBStore records keys in a DynamoDB table, relying on conditional
+// writes to detect concurrent copies of the same object.
+type DynamoDBStore struct {
+	Client    DynamoDBClient
+	TableName string
+	Now       func() time.Time
+}
+
+var _ Store = &DynamoDBStore{}
+
+func (d *DynamoDBStore) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+func (d *DynamoDBStore) Exists(ctx context.Context, key string) (bool, error) {
+	out, err := d.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.TableName),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]types.AttributeValue{
+			keyAttribute: &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get item: %w", err)
+	}
+
+	v, ok := out.Item[expiryAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return false, nil
+	}
+
+	// DynamoDB deletes expired items lazily, so we must check expiry ourselves.
+	expiresAt, err := strconv.ParseInt(v.Value, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse expiry: %w", err)
+	}
+	return d.now().Unix() < expiresAt, nil
+}
+
+func (d *DynamoDBStore) Put(ctx context.Context, key string, ttl time.Duration) error {
+	now := d.now()
+	_, err := d.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.TableName),
+		Item: map[string]types.AttributeValue{
+			keyAttribute:    &types.AttributeValueMemberS{Value: key},
+			expiryAttribute: &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(ttl).Unix(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #expiresAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#key":       keyAttribute,
+			"#expiresAt": expiryAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(err, &conditionErr):
+		return ErrExists
+	case err != nil:
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoDBStore) Update(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := d.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.TableName),
+		Item: map[string]types.AttributeValue{
+			keyAttribute:    &types.AttributeValueMemberS{Value: key},
+			expiryAttribute: &types.AttributeValueMemberN{Value: strconv.FormatInt(d.now().Add(ttl).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoDBStore) Delete(ctx context.Context, key string) error {
+	_, err := d.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.TableName),
+		Key: map[string]types.AttributeValue{
+			keyAttribute: &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return nil
+}
+
+func NewDynamoDBStore(client DynamoDBClient, tableName string) (*DynamoDBStore, error) {
+	if tableName == "" {
+		return nil, ErrMissingTableName
+	}
+	return &DynamoDBStore{
+		Client:    client,
+		TableName: tableName,
+	}, nil
+}
@@ -0,0 +1,175 @@
+package dedup_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/dedup"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+var errSentinel = errors.New("sentinel error")
+
+func TestDynamoDBStoreExists(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+
+	testcases := []struct {
+		Item      map[string]types.AttributeValue
+		GetErr    error
+		Expect    bool
+		ExpectErr error
+	}{
+		{
+			// missing item
+		},
+		{
+			Item: map[string]types.AttributeValue{
+				"key":       &types.AttributeValueMemberS{Value: "a"},
+				"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix()+60, 10)},
+			},
+			Expect: true,
+		},
+		{
+			// expired, but not yet deleted
+			Item: map[string]types.AttributeValue{
+				"key":       &types.AttributeValueMemberS{Value: "a"},
+				"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			},
+		},
+		{
+			GetErr:    errSentinel,
+			ExpectErr: errSentinel,
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+			store, err := dedup.NewDynamoDBStore(&awstest.DynamoDBClient{
+				GetItemFunc: func(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+					if aws.ToString(input.TableName) != "table" {
+						t.Errorf("unexpected table %q", aws.ToString(input.TableName))
+					}
+					return &dynamodb.GetItemOutput{Item: tc.Item}, tc.GetErr
+				},
+			}, "table")
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.Now = func() time.Time { return now }
+
+			got, err := store.Exists(context.Background(), "a")
+			if !errors.Is(err, tc.ExpectErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.Expect {
+				t.Fatalf("expected %t, got %t", tc.Expect, got)
+			}
+		})
+	}
+}
+
+func TestDynamoDBStorePut(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		PutErr    error
+		ExpectErr error
+	}{
+		{},
+		{
+			PutErr:    &types.ConditionalCheckFailedException{},
+			ExpectErr: dedup.ErrExists,
+		},
+		{
+			PutErr:    errSentinel,
+			ExpectErr: errSentinel,
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+			store, err := dedup.NewDynamoDBStore(&awstest.DynamoDBClient{
+				PutItemFunc: func(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+					expiresAt, ok := input.Item["expiresAt"].(*types.AttributeValueMemberN)
+					if !ok || expiresAt.Value != "1700000060" {
+						t.Errorf("unexpected expiry: %v", input.Item["expiresAt"])
+					}
+					return &dynamodb.PutItemOutput{}, tc.PutErr
+				},
+			}, "table")
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.Now = func() time.Time { return time.Unix(1700000000, 0) }
+
+			if err := store.Put(context.Background(), "a", time.Minute); !errors.Is(err, tc.ExpectErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDynamoDBStoreUpdate(t *testing.T) {
+	t.Parallel()
+
+	store, err := dedup.NewDynamoDBStore(&awstest.DynamoDBClient{
+		PutItemFunc: func(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			if input.ConditionExpression != nil {
+				t.Errorf("unexpected condition %q", aws.ToString(input.ConditionExpression))
+			}
+			expiresAt, ok := input.Item["expiresAt"].(*types.AttributeValueMemberN)
+			if !ok || expiresAt.Value != "1700000060" {
+				t.Errorf("unexpected expiry: %v", input.Item["expiresAt"])
+			}
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}, "table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	if err := store.Update(context.Background(), "a", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDynamoDBStoreDelete(t *testing.T) {
+	t.Parallel()
+
+	store, err := dedup.NewDynamoDBStore(&awstest.DynamoDBClient{
+		DeleteItemFunc: func(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+			key, ok := input.Key["key"].(*types.AttributeValueMemberS)
+			if !ok || key.Value != "a" {
+				t.Errorf("unexpected key: %v", input.Key["key"])
+			}
+			return &dynamodb.DeleteItemOutput{}, errSentinel
+		},
+	}, "table")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete(context.Background(), "a"); !errors.Is(err, errSentinel) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewDynamoDBStore(t *testing.T) {
+	t.Parallel()
+
+	if _, err := dedup.NewDynamoDBStore(&awstest.DynamoDBClient{}, ""); !errors.Is(err, dedup.ErrMissingTableName) {
+		t.Fatalf("expected ErrMissingTableName, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps keys in memory. It is intended for testing.
+type MemoryStore struct {
+	Now func() time.Time
+
+	items map[string]time.Time
+	sync.Mutex
+}
+
+var _ Store = &MemoryStore{}
+
+func (m *MemoryStore) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+func (m *MemoryStore) Exists(_ context.Context, key string) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	expiresAt, ok := m.items[key]
+	return ok && m.now().Before(expiresAt), nil
+}
+
+func (m *MemoryStore) Put(_ context.Context, key string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	now := m.now()
+	if expiresAt, ok := m.items[key]; ok && now.Before(expiresAt) {
+		return ErrExists
+	}
+	if m.items == nil {
+		m.items = make(map[string]time.Time)
+	}
+	m.items[key] = now.Add(ttl)
+	return nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, key string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.items == nil {
+		m.items = make(map[string]time.Time)
+	}
+	m.items[key] = m.now().Add(ttl)
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: make(map[string]time.Time),
+	}
+}
@@ -0,0 +1,68 @@
+package dedup_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/dedup"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	store := dedup.NewMemoryStore()
+	store.Now = func() time.Time { return now }
+
+	if ok, _ := store.Exists(ctx, "a"); ok {
+		t.Fatal("unexpected key")
+	}
+
+	if err := store.Put(ctx, "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := store.Exists(ctx, "a"); !ok {
+		t.Fatal("expected key")
+	}
+
+	if err := store.Put(ctx, "a", time.Minute); !errors.Is(err, dedup.ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+
+	if ok, _ := store.Exists(ctx, "a"); ok {
+		t.Fatal("expected key to expire")
+	}
+
+	if err := store.Put(ctx, "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := store.Exists(ctx, "a"); ok {
+		t.Fatal("expected key to be deleted")
+	}
+
+	if err := store.Update(ctx, "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Update(ctx, "a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+
+	if ok, _ := store.Exists(ctx, "a"); !ok {
+		t.Fatal("expected update to extend expiry")
+	}
+}
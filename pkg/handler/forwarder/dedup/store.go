@@ -0,0 +1,40 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrExists is returned when recording a key which is already present.
+var ErrExists = errors.New("key already exists")
+
+// Store records objects which have already been copied.
+//
+// Callers claim a key with Put before copying, so that concurrent
+// deliveries of the same notification do not both copy the object. Once
+// the copy completes, Update extends the claim. If the copy fails, Delete
+// releases the claim so that a retry can proceed.
+type Store interface {
+	// Exists verifies if key has been recorded and has not yet expired.
+	Exists(ctx context.Context, key string) (bool, error)
+	// Put records key until ttl elapses. It returns ErrExists if the key
+	// is already present.
+	Put(ctx context.Context, key string, ttl time.Duration) error
+	// Update records key until ttl elapses, regardless of whether it is
+	// already present.
+	Update(ctx context.Context, key string, ttl time.Duration) error
+	// Delete removes key.
+	Delete(ctx context.Context, key string) error
+}
+
+// Key builds a deduplication key for an object copied to a destination.
+// An empty key is returned if the object has neither an ETag nor version,
+// since we cannot tell apart different revisions of the same object.
+func Key(destination, bucket, key, etag, versionID string) string {
+	if etag == "" && versionID == "" {
+		return ""
+	}
+	return strings.Join([]string{destination, bucket, key, etag, versionID}, "|")
+}
@@ -21,12 +21,24 @@ import (
 	"github.com/go-logr/logr"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/dedup"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/seekable"
 )
 
-var errNoLambdaContext = fmt.Errorf("no lambda context found")
+var (
+	errNoLambdaContext = fmt.Errorf("no lambda context found")
 
-const writeSQSMemoryLimitBytes int64 = 8 * 1024 * 1024
+	ErrEventBridgeFailure = errors.New("failed to process event")
+)
+
+const (
+	writeSQSMemoryLimitBytes int64 = 8 * 1024 * 1024
+	defaultDedupTTL                = 24 * time.Hour
+	// defaultDedupLease bounds how long a claim is held when the context
+	// carries no deadline. It matches the maximum Lambda timeout.
+	defaultDedupLease = 15 * time.Minute
+)
 
 type S3Client interface {
 	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
@@ -38,16 +50,31 @@ type Override interface {
 	Apply(context.Context, *s3.CopyObjectInput) bool
 }
 
+// Destination is an additional location files are copied to.
+type Destination struct {
+	Name     string
+	URI      *url.URL
+	S3Client S3Client
+}
+
 type Handler struct {
 	handler.Mux
 
 	MaxFileSize        int64
+	OversizedMode      OversizedMode
+	MultipartThreshold int64
+	Sources            *SourceClients
 	DestinationURI     *url.URL
 	S3Client           S3Client
+	Destinations       []*Destination
+	Queue              Queue
+	DedupStore         dedup.Store
+	DedupTTL           time.Duration
 	Override           Override
 	ObjectPolicy       interface{ Allow(string) bool }
 	Now                func() time.Time
 	MaxConcurrentTasks int
+	MaxReceiveCount    int
 }
 
 // encodeCopySourceKey URL-encodes each path segment of an S3 key for use in
@@ -88,44 +115,37 @@ func GetCopyObjectInput(source, destination *url.URL) *s3.CopyObjectInput {
 }
 
 func (h *Handler) GetDestinationRegion(ctx context.Context, client s3.HeadBucketAPIClient) (string, error) {
-	if h.DestinationURI.Scheme != "s3" {
+	return GetDestinationRegion(ctx, client, h.DestinationURI)
+}
+
+// GetDestinationRegion returns the bucket region for S3 destinations.
+// An empty region is returned for any other destination type.
+func GetDestinationRegion(ctx context.Context, client s3.HeadBucketAPIClient, destination *url.URL) (string, error) {
+	if destination.Scheme != "s3" {
 		return "", nil
 	}
-	region, err := manager.GetBucketRegion(ctx, client, h.DestinationURI.Host)
+	region, err := manager.GetBucketRegion(ctx, client, destination.Host)
 	if err != nil {
 		return "", fmt.Errorf("failed to get region: %w", err)
 	}
 	return region, nil
 }
 
-func (h *Handler) WriteSQS(ctx context.Context, r io.Reader) error {
-	lctx, ok := lambdacontext.FromContext(ctx)
-	if !ok {
-		return errNoLambdaContext
-	}
-
-	functionArn, err := arn.Parse(lctx.InvokedFunctionArn)
-	if err != nil {
-		return fmt.Errorf("failed to parse function ARN: %w", err)
-	}
-
-	now := h.Now()
-	key := strings.Join([]string{
-		"AWSLogs",
-		functionArn.AccountID,
-		"sqs",
-		functionArn.Region,
-		now.Format("2006/01/02/15"), // use yyyy/mm/dd/hh format
-		lctx.AwsRequestID,
-	}, "/")
+// destinations returns all destinations in the order in which objects are copied.
+func (h *Handler) destinations() []*Destination {
+	return append([]*Destination{{URI: h.DestinationURI, S3Client: h.S3Client}}, h.Destinations...)
+}
 
+// putObject writes an object to the primary destination. The key is
+// prefixed by the destination path for S3 destinations.
+func (h *Handler) putObject(ctx context.Context, r io.Reader, key string, contentType string) error {
 	if h.DestinationURI.Scheme == "s3" {
 		key = strings.Trim(h.DestinationURI.Path, "/") + "/" + key
 	}
 
 	body, cleanup, err := seekable.FromReader(r, writeSQSMemoryLimitBytes)
 	if err != nil {
-		return fmt.Errorf("failed to prepare message body: %w", err)
+		return fmt.Errorf("failed to prepare body: %w", err)
 	}
 	defer func() {
 		if cleanupErr := cleanup(); cleanupErr != nil {
@@ -137,51 +157,285 @@ func (h *Handler) WriteSQS(ctx context.Context, r io.Reader) error {
 		Bucket:      aws.String(h.DestinationURI.Host),
 		Key:         &key,
 		Body:        body,
-		ContentType: aws.String("application/x-aws-sqs"),
+		ContentType: aws.String(contentType),
 	})
 	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+// objectKey returns a key unique to the current invocation.
+func (h *Handler) objectKey(ctx context.Context, service string) (string, error) {
+	lctx, ok := lambdacontext.FromContext(ctx)
+	if !ok {
+		return "", errNoLambdaContext
+	}
+
+	functionArn, err := arn.Parse(lctx.InvokedFunctionArn)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse function ARN: %w", err)
+	}
+
+	now := h.Now()
+	return strings.Join([]string{
+		"AWSLogs",
+		functionArn.AccountID,
+		service,
+		functionArn.Region,
+		now.Format("2006/01/02/15"), // use yyyy/mm/dd/hh format
+		lctx.AwsRequestID,
+	}, "/"), nil
+}
+
+func (h *Handler) WriteSQS(ctx context.Context, r io.Reader) error {
+	key, err := h.objectKey(ctx, "sqs")
+	if err != nil {
+		return err
+	}
+
+	if err := h.putObject(ctx, r, key, "application/x-aws-sqs"); err != nil {
 		return fmt.Errorf("failed to write messages: %w", err)
 	}
 	return nil
 }
 
-func (h *Handler) ProcessRecord(ctx context.Context, record *events.SQSMessage) error {
-	logger := logr.FromContextOrDiscard(ctx)
+// WriteErrors archives failed copy records as newline delimited JSON
+// under the errors/ prefix of the primary destination.
+func (h *Handler) WriteErrors(ctx context.Context, r io.Reader) error {
+	key, err := h.objectKey(ctx, "sqs")
+	if err != nil {
+		return err
+	}
 
-	copyRecords := GetObjectCreated(record)
-	for _, copyRecord := range copyRecords {
-		sourceURL, err := url.Parse(copyRecord.URI)
-		if err != nil {
-			logger.Error(err, "error parsing source URI", "SourceURI", copyRecord.URI)
-			continue
+	if err := h.putObject(ctx, r, "errors/"+key+".ndjson", "application/x-ndjson"); err != nil {
+		return fmt.Errorf("failed to write errors: %w", err)
+	}
+	return nil
+}
+
+// dedupLease returns how long to hold a claim while copying. A claim should
+// outlive the invocation, but not by so much that a crashed invocation
+// prevents redelivered notifications from being processed.
+func (h *Handler) dedupLease(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		if lease := deadline.Sub(h.Now()); lease > 0 {
+			return lease
 		}
+	}
+	return defaultDedupLease
+}
 
-		copyInput := GetCopyObjectInput(sourceURL, h.DestinationURI)
+// copyRecord copies an object to every destination requested by the copy
+// record. It returns a copy record restricted to the destinations that
+// failed, if any, and whether at least one copy succeeded.
+func (h *Handler) copyRecord(ctx context.Context, copyRecord CopyRecord, result *SQSMessage) (retry *CopyRecord, copied bool, err error) {
+	logger := logr.FromContextOrDiscard(ctx)
 
-		if !h.ObjectPolicy.Allow(sourceURL.Host + sourceURL.Path) {
-			logger.Info("Ignoring object not in allowed sources", "bucket", copyInput.Bucket, "key", copyInput.Key)
-			continue
-		}
+	sourceURL, err := url.Parse(copyRecord.URI)
+	if err != nil {
+		logger.Error(err, "error parsing source URI", "SourceURI", copyRecord.URI)
+		return nil, false, nil
+	}
+
+	if !h.ObjectPolicy.Allow(sourceURL.Host + sourceURL.Path) {
+		logger.Info("Ignoring object not in allowed sources", "bucket", sourceURL.Host, "key", strings.TrimLeft(sourceURL.Path, "/"))
+		result.Outcomes = append(result.Outcomes, &CopyOutcome{URI: copyRecord.URI, Status: OutcomeSkippedPolicy})
+		return nil, false, nil
+	}
 
-		if copyRecord.Size != nil && h.MaxFileSize > 0 && *copyRecord.Size > h.MaxFileSize {
+	oversized := copyRecord.Size != nil && h.MaxFileSize > 0 && *copyRecord.Size > h.MaxFileSize
+	if oversized {
+		switch h.OversizedMode {
+		case OversizedTruncate, OversizedSplit:
+			logger.V(1).Info("object size exceeds the maximum file size limit; copying ranges",
+				"max", h.MaxFileSize, "size", *copyRecord.Size, "uri", copyRecord.URI, "mode", h.OversizedMode)
+		case OversizedFail:
+			err := fmt.Errorf("%w: %q is %d bytes", ErrObjectTooLarge, copyRecord.URI, *copyRecord.Size)
+			result.Outcomes = append(result.Outcomes, &CopyOutcome{URI: copyRecord.URI, Status: OutcomeFailedSize})
+			return &copyRecord, false, err
+		default:
 			logger.V(1).Info("object size exceeds the maximum file size limit; skipping copy",
 				"max", h.MaxFileSize, "size", *copyRecord.Size, "uri", copyRecord.URI)
 			// Log a warning and skip this object by continuing to the next iteration
+			result.Outcomes = append(result.Outcomes, &CopyOutcome{URI: copyRecord.URI, Status: OutcomeSkippedSize})
+			return nil, false, nil
+		}
+	}
+
+	// object attributes are shared across destinations, so that rules
+	// retrieve them at most once per object
+	overrideCtx := override.NewObjectContext(ctx, h.sourceObject(sourceURL, &copyRecord))
+
+	var errs []error
+	for _, destination := range h.destinations() {
+		destinationURI := destination.URI.String()
+		if !copyRecord.HasDestination(destinationURI) {
 			continue
 		}
 
+		copyInput := GetCopyObjectInput(sourceURL, destination.URI)
+		outcome := &CopyOutcome{
+			URI:         copyRecord.URI,
+			Destination: destinationURI,
+		}
+
 		if h.Override != nil {
-			if h.Override.Apply(ctx, copyInput) && copyInput.Key == nil {
+			var overrideResult override.Result
+			modified := h.Override.Apply(override.NewContext(overrideCtx, &overrideResult), copyInput)
+			outcome.RuleID = overrideResult.RuleID
+			outcome.RuleSet = overrideResult.Set
+			if modified && copyInput.Key == nil {
 				logger.V(6).Info("ignoring object")
+				outcome.Status = OutcomeIgnored
+				result.Outcomes = append(result.Outcomes, outcome)
+				continue
+			}
+			// Objects routed by a rule are only copied to the named
+			// destination. All other objects go to unnamed destinations.
+			if overrideResult.Destination != destination.Name {
+				continue
+			}
+		} else if destination.Name != "" {
+			continue
+		}
+
+		outcome.Key = aws.ToString(copyInput.Key)
+		outcome.ContentType = aws.ToString(copyInput.ContentType)
+		outcome.ContentEncoding = aws.ToString(copyInput.ContentEncoding)
+		result.Outcomes = append(result.Outcomes, outcome)
+
+		dedupKey := dedup.Key(destinationURI, sourceURL.Host, strings.TrimLeft(sourceURL.Path, "/"), copyRecord.ETag, copyRecord.VersionID)
+		claimed := h.DedupStore != nil && dedupKey != ""
+		if claimed {
+			// claim the key before copying, so that concurrent deliveries of
+			// the same notification do not both copy the object.
+			switch err := h.DedupStore.Put(ctx, dedupKey, h.dedupLease(ctx)); {
+			case errors.Is(err, dedup.ErrExists):
+				logger.V(3).Info("skipping duplicate copy", "uri", copyRecord.URI, "destination", destinationURI)
+				outcome.Status = OutcomeDuplicate
+				copied = true
 				continue
+			case err != nil:
+				// a failure to claim should not prevent the copy from happening
+				logger.Error(err, "failed to claim copy", "uri", copyRecord.URI)
+				claimed = false
 			}
 		}
 
-		if _, err := h.S3Client.CopyObject(ctx, copyInput); err != nil {
-			return fmt.Errorf("error copying file %q: %w", copyRecord.URI, err)
+		start := h.Now()
+		if oversized {
+			outcome.Bytes, outcome.Parts, err = h.copyRanges(ctx, destination, sourceURL, copyInput, &copyRecord)
+		} else {
+			outcome.Bytes, err = h.copyObject(ctx, destination, copyInput, &copyRecord)
+		}
+		outcome.DurationMillis = h.Now().Sub(start).Milliseconds()
+		if err != nil {
+			outcome.Status = OutcomeFailed
+			err = fmt.Errorf("error copying file %q to %q: %w", copyRecord.URI, destinationURI, err)
+			errs = append(errs, err)
+			result.Failures = append(result.Failures, &DestinationError{
+				URI:          copyRecord.URI,
+				Destination:  destinationURI,
+				ErrorMessage: err.Error(),
+			})
+			if retry == nil {
+				retry = &CopyRecord{
+					URI:       copyRecord.URI,
+					Size:      copyRecord.Size,
+					ETag:      copyRecord.ETag,
+					VersionID: copyRecord.VersionID,
+					EventTime: copyRecord.EventTime,
+				}
+			}
+			retry.Destinations = append(retry.Destinations, destinationURI)
+			if claimed {
+				// release the claim so that the retry is not skipped
+				if err := h.DedupStore.Delete(context.WithoutCancel(ctx), dedupKey); err != nil {
+					logger.Error(err, "failed to release copy", "uri", copyRecord.URI)
+				}
+			}
+			continue
+		}
+		copied = true
+		switch {
+		case !oversized:
+			outcome.Status = OutcomeCopied
+		case h.OversizedMode == OversizedTruncate:
+			outcome.Status = OutcomeTruncated
+		default:
+			outcome.Status = OutcomeSplit
+		}
+
+		if claimed {
+			if err := h.DedupStore.Update(ctx, dedupKey, h.DedupTTL); err != nil {
+				logger.Error(err, "failed to record copy", "uri", copyRecord.URI)
+			}
 		}
 	}
-	return nil
+	return retry, copied, errors.Join(errs...)
+}
+
+// ProcessRecord copies all objects referenced in an SQS message to every destination.
+// Failures are recorded per destination in the provided result. If some
+// copies succeed, failed copies are resubmitted to the queue so that
+// retries do not copy objects to the same destination twice.
+func (h *Handler) ProcessRecord(ctx context.Context, result *SQSMessage) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	var (
+		retries []CopyRecord
+		failed  []*FailedCopyRecord
+		copied  bool
+		errs    []error
+	)
+
+	for _, copyRecord := range GetObjectCreated(&result.SQSMessage) {
+		retry, ok, err := h.copyRecord(ctx, copyRecord, result)
+		copied = copied || ok
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if retry != nil {
+			retries = append(retries, *retry)
+			failed = append(failed, &FailedCopyRecord{
+				CopyRecord:   *retry,
+				ErrorMessage: err.Error(),
+				ErrorClass:   ErrorClass(err),
+			})
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err == nil {
+		return nil
+	}
+
+	result.failed = failed
+	result.retryAfter = retryDelay(err)
+	if isRejectedCopyError(err) {
+		// retrying would only delay archiving the failure
+		result.permanent = true
+		return err
+	}
+
+	if copied && h.Queue != nil && !result.senderRetries {
+		// Only resubmit on partial progress. If nothing was copied, we rely
+		// on SQS redrive so that persistent failures end up in the dead
+		// letter queue.
+		var putErr error
+		if q, ok := h.Queue.(DelayedQueue); ok && result.retryAfter > 0 {
+			putErr = q.PutDelayed(ctx, result.retryAfter, &CopyEvent{Copy: retries})
+		} else {
+			putErr = h.Queue.Put(ctx, &CopyEvent{Copy: retries})
+		}
+		if putErr == nil {
+			logger.Error(err, "resubmitted failed copies", "count", len(retries))
+			return nil
+		}
+		logger.Error(putErr, "failed to resubmit failed copies")
+	}
+	return err
 }
 
 func (h *Handler) Handle(ctx context.Context, request events.SQSEvent) (response events.SQSEventResponse, err error) {
@@ -207,7 +461,7 @@ func (h *Handler) Handle(ctx context.Context, request events.SQSEvent) (response
 		go func(m events.SQSMessage) {
 			defer releaseToken()
 			result := &SQSMessage{SQSMessage: m}
-			if err := h.ProcessRecord(ctx, &m); err != nil {
+			if err := h.ProcessRecord(ctx, result); err != nil {
 				logger.Error(err, "failed to process record")
 				result.ErrorMessage = err.Error()
 			}
@@ -215,18 +469,50 @@ func (h *Handler) Handle(ctx context.Context, request events.SQSEvent) (response
 		}(record)
 	}
 
-	var messages bytes.Buffer
+	var (
+		messages, failures bytes.Buffer
+		// permanent failures are not retried once archived
+		permanent []events.SQSBatchItemFailure
+	)
 	encoder := json.NewEncoder(&messages)
+	failureEncoder := json.NewEncoder(&failures)
 	for i := 0; i < len(request.Records); i++ {
 		result := <-resultCh
 		if result.ErrorMessage != "" {
-			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
-				ItemIdentifier: result.MessageId,
-			})
+			failure := events.SQSBatchItemFailure{ItemIdentifier: result.MessageId}
+			if result.permanent {
+				permanent = append(permanent, failure)
+			} else {
+				response.BatchItemFailures = append(response.BatchItemFailures, failure)
+				h.delayRetry(ctx, result)
+			}
 		}
 		if e := encoder.Encode(result); e != nil {
 			err = errors.Join(err, fmt.Errorf("failed to encode message: %w", e))
 		}
+
+		// archive failures on the final delivery, before the message
+		// is moved to the dead letter queue, or immediately if retrying
+		// cannot succeed
+		if attempts := result.Attempts(); result.permanent || (h.MaxReceiveCount > 0 && attempts >= h.MaxReceiveCount) {
+			for _, failed := range result.failed {
+				failed.MessageID = result.MessageId
+				failed.Attempts = attempts
+				failed.Timestamp = h.Now()
+				if e := failureEncoder.Encode(failed); e != nil {
+					err = errors.Join(err, fmt.Errorf("failed to encode failure: %w", e))
+				}
+			}
+		}
+	}
+
+	if err == nil && failures.Len() > 0 {
+		if e := h.WriteErrors(ctx, &failures); e != nil {
+			// the dead letter queue retains the original message, so
+			// permanent failures must also be retried
+			logger.Error(e, "failed to archive failed copies")
+			response.BatchItemFailures = append(response.BatchItemFailures, permanent...)
+		}
 	}
 
 	if err == nil {
@@ -236,6 +522,44 @@ func (h *Handler) Handle(ctx context.Context, request events.SQSEvent) (response
 	return
 }
 
+// delayRetry postpones redelivery of a failed message if a destination
+// requested a delay before retrying, e.g. when throttling requests.
+func (h *Handler) delayRetry(ctx context.Context, result *SQSMessage) {
+	q, ok := h.Queue.(DelayedQueue)
+	if !ok || result.retryAfter <= 0 || result.ReceiptHandle == "" {
+		return
+	}
+	if err := q.Delay(ctx, result.ReceiptHandle, result.retryAfter); err != nil {
+		logr.FromContextOrDiscard(ctx).Error(err, "failed to delay retry", "messageId", result.MessageId)
+	}
+}
+
+// HandleEventBridge processes events delivered directly by an EventBridge rule.
+// The event is processed as a single SQS message, so that it is recorded
+// alongside all other messages.
+func (h *Handler) HandleEventBridge(ctx context.Context, event events.EventBridgeEvent) (response events.SQSEventResponse, err error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return response, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	response, err = h.Handle(ctx, events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				MessageId:   event.ID,
+				Body:        string(body),
+				EventSource: "aws.events",
+				AWSRegion:   event.Region,
+			},
+		},
+	})
+	if err == nil && len(response.BatchItemFailures) > 0 {
+		// asynchronous invocations are retried only if an error is returned
+		err = ErrEventBridgeFailure
+	}
+	return response, err
+}
+
 func New(cfg *Config) (h *Handler, err error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -250,14 +574,42 @@ func New(cfg *Config) (h *Handler, err error) {
 
 	objectFilter, _ := NewObjectFilter(cfg.SourceBucketNames, cfg.SourceObjectKeys)
 
+	multipartThreshold := cfg.MultipartThreshold
+	if multipartThreshold == 0 {
+		multipartThreshold = maxCopyObjectSize
+	}
+
+	dedupTTL := cfg.DedupTTL
+	if dedupTTL == 0 {
+		dedupTTL = defaultDedupTTL
+	}
+
+	destinations := make([]*Destination, 0, len(cfg.Destinations))
+	for _, d := range cfg.Destinations {
+		du, _ := url.ParseRequestURI(d.URI)
+		destinations = append(destinations, &Destination{
+			Name:     d.Name,
+			URI:      du,
+			S3Client: d.S3Client,
+		})
+	}
+
 	h = &Handler{
 		DestinationURI:     u,
 		S3Client:           cfg.S3Client,
+		Destinations:       destinations,
+		Queue:              cfg.Queue,
+		DedupStore:         cfg.DedupStore,
+		DedupTTL:           dedupTTL,
 		MaxFileSize:        cfg.MaxFileSize,
+		OversizedMode:      cfg.OversizedMode,
+		MultipartThreshold: multipartThreshold,
+		Sources:            cfg.Sources,
 		Override:           cfg.Override,
 		ObjectPolicy:       objectFilter,
 		Now:                time.Now,
 		MaxConcurrentTasks: maxConcurrentTasks,
+		MaxReceiveCount:    cfg.MaxReceiveCount,
 	}
 
 	return h, nil
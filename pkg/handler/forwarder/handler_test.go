@@ -9,6 +9,9 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -17,10 +20,14 @@ import (
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/dedup"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
 	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
 )
 
@@ -513,3 +520,921 @@ func TestWriteSQSUsesSeekableBody(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type fakeQueue struct {
+	sync.Mutex
+	Items []any
+	Err   error
+}
+
+func (q *fakeQueue) Put(_ context.Context, items ...any) error {
+	q.Lock()
+	defer q.Unlock()
+	if q.Err != nil {
+		return q.Err
+	}
+	q.Items = append(q.Items, items...)
+	return nil
+}
+
+func TestHandlerDestinations(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name             string
+		Body             string
+		Queue            *fakeQueue
+		SecondaryErr     error
+		ExpectPrimary    int64
+		ExpectSecondary  int64
+		ExpectResponse   events.SQSEventResponse
+		ExpectQueueItems []any
+	}{
+		{
+			Name:            "all succeed",
+			Body:            `{"copy": [{"uri": "s3://source/a.json"}]}`,
+			ExpectPrimary:   1,
+			ExpectSecondary: 1,
+		},
+		{
+			Name:            "partial failure without queue retries message",
+			Body:            `{"copy": [{"uri": "s3://source/a.json"}]}`,
+			SecondaryErr:    errSentinel,
+			ExpectPrimary:   1,
+			ExpectSecondary: 1,
+			ExpectResponse: events.SQSEventResponse{
+				BatchItemFailures: []events.SQSBatchItemFailure{
+					{ItemIdentifier: "1"},
+				},
+			},
+		},
+		{
+			Name:            "partial failure with queue resubmits failed destination",
+			Body:            `{"copy": [{"uri": "s3://source/a.json", "size": 5, "eventTime": "2024-01-02T03:04:05Z"}]}`,
+			Queue:           &fakeQueue{},
+			SecondaryErr:    errSentinel,
+			ExpectPrimary:   1,
+			ExpectSecondary: 1,
+			ExpectQueueItems: []any{
+				&forwarder.CopyEvent{
+					Copy: []forwarder.CopyRecord{
+						{
+							URI:          "s3://source/a.json",
+							Size:         aws.Int64(5),
+							EventTime:    pointerToTime(t, "2024-01-02T03:04:05Z"),
+							Destinations: []string{"https://example.com/v1/http"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:            "complete failure with queue retries message",
+			Body:            `{"copy": [{"uri": "s3://source/a.json", "destinations": ["https://example.com/v1/http"]}]}`,
+			Queue:           &fakeQueue{},
+			SecondaryErr:    errSentinel,
+			ExpectSecondary: 1,
+			ExpectResponse: events.SQSEventResponse{
+				BatchItemFailures: []events.SQSBatchItemFailure{
+					{ItemIdentifier: "1"},
+				},
+			},
+		},
+		{
+			Name:            "failing resubmission retries message",
+			Body:            `{"copy": [{"uri": "s3://source/a.json"}]}`,
+			Queue:           &fakeQueue{Err: errSentinel},
+			SecondaryErr:    errSentinel,
+			ExpectPrimary:   1,
+			ExpectSecondary: 1,
+			ExpectResponse: events.SQSEventResponse{
+				BatchItemFailures: []events.SQSBatchItemFailure{
+					{ItemIdentifier: "1"},
+				},
+			},
+		},
+		{
+			Name:            "copy restricted to requested destinations",
+			Body:            `{"copy": [{"uri": "s3://source/a.json", "destinations": ["s3://primary"]}]}`,
+			ExpectPrimary:   1,
+			ExpectSecondary: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			var primaryCalls, secondaryCalls atomic.Int64
+
+			cfg := &forwarder.Config{
+				DestinationURI: "s3://primary",
+				S3Client: &awstest.S3Client{
+					CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						primaryCalls.Add(1)
+						return nil, nil
+					},
+				},
+				Destinations: []*forwarder.DestinationConfig{
+					{
+						URI: "https://example.com/v1/http",
+						S3Client: &awstest.S3Client{
+							CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+								secondaryCalls.Add(1)
+								return nil, tc.SecondaryErr
+							},
+						},
+					},
+				},
+			}
+			if tc.Queue != nil {
+				cfg.Queue = tc.Queue
+			}
+
+			h, err := forwarder.New(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+			response, err := h.Handle(ctx, events.SQSEvent{
+				Records: []events.SQSMessage{{MessageId: "1", Body: tc.Body}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(response, tc.ExpectResponse); diff != "" {
+				t.Error("unexpected response", diff)
+			}
+
+			if v := primaryCalls.Load(); v != tc.ExpectPrimary {
+				t.Errorf("expected %d copies to primary destination, got %d", tc.ExpectPrimary, v)
+			}
+
+			if v := secondaryCalls.Load(); v != tc.ExpectSecondary {
+				t.Errorf("expected %d copies to secondary destination, got %d", tc.ExpectSecondary, v)
+			}
+
+			if tc.Queue != nil {
+				if diff := cmp.Diff(tc.Queue.Items, tc.ExpectQueueItems); diff != "" {
+					t.Error("unexpected queue items", diff)
+				}
+			}
+		})
+	}
+}
+
+// throttledError requests a delay before retrying.
+type throttledError struct{}
+
+func (throttledError) Error() string             { return "too many requests" }
+func (throttledError) Retryable() bool           { return true }
+func (throttledError) RetryDelay() time.Duration { return time.Minute }
+
+type fakeDelayedQueue struct {
+	fakeQueue
+	PutDelays []time.Duration
+	Delays    map[string]time.Duration
+}
+
+func (q *fakeDelayedQueue) PutDelayed(ctx context.Context, delay time.Duration, items ...any) error {
+	q.Lock()
+	q.PutDelays = append(q.PutDelays, delay)
+	q.Unlock()
+	return q.Put(ctx, items...)
+}
+
+func (q *fakeDelayedQueue) Delay(_ context.Context, receiptHandle string, delay time.Duration) error {
+	q.Lock()
+	defer q.Unlock()
+	if q.Delays == nil {
+		q.Delays = make(map[string]time.Duration)
+	}
+	q.Delays[receiptHandle] = delay
+	return nil
+}
+
+func TestHandlerRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name            string
+		Body            string
+		ExpectPutDelays []time.Duration
+		ExpectDelays    map[string]time.Duration
+	}{
+		{
+			Name:            "partial failure resubmits with delay",
+			Body:            `{"copy": [{"uri": "s3://source/a.json"}]}`,
+			ExpectPutDelays: []time.Duration{time.Minute},
+		},
+		{
+			Name:         "complete failure delays redelivery",
+			Body:         `{"copy": [{"uri": "s3://source/a.json", "destinations": ["https://example.com/v1/http"]}]}`,
+			ExpectDelays: map[string]time.Duration{"receipt": time.Minute},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			queue := &fakeDelayedQueue{}
+			h, err := forwarder.New(&forwarder.Config{
+				DestinationURI: "s3://primary",
+				S3Client: &awstest.S3Client{
+					CopyObjectFunc: func(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						return nil, nil
+					},
+				},
+				Destinations: []*forwarder.DestinationConfig{
+					{
+						URI: "https://example.com/v1/http",
+						S3Client: &awstest.S3Client{
+							CopyObjectFunc: func(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+								return nil, throttledError{}
+							},
+						},
+					},
+				},
+				Queue: queue,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+			if _, err := h.Handle(ctx, events.SQSEvent{
+				Records: []events.SQSMessage{{MessageId: "1", ReceiptHandle: "receipt", Body: tc.Body}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(queue.PutDelays, tc.ExpectPutDelays); diff != "" {
+				t.Error("unexpected resubmission delays", diff)
+			}
+			if diff := cmp.Diff(queue.Delays, tc.ExpectDelays); diff != "" {
+				t.Error("unexpected redelivery delays", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerRecordsDestinationFailures(t *testing.T) {
+	t.Parallel()
+
+	var written bytes.Buffer
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://primary",
+		S3Client: &awstest.S3Client{
+			PutObjectFunc: func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				_, err := written.ReadFrom(input.Body)
+				return nil, err
+			},
+		},
+		Destinations: []*forwarder.DestinationConfig{
+			{
+				URI: "s3://secondary/prefix",
+				S3Client: &awstest.S3Client{
+					CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						return nil, errSentinel
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+	if _, err := h.Handle(ctx, events.SQSEvent{
+		Records: []events.SQSMessage{{MessageId: "1", Body: `{"copy": [{"uri": "s3://source/a.json"}]}`}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got forwarder.SQSMessage
+	if err := json.Unmarshal(written.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []*forwarder.DestinationError{
+		{
+			URI:          "s3://source/a.json",
+			Destination:  "s3://secondary/prefix",
+			ErrorMessage: `error copying file "s3://source/a.json" to "s3://secondary/prefix": sentinel error`,
+		},
+	}
+
+	if diff := cmp.Diff(got.Failures, expect); diff != "" {
+		t.Error("unexpected failures", diff)
+	}
+}
+
+func TestHandlerRecordsOutcomes(t *testing.T) {
+	t.Parallel()
+
+	var written bytes.Buffer
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI:    "s3://destination/prefix",
+		MaxFileSize:       100,
+		SourceBucketNames: []string{"source"},
+		Override: &override.Set{
+			Name:   "custom",
+			Logger: logr.Discard(),
+			Rules: []*override.Rule{
+				{
+					ID:       "ignore",
+					Match:    override.Filter{Source: regexp.MustCompile(`ignored`)},
+					Override: override.Action{ContentType: aws.String("text/x-ignore")},
+				},
+				{
+					ID:       "json",
+					Match:    override.Filter{Source: regexp.MustCompile(`\.json$`)},
+					Override: override.Action{ContentType: aws.String("application/x-ndjson")},
+				},
+			},
+		},
+		S3Client: &awstest.S3Client{
+			CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				now = now.Add(time.Second)
+				return nil, nil
+			},
+			PutObjectFunc: func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				_, err := written.ReadFrom(input.Body)
+				return nil, err
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Now = func() time.Time { return now }
+
+	body := `{"copy": [
+		{"uri": "s3://source/a.json", "size": 10},
+		{"uri": "s3://source/ignored.json", "size": 10},
+		{"uri": "s3://source/large.json", "size": 1000},
+		{"uri": "s3://other/a.json", "size": 10}
+	]}`
+
+	ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+	if _, err := h.Handle(ctx, events.SQSEvent{
+		Records: []events.SQSMessage{{MessageId: "1", Body: body}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got forwarder.SQSMessage
+	if err := json.Unmarshal(written.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []*forwarder.CopyOutcome{
+		{
+			URI:            "s3://source/a.json",
+			Destination:    "s3://destination/prefix",
+			Key:            "prefix/a.json",
+			Status:         forwarder.OutcomeCopied,
+			RuleID:         "json",
+			RuleSet:        "custom",
+			ContentType:    "application/x-ndjson",
+			Bytes:          10,
+			DurationMillis: 1000,
+		},
+		{
+			URI:         "s3://source/ignored.json",
+			Destination: "s3://destination/prefix",
+			Status:      forwarder.OutcomeIgnored,
+			RuleID:      "ignore",
+			RuleSet:     "custom",
+		},
+		{
+			URI:    "s3://source/large.json",
+			Status: forwarder.OutcomeSkippedSize,
+		},
+		{
+			URI:    "s3://other/a.json",
+			Status: forwarder.OutcomeSkippedPolicy,
+		},
+	}
+
+	if diff := cmp.Diff(got.Outcomes, expect); diff != "" {
+		t.Error("unexpected outcomes", diff)
+	}
+}
+
+func TestHandlerRouting(t *testing.T) {
+	t.Parallel()
+
+	var route override.DestinationRule
+	if err := route.UnmarshalText([]byte(`/CloudTrail/=security`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu     sync.Mutex
+		copied = make(map[string][]string)
+	)
+
+	recordCopy := func(name string) func(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		return func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			copied[name] = append(copied[name], aws.ToString(input.CopySource))
+			return nil, nil
+		}
+	}
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://default",
+		S3Client:       &awstest.S3Client{CopyObjectFunc: recordCopy("default")},
+		Override: &override.Set{
+			Logger: logr.Discard(),
+			Rules:  []*override.Rule{&route.Rule},
+		},
+		Destinations: []*forwarder.DestinationConfig{
+			{
+				Name:     "security",
+				URI:      "https://example.com/v1/http",
+				S3Client: &awstest.S3Client{CopyObjectFunc: recordCopy("security")},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+	response, err := h.Handle(ctx, events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "1", Body: `{"copy": [{"uri": "s3://source/AWSLogs/123456789012/CloudTrail/file.json.gz"}]}`},
+			{MessageId: "2", Body: `{"copy": [{"uri": "s3://source/AWSLogs/123456789012/vpcflowlogs/file.log.gz"}]}`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.BatchItemFailures) != 0 {
+		t.Fatalf("unexpected failures: %v", response.BatchItemFailures)
+	}
+
+	expect := map[string][]string{
+		"default":  {"source/AWSLogs/123456789012/vpcflowlogs/file.log.gz"},
+		"security": {"source/AWSLogs/123456789012/CloudTrail/file.json.gz"},
+	}
+	if diff := cmp.Diff(copied, expect); diff != "" {
+		t.Error("unexpected routing", diff)
+	}
+}
+
+func TestHandlerDedup(t *testing.T) {
+	t.Parallel()
+
+	var copies atomic.Int32
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination",
+		S3Client: &awstest.S3Client{
+			CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				copies.Add(1)
+				return nil, nil
+			},
+		},
+		DedupStore: dedup.NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+	for _, body := range []string{
+		`{"copy": [{"uri": "s3://source/a.json", "etag": "abc"}]}`,
+		`{"copy": [{"uri": "s3://source/a.json", "etag": "abc"}]}`,
+		`{"copy": [{"uri": "s3://source/a.json", "etag": "def"}]}`,
+		`{"copy": [{"uri": "s3://source/b.json"}]}`,
+		`{"copy": [{"uri": "s3://source/b.json"}]}`,
+	} {
+		response, err := h.Handle(ctx, events.SQSEvent{
+			Records: []events.SQSMessage{{MessageId: "1", Body: body}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatalf("unexpected failures: %v", response.BatchItemFailures)
+		}
+	}
+
+	// objects without an etag or version cannot be deduplicated
+	if got := copies.Load(); got != 4 {
+		t.Fatalf("expected 4 copies, got %d", got)
+	}
+}
+
+func TestHandlerDedupClaim(t *testing.T) {
+	t.Parallel()
+
+	var (
+		h      *forwarder.Handler
+		copies atomic.Int32
+		fail   atomic.Bool
+	)
+
+	ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+	request := events.SQSEvent{
+		Records: []events.SQSMessage{{MessageId: "1", Body: `{"copy": [{"uri": "s3://source/a.json", "etag": "abc"}]}`}},
+	}
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination",
+		S3Client: &awstest.S3Client{
+			CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				if copies.Add(1) == 1 {
+					// a concurrent delivery while the copy is in flight
+					// must not copy the object again
+					response, err := h.Handle(ctx, request)
+					if err != nil || len(response.BatchItemFailures) != 0 {
+						t.Errorf("unexpected concurrent result: %v, %v", response, err)
+					}
+				}
+				if fail.Load() {
+					return nil, errSentinel
+				}
+				return nil, nil
+			},
+		},
+		DedupStore: dedup.NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed copy releases its claim, so that a retry proceeds
+	fail.Store(true)
+	if response, _ := h.Handle(ctx, request); len(response.BatchItemFailures) != 1 {
+		t.Fatalf("expected failure, got %v", response.BatchItemFailures)
+	}
+	fail.Store(false)
+	if response, _ := h.Handle(ctx, request); len(response.BatchItemFailures) != 0 {
+		t.Fatalf("unexpected failures: %v", response.BatchItemFailures)
+	}
+	if response, _ := h.Handle(ctx, request); len(response.BatchItemFailures) != 0 {
+		t.Fatalf("unexpected failures: %v", response.BatchItemFailures)
+	}
+
+	if got := copies.Load(); got != 2 {
+		t.Fatalf("expected 2 copies, got %d", got)
+	}
+}
+
+func TestHandleEventBridge(t *testing.T) {
+	t.Parallel()
+
+	event := events.EventBridgeEvent{
+		Version:    "0",
+		ID:         "17793124-05d4-b198-2fde-7ededc63b103",
+		DetailType: "Object Created",
+		Source:     "aws.s3",
+		Region:     "us-west-2",
+		Detail:     json.RawMessage(`{"bucket":{"name":"my-bucket"},"object":{"key":"test.json","size":5,"etag":"abc"}}`),
+	}
+
+	testcases := []struct {
+		CopyErr   error
+		ExpectErr error
+	}{
+		{},
+		{
+			CopyErr:   errSentinel,
+			ExpectErr: forwarder.ErrEventBridgeFailure,
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			var copySource string
+			h, err := forwarder.New(&forwarder.Config{
+				DestinationURI: "s3://destination",
+				S3Client: &awstest.S3Client{
+					CopyObjectFunc: func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						copySource = aws.ToString(input.CopySource)
+						return nil, tc.CopyErr
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+			if _, err := h.HandleEventBridge(ctx, event); !errors.Is(err, tc.ExpectErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if copySource != "my-bucket/test.json" {
+				t.Fatalf("unexpected copy source %q", copySource)
+			}
+		})
+	}
+}
+
+func TestHandlerArchivesFailures(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testcases := []struct {
+		ReceiveCount string
+		Expect       []*forwarder.FailedCopyRecord
+	}{
+		{
+			// not the final attempt
+			ReceiveCount: "3",
+		},
+		{
+			ReceiveCount: "4",
+			Expect: []*forwarder.FailedCopyRecord{
+				{
+					CopyRecord: forwarder.CopyRecord{
+						URI:          "s3://source/a.json",
+						ETag:         "abc",
+						Destinations: []string{"s3://destination/prefix"},
+					},
+					MessageID:    "1",
+					ErrorMessage: `error copying file "s3://source/a.json" to "s3://destination/prefix": sentinel error`,
+					ErrorClass:   "Unknown",
+					Attempts:     4,
+					Timestamp:    now,
+				},
+			},
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu      sync.Mutex
+				written = make(map[string][]byte)
+			)
+
+			h, err := forwarder.New(&forwarder.Config{
+				DestinationURI:  "s3://destination/prefix",
+				MaxReceiveCount: 4,
+				S3Client: &awstest.S3Client{
+					CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						return nil, errSentinel
+					},
+					PutObjectFunc: func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+						data, err := io.ReadAll(input.Body)
+						mu.Lock()
+						defer mu.Unlock()
+						written[aws.ToString(input.Key)] = data
+						return nil, err
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Now = func() time.Time { return now }
+
+			ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+			response, err := h.Handle(ctx, events.SQSEvent{
+				Records: []events.SQSMessage{
+					{
+						MessageId:  "1",
+						Body:       `{"copy": [{"uri": "s3://source/a.json", "etag": "abc"}]}`,
+						Attributes: map[string]string{"ApproximateReceiveCount": tc.ReceiveCount},
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(response.BatchItemFailures) != 1 {
+				t.Fatalf("expected message to fail, got %v", response.BatchItemFailures)
+			}
+
+			key := "prefix/errors/AWSLogs/123456789012/sqs/us-east-1/2024/01/02/03/c8ee04d5-5925-541a-b113-5942a0fc5985.ndjson"
+			var got []*forwarder.FailedCopyRecord
+			if data, ok := written[key]; ok {
+				dec := json.NewDecoder(bytes.NewReader(data))
+				for dec.More() {
+					var record forwarder.FailedCopyRecord
+					if err := dec.Decode(&record); err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, &record)
+				}
+			}
+
+			if diff := cmp.Diff(got, tc.Expect); diff != "" {
+				t.Error("unexpected archive", diff)
+			}
+		})
+	}
+}
+
+// statusError mimics an HTTP destination error.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string       { return fmt.Sprintf("status %d", e.code) }
+func (e *statusError) HTTPStatusCode() int { return e.code }
+func (e *statusError) Retryable() bool     { return e.code >= 500 }
+
+func TestHandlerArchivesRejected(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testcases := []struct {
+		Name          string
+		CopyErr       error
+		ArchiveErr    error
+		ExpectFailure bool
+		Expect        []*forwarder.FailedCopyRecord
+	}{
+		{
+			Name:          "retryable",
+			CopyErr:       &statusError{code: 503},
+			ExpectFailure: true,
+		},
+		{
+			Name:    "rejected",
+			CopyErr: &statusError{code: 400},
+			Expect: []*forwarder.FailedCopyRecord{
+				{
+					CopyRecord: forwarder.CopyRecord{
+						URI:          "s3://source/a.json",
+						Destinations: []string{"s3://destination/prefix"},
+					},
+					MessageID:    "1",
+					ErrorMessage: `error copying file "s3://source/a.json" to "s3://destination/prefix": status 400`,
+					ErrorClass:   "HTTP400",
+					Attempts:     1,
+					Timestamp:    now,
+				},
+			},
+		},
+		{
+			// failures are retried if they cannot be archived
+			Name:          "archive failure",
+			CopyErr:       &statusError{code: 400},
+			ArchiveErr:    errSentinel,
+			ExpectFailure: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu      sync.Mutex
+				written = make(map[string][]byte)
+			)
+
+			h, err := forwarder.New(&forwarder.Config{
+				DestinationURI:  "s3://destination/prefix",
+				MaxReceiveCount: 4,
+				S3Client: &awstest.S3Client{
+					CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						return nil, tc.CopyErr
+					},
+					PutObjectFunc: func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+						key := aws.ToString(input.Key)
+						if tc.ArchiveErr != nil && strings.Contains(key, "/errors/") {
+							return nil, tc.ArchiveErr
+						}
+						data, err := io.ReadAll(input.Body)
+						mu.Lock()
+						defer mu.Unlock()
+						written[key] = data
+						return nil, err
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Now = func() time.Time { return now }
+
+			ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+			response, err := h.Handle(ctx, events.SQSEvent{
+				Records: []events.SQSMessage{
+					{
+						MessageId:  "1",
+						Body:       `{"copy": [{"uri": "s3://source/a.json"}]}`,
+						Attributes: map[string]string{"ApproximateReceiveCount": "1"},
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if failed := len(response.BatchItemFailures) > 0; failed != tc.ExpectFailure {
+				t.Fatalf("unexpected batch item failures: %v", response.BatchItemFailures)
+			}
+
+			key := "prefix/errors/AWSLogs/123456789012/sqs/us-east-1/2024/01/02/03/c8ee04d5-5925-541a-b113-5942a0fc5985.ndjson"
+			var got []*forwarder.FailedCopyRecord
+			if data, ok := written[key]; ok {
+				dec := json.NewDecoder(bytes.NewReader(data))
+				for dec.More() {
+					var record forwarder.FailedCopyRecord
+					if err := dec.Decode(&record); err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, &record)
+				}
+			}
+
+			if diff := cmp.Diff(got, tc.Expect); diff != "" {
+				t.Error("unexpected archive", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerObjectTags(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		copied   []string
+		taggings = make(map[string]int)
+	)
+
+	client := &awstest.S3Client{
+		CopyObjectFunc: func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			copied = append(copied, aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key))
+			return &s3.CopyObjectOutput{}, nil
+		},
+		GetObjectTaggingFunc: func(_ context.Context, input *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+			key := aws.ToString(input.Key)
+			mu.Lock()
+			taggings[key]++
+			mu.Unlock()
+			var output s3.GetObjectTaggingOutput
+			if key == "ignored.json" {
+				output.TagSet = append(output.TagSet, s3types.Tag{Key: aws.String("observe:ignore"), Value: aws.String("true")})
+			}
+			return &output, nil
+		},
+	}
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination/prefix",
+		Destinations: []*forwarder.DestinationConfig{
+			{URI: "s3://backup/prefix", S3Client: client},
+		},
+		Override: &override.Set{
+			Logger: logr.Discard(),
+			Rules: []*override.Rule{
+				{
+					ID: "ignore",
+					Match: override.Filter{
+						Tags: map[string]*regexp.Regexp{"observe:ignore": regexp.MustCompile(`^true$`)},
+					},
+					Override: override.Action{ContentType: aws.String("text/x-ignore")},
+				},
+			},
+		},
+		S3Client: client,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.ProcessRecord(context.Background(), &forwarder.SQSMessage{
+		SQSMessage: events.SQSMessage{
+			Body: `{"copy": [{"uri": "s3://source/a.json"}, {"uri": "s3://source/ignored.json"}]}`,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(copied, []string{"destination/prefix/a.json", "backup/prefix/a.json"}); diff != "" {
+		t.Error("unexpected copies", diff)
+	}
+
+	// tags are retrieved once per object, regardless of destination count
+	if diff := cmp.Diff(taggings, map[string]int{"a.json": 1, "ignored.json": 1}); diff != "" {
+		t.Error("unexpected tagging requests", diff)
+	}
+}
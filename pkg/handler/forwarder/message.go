@@ -1,21 +1,179 @@
 package forwarder
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/smithy-go"
 )
 
 type SQSMessage struct {
 	events.SQSMessage
-	ErrorMessage string `json:"error,omitempty"`
+	ErrorMessage string              `json:"error,omitempty"`
+	Failures     []*DestinationError `json:"failures,omitempty"`
+	Outcomes     []*CopyOutcome      `json:"outcomes,omitempty"`
+
+	// failed contains copy records which could not be processed
+	failed []*FailedCopyRecord
+	// permanent is set if no failed copy can succeed on retry
+	permanent bool
+	// retryAfter is the delay requested by destinations before retrying
+	retryAfter time.Duration
+	// senderRetries is set if the sender retries failed messages itself,
+	// in which case failed copies are not resubmitted to the queue
+	senderRetries bool
+}
+
+// Attempts returns the number of times the message has been delivered.
+func (m *SQSMessage) Attempts() int {
+	attempts, _ := strconv.Atoi(m.Attributes["ApproximateReceiveCount"])
+	return attempts
+}
+
+// DestinationError records a failure to copy an object to a destination.
+type DestinationError struct {
+	URI          string `json:"uri"`
+	Destination  string `json:"destination"`
+	ErrorMessage string `json:"error"`
+}
+
+// Copy outcome statuses.
+const (
+	OutcomeCopied        = "copied"
+	OutcomeDuplicate     = "duplicate"
+	OutcomeFailed        = "failed"
+	OutcomeFailedSize    = "failed_size"
+	OutcomeIgnored       = "ignored"
+	OutcomeSkippedPolicy = "skipped_policy"
+	OutcomeSkippedSize   = "skipped_size"
+	OutcomeSplit         = "split"
+	OutcomeTruncated     = "truncated"
+)
+
+// CopyOutcome records what happened to an object. Objects skipped before
+// any destination is considered have no destination.
+type CopyOutcome struct {
+	URI         string `json:"uri"`
+	Destination string `json:"destination,omitempty"`
+	Key         string `json:"key,omitempty"`
+	Status      string `json:"status"`
+	// RuleID identifies the override rule which modified the copy, and
+	// RuleSet the set containing it.
+	RuleID  string `json:"ruleId,omitempty"`
+	RuleSet string `json:"ruleSet,omitempty"`
+	// ContentType and ContentEncoding are only set if overridden.
+	// Otherwise the destination retains the source object metadata.
+	ContentType     string `json:"contentType,omitempty"`
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	Bytes           int64  `json:"bytes,omitempty"`
+	// Parts is the number of ranges an oversized object was forwarded in.
+	Parts          int   `json:"parts,omitempty"`
+	DurationMillis int64 `json:"durationMs,omitempty"`
 }
 
 type CopyRecord struct {
-	URI  string `json:"uri"`
-	Size *int64 `json:"size,omitempty"`
+	URI       string `json:"uri"`
+	Size      *int64 `json:"size,omitempty"`
+	ETag      string `json:"etag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	// EventTime is the time of the event which announced the object.
+	EventTime *time.Time `json:"eventTime,omitempty"`
+	// Destinations restricts which destinations an object is copied to.
+	// If empty, the object is copied to all destinations.
+	Destinations []string `json:"destinations,omitempty"`
+}
+
+// HasDestination verifies if object should be copied to destination.
+func (c *CopyRecord) HasDestination(destination string) bool {
+	if len(c.Destinations) == 0 {
+		return true
+	}
+	for _, d := range c.Destinations {
+		if d == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// FailedCopyRecord is a copy record which could not be processed, as written
+// to the error archive.
+type FailedCopyRecord struct {
+	CopyRecord
+	MessageID    string    `json:"messageId"`
+	ErrorMessage string    `json:"error"`
+	ErrorClass   string    `json:"errorClass"`
+	Attempts     int       `json:"attempts"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// ErrorClass summarizes an error for filtering purposes.
+func ErrorClass(err error) string {
+	var (
+		apiErr    smithy.APIError
+		statusErr interface{ HTTPStatusCode() int }
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.ErrorCode()
+	case errors.As(err, &statusErr) && statusErr.HTTPStatusCode() != 0:
+		return fmt.Sprintf("HTTP%d", statusErr.HTTPStatusCode())
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	case errors.Is(err, ErrObjectTooLarge):
+		return "ObjectTooLarge"
+	default:
+		return "Unknown"
+	}
+}
+
+// retryableError is implemented by destination errors which know whether a
+// retry may succeed, such as HTTP request failures.
+type retryableError interface {
+	Retryable() bool
+}
+
+// retryDelayError is implemented by errors which carry the delay requested
+// by a destination before retrying, e.g. through a Retry-After header.
+type retryDelayError interface {
+	RetryDelay() time.Duration
+}
+
+// retryDelay returns the longest delay requested by any failed copy.
+func retryDelay(err error) (delay time.Duration) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			delay = max(delay, retryDelay(err))
+		}
+		return delay
+	}
+	var e retryDelayError
+	if errors.As(err, &e) {
+		return e.RetryDelay()
+	}
+	return 0
+}
+
+// isRejectedCopyError verifies if destinations rejected every failed copy
+// in a way that retrying cannot fix, e.g. due to a malformed request.
+func isRejectedCopyError(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		for _, err := range errs {
+			if !isRejectedCopyError(err) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	}
+	var retryable retryableError
+	return errors.As(err, &retryable) && !retryable.Retryable()
 }
 
 type CopyEvent struct {
@@ -36,6 +194,10 @@ func GetObjectCreated(m *events.SQSMessage) (copyRecords []CopyRecord) {
 		copyRecords = append(copyRecords, processS3Event(message)...)
 	}
 
+	if len(copyRecords) == 0 {
+		copyRecords = append(copyRecords, processEventBridgeEvent(message)...)
+	}
+
 	if len(copyRecords) == 0 {
 		copyRecords = append(copyRecords, processCopyEvent(message)...)
 	}
@@ -53,7 +215,16 @@ func processS3Event(message []byte) (copyRecords []CopyRecord) {
 				uri := fmt.Sprintf("s3://%s/%s", record.S3.Bucket.Name, record.S3.Object.Key)
 
 				// Initialize a CopyRecord with URI
-				copyRecord := CopyRecord{URI: uri}
+				copyRecord := CopyRecord{
+					URI:       uri,
+					ETag:      record.S3.Object.ETag,
+					VersionID: record.S3.Object.VersionID,
+				}
+
+				if !record.EventTime.IsZero() {
+					eventTime := record.EventTime
+					copyRecord.EventTime = &eventTime
+				}
 
 				// Only set Size if it's present in the S3 event
 				if record.S3.Object.Size != 0 {
@@ -69,13 +240,61 @@ func processS3Event(message []byte) (copyRecords []CopyRecord) {
 	return
 }
 
+// S3EventBridgeDetail is the detail of an S3 "Object Created" event
+// delivered through Amazon EventBridge.
+type S3EventBridgeDetail struct {
+	Bucket struct {
+		Name string `json:"name"`
+	} `json:"bucket"`
+	Object struct {
+		Key       string `json:"key"`
+		Size      int64  `json:"size"`
+		ETag      string `json:"etag"`
+		VersionID string `json:"version-id"`
+	} `json:"object"`
+}
+
+func processEventBridgeEvent(message []byte) (copyRecords []CopyRecord) {
+	var event events.EventBridgeEvent
+	if err := json.Unmarshal(message, &event); err != nil {
+		return
+	}
+
+	if event.Source != "aws.s3" || event.DetailType != "Object Created" {
+		return
+	}
+
+	var detail S3EventBridgeDetail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil || detail.Bucket.Name == "" {
+		return
+	}
+
+	copyRecord := CopyRecord{
+		URI:       fmt.Sprintf("s3://%s/%s", detail.Bucket.Name, detail.Object.Key),
+		ETag:      detail.Object.ETag,
+		VersionID: detail.Object.VersionID,
+	}
+
+	if !event.Time.IsZero() {
+		eventTime := event.Time
+		copyRecord.EventTime = &eventTime
+	}
+
+	if detail.Object.Size != 0 {
+		size := detail.Object.Size
+		copyRecord.Size = &size
+	}
+
+	return append(copyRecords, copyRecord)
+}
+
 func processCopyEvent(message []byte) (copyRecords []CopyRecord) {
 	var copyEvent CopyEvent
 	err := json.Unmarshal(message, &copyEvent)
 
 	if err == nil {
 		for _, record := range copyEvent.Copy {
-			copyRecords = append(copyRecords, CopyRecord{URI: record.URI, Size: record.Size})
+			copyRecords = append(copyRecords, record)
 		}
 	}
 
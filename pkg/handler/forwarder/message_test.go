@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
 
@@ -15,6 +16,15 @@ func pointerToInt64(val int64) *int64 {
 	return &val
 }
 
+func pointerToTime(t *testing.T, s string) *time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &v
+}
+
 func TestObjectCreated(t *testing.T) {
 	t.Parallel()
 
@@ -41,8 +51,11 @@ func TestObjectCreated(t *testing.T) {
 			}`,
 			Expected: []forwarder.CopyRecord{
 				{
-					URI:  "s3://my-bucket/test.json",
-					Size: pointerToInt64(16),
+					URI:       "s3://my-bucket/test.json",
+					Size:      pointerToInt64(16),
+					ETag:      "ed818579e8cee1d812a77f19efa5e56a",
+					VersionID: "B4uqIbhdKYPsdJ.MkIjfpH5cOzj7332h",
+					EventTime: pointerToTime(t, "2023-09-19T02:49:21.921Z"),
 				},
 			},
 		},
@@ -67,8 +80,10 @@ func TestObjectCreated(t *testing.T) {
 			}`,
 			Expected: []forwarder.CopyRecord{
 				{
-					URI:  "s3://my-bucket/test.json",
-					Size: pointerToInt64(25),
+					URI:       "s3://my-bucket/test.json",
+					Size:      pointerToInt64(25),
+					ETag:      "d0b8560f261410878a68bbe070d81853",
+					EventTime: pointerToTime(t, "2023-09-27T23:16:10.232Z"),
 				},
 			},
 		},
@@ -123,6 +138,43 @@ func TestObjectCreated(t *testing.T) {
 				},
 			},
 		},
+		{
+			Message: `
+			{
+			  "attributes": {
+				"ApproximateFirstReceiveTimestamp": "1713373525337",
+				"ApproximateReceiveCount": "1",
+				"SenderId": "AIDAJXNJGGKNS7OSV23OI",
+				"SentTimestamp": "1713373525324"
+			  },
+			  "awsRegion": "us-west-2",
+			  "body": "{\"version\":\"0\",\"id\":\"17793124-05d4-b198-2fde-7ededc63b103\",\"detail-type\":\"Object Created\",\"source\":\"aws.s3\",\"account\":\"123456789012\",\"time\":\"2024-04-17T17:05:25Z\",\"region\":\"us-west-2\",\"resources\":[\"arn:aws:s3:::my-bucket\"],\"detail\":{\"version\":\"0\",\"bucket\":{\"name\":\"my-bucket\"},\"object\":{\"key\":\"AWSLogs/test.json\",\"size\":5,\"etag\":\"b1946ac92492d2347c6235b4d2611184\",\"version-id\":\"IYV3p45BT0ac8hjHg1houSdS1a.Mro8e\",\"sequencer\":\"00617F08299329D189\"},\"request-id\":\"N4N7GDK58NMKJ12R\",\"requester\":\"123456789012\",\"source-ip-address\":\"1.2.3.4\",\"reason\":\"PutObject\"}}",
+			  "eventSource": "aws:sqs",
+			  "eventSourceARN": "arn:aws:sqs:us-west-2:123456789012:my-queue",
+			  "md5OfBody": "e2c5b6a5f8c2d04b31c0e8e7e3a4d54c",
+			  "md5OfMessageAttributes": "",
+			  "messageAttributes": {},
+			  "messageId": "7a5e4e1c-7b8a-4b63-a1c7-8b5a0dc0f7a1"
+			}`,
+			Expected: []forwarder.CopyRecord{
+				{
+					URI:       "s3://my-bucket/AWSLogs/test.json",
+					Size:      pointerToInt64(5),
+					ETag:      "b1946ac92492d2347c6235b4d2611184",
+					VersionID: "IYV3p45BT0ac8hjHg1houSdS1a.Mro8e",
+					EventTime: pointerToTime(t, "2024-04-17T17:05:25Z"),
+				},
+			},
+		},
+		{
+			// EventBridge events for other detail types are ignored
+			Message: `
+			{
+			  "body": "{\"version\":\"0\",\"id\":\"17793124-05d4-b198-2fde-7ededc63b103\",\"detail-type\":\"Object Deleted\",\"source\":\"aws.s3\",\"account\":\"123456789012\",\"time\":\"2024-04-17T17:05:25Z\",\"region\":\"us-west-2\",\"resources\":[\"arn:aws:s3:::my-bucket\"],\"detail\":{\"version\":\"0\",\"bucket\":{\"name\":\"my-bucket\"},\"object\":{\"key\":\"test.json\"}}}",
+			  "eventSource": "aws:sqs",
+			  "messageId": "7a5e4e1c-7b8a-4b63-a1c7-8b5a0dc0f7a1"
+			}`,
+		},
 	}
 
 	for i, tc := range testcases {
@@ -0,0 +1,252 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http"
+)
+
+const (
+	// maxCopyObjectSize is the largest object S3 copies in a single request.
+	maxCopyObjectSize int64 = 5 * 1024 * 1024 * 1024
+
+	multipartPartSize    int64 = 512 * 1024 * 1024
+	multipartMaxParts    int64 = 10000
+	multipartConcurrency       = 8
+)
+
+var (
+	ErrInvalidMultipartThreshold = errors.New("invalid multipart threshold")
+
+	errMultipartETag = errors.New("missing part ETag")
+)
+
+// MultipartCopyAPIClient copies objects in parts. Destination clients which
+// implement it are used to copy objects too large for CopyObject. HeadObject
+// is only used to read source metadata if no source clients are configured.
+type MultipartCopyAPIClient interface {
+	HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	CreateMultipartUpload(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPartCopy(context.Context, *s3.UploadPartCopyInput, ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+	CompleteMultipartUpload(context.Context, *s3.CompleteMultipartUploadInput, ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// copyObject copies an object to a destination. Objects with a known size
+// above the multipart threshold are copied in parts if the destination
+// client supports it. Objects read through an assumed role cannot be copied
+// server side, and are instead read and written by the forwarder.
+//
+// It returns the number of bytes copied. Server side copies do not report
+// how much was written, and are assumed to copy the object in full.
+func (h *Handler) copyObject(ctx context.Context, destination *Destination, copyInput *s3.CopyObjectInput, copyRecord *CopyRecord) (int64, error) {
+	if destination.URI.Scheme == "s3" && h.Sources != nil {
+		if bucket, _, err := parseCopySource(aws.ToString(copyInput.CopySource)); err == nil && h.Sources.Role(bucket) != nil {
+			return h.copyThrough(ctx, destination.S3Client, copyInput, copyRecord)
+		}
+	}
+
+	size := copyRecord.Size
+	multipartClient, ok := destination.S3Client.(MultipartCopyAPIClient)
+	if !ok || size == nil || *size <= h.MultipartThreshold {
+		out, err := destination.S3Client.CopyObject(ctx, copyInput)
+		if err != nil {
+			return 0, err
+		}
+		if n, ok := s3http.BytesCopied(out); ok {
+			return n, nil
+		}
+		return aws.ToInt64(size), nil
+	}
+	var sourceClient s3.HeadObjectAPIClient = multipartClient
+	if h.Sources != nil {
+		sourceClient = h.Sources
+	}
+	if err := multipartCopy(ctx, multipartClient, sourceClient, copyInput, *size); err != nil {
+		return 0, err
+	}
+	return *size, nil
+}
+
+// multipartCopy copies an object through parallel UploadPartCopy requests.
+// Source metadata is read through sourceClient, which may differ in region
+// and credentials from the destination client. The upload is aborted if any
+// part fails.
+func multipartCopy(ctx context.Context, client MultipartCopyAPIClient, sourceClient s3.HeadObjectAPIClient, input *s3.CopyObjectInput, size int64) (err error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	createInput, err := toCreateMultipartUploadInput(ctx, sourceClient, input)
+	if err != nil {
+		return err
+	}
+
+	upload, err := client.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		// abort even if the context was cancelled, so that parts are not retained
+		if _, abortErr := client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   input.Bucket,
+			Key:      input.Key,
+			UploadId: upload.UploadId,
+		}); abortErr != nil {
+			logger.Error(abortErr, "failed to abort multipart upload", "uploadId", aws.ToString(upload.UploadId))
+		}
+	}()
+
+	// S3 limits the number of parts per upload
+	partSize := max(multipartPartSize, (size+multipartMaxParts-1)/multipartMaxParts)
+	parts := make([]types.CompletedPart, (size+partSize-1)/partSize)
+
+	logger.V(3).Info("copying object in parts", "key", aws.ToString(input.Key), "size", size, "parts", len(parts))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(multipartConcurrency)
+	for i := range parts {
+		partNumber := aws.Int32(int32(i + 1))
+		start := int64(i) * partSize
+		end := min(start+partSize, size) - 1
+
+		g.Go(func() error {
+			output, err := client.UploadPartCopy(gctx, &s3.UploadPartCopyInput{
+				Bucket:                         input.Bucket,
+				Key:                            input.Key,
+				UploadId:                       upload.UploadId,
+				PartNumber:                     partNumber,
+				CopySource:                     input.CopySource,
+				CopySourceRange:                aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+				CopySourceIfMatch:              input.CopySourceIfMatch,
+				CopySourceSSECustomerAlgorithm: input.CopySourceSSECustomerAlgorithm,
+				CopySourceSSECustomerKey:       input.CopySourceSSECustomerKey,
+				CopySourceSSECustomerKeyMD5:    input.CopySourceSSECustomerKeyMD5,
+				ExpectedBucketOwner:            input.ExpectedBucketOwner,
+				ExpectedSourceBucketOwner:      input.ExpectedSourceBucketOwner,
+				RequestPayer:                   input.RequestPayer,
+				SSECustomerAlgorithm:           input.SSECustomerAlgorithm,
+				SSECustomerKey:                 input.SSECustomerKey,
+				SSECustomerKeyMD5:              input.SSECustomerKeyMD5,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to copy part %d: %w", *partNumber, err)
+			}
+			if output.CopyPartResult == nil || output.CopyPartResult.ETag == nil {
+				return fmt.Errorf("failed to copy part %d: %w", *partNumber, errMultipartETag)
+			}
+			parts[i] = types.CompletedPart{
+				ETag:       output.CopyPartResult.ETag,
+				PartNumber: partNumber,
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	if _, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          input.Bucket,
+		Key:             input.Key,
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		RequestPayer:    input.RequestPayer,
+	}); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// toCreateMultipartUploadInput converts a copy request into a multipart upload.
+// Unlike CopyObject, multipart uploads do not inherit source metadata, so
+// metadata is retrieved from the source unless it is being replaced.
+func toCreateMultipartUploadInput(ctx context.Context, client s3.HeadObjectAPIClient, input *s3.CopyObjectInput) (*s3.CreateMultipartUploadInput, error) {
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:                    input.Bucket,
+		Key:                       input.Key,
+		ACL:                       input.ACL,
+		BucketKeyEnabled:          input.BucketKeyEnabled,
+		CacheControl:              input.CacheControl,
+		ContentDisposition:        input.ContentDisposition,
+		ContentEncoding:           input.ContentEncoding,
+		ContentLanguage:           input.ContentLanguage,
+		ContentType:               input.ContentType,
+		ExpectedBucketOwner:       input.ExpectedBucketOwner,
+		Expires:                   input.Expires,
+		GrantFullControl:          input.GrantFullControl,
+		GrantRead:                 input.GrantRead,
+		GrantReadACP:              input.GrantReadACP,
+		GrantWriteACP:             input.GrantWriteACP,
+		Metadata:                  input.Metadata,
+		ObjectLockLegalHoldStatus: input.ObjectLockLegalHoldStatus,
+		ObjectLockMode:            input.ObjectLockMode,
+		ObjectLockRetainUntilDate: input.ObjectLockRetainUntilDate,
+		RequestPayer:              input.RequestPayer,
+		SSECustomerAlgorithm:      input.SSECustomerAlgorithm,
+		SSECustomerKey:            input.SSECustomerKey,
+		SSECustomerKeyMD5:         input.SSECustomerKeyMD5,
+		SSEKMSEncryptionContext:   input.SSEKMSEncryptionContext,
+		SSEKMSKeyId:               input.SSEKMSKeyId,
+		ServerSideEncryption:      input.ServerSideEncryption,
+		StorageClass:              input.StorageClass,
+		Tagging:                   input.Tagging,
+		WebsiteRedirectLocation:   input.WebsiteRedirectLocation,
+	}
+
+	if input.MetadataDirective == types.MetadataDirectiveReplace {
+		return createInput, nil
+	}
+
+	bucket, key, err := parseCopySource(aws.ToString(input.CopySource))
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		ExpectedBucketOwner:  input.ExpectedSourceBucketOwner,
+		RequestPayer:         input.RequestPayer,
+		SSECustomerAlgorithm: input.CopySourceSSECustomerAlgorithm,
+		SSECustomerKey:       input.CopySourceSSECustomerKey,
+		SSECustomerKeyMD5:    input.CopySourceSSECustomerKeyMD5,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source metadata: %w", err)
+	}
+
+	createInput.CacheControl = head.CacheControl
+	createInput.ContentDisposition = head.ContentDisposition
+	createInput.ContentEncoding = head.ContentEncoding
+	createInput.ContentLanguage = head.ContentLanguage
+	createInput.ContentType = head.ContentType
+	createInput.Metadata = head.Metadata
+	return createInput, nil
+}
+
+// parseCopySource reverses the encoding applied by GetCopyObjectInput.
+func parseCopySource(copySource string) (bucket, key string, err error) {
+	bucket, escapedKey, ok := strings.Cut(copySource, "/")
+	if !ok {
+		return "", "", fmt.Errorf("invalid copy source %q", copySource)
+	}
+	key, err = url.QueryUnescape(escapedKey)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid copy source %q: %w", copySource, err)
+	}
+	return bucket, key, nil
+}
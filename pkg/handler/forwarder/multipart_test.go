@@ -0,0 +1,232 @@
+package forwarder_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+const gib = 1024 * 1024 * 1024
+
+// fakeMultipartS3 records calls made during a multipart copy.
+type fakeMultipartS3 struct {
+	sync.Mutex
+	FailPart int32
+
+	Copied    int
+	Created   *s3.CreateMultipartUploadInput
+	Ranges    []string
+	Completed *s3.CompleteMultipartUploadInput
+	Aborted   bool
+}
+
+func (f *fakeMultipartS3) Client() *awstest.S3Client {
+	return &awstest.S3Client{
+		CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			f.Lock()
+			defer f.Unlock()
+			f.Copied++
+			return &s3.CopyObjectOutput{}, nil
+		},
+		HeadObjectFunc: func(_ context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			if key := aws.ToString(input.Key); key != "path/to/big file.log" {
+				return nil, fmt.Errorf("unexpected key %q", key)
+			}
+			return &s3.HeadObjectOutput{
+				ContentType: aws.String("text/plain"),
+				Metadata:    map[string]string{"origin": "source"},
+			}, nil
+		},
+		CreateMultipartUploadFunc: func(_ context.Context, input *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			f.Lock()
+			defer f.Unlock()
+			f.Created = input
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil
+		},
+		UploadPartCopyFunc: func(_ context.Context, input *s3.UploadPartCopyInput, _ ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+			if aws.ToInt32(input.PartNumber) == f.FailPart {
+				return nil, errSentinel
+			}
+			f.Lock()
+			defer f.Unlock()
+			f.Ranges = append(f.Ranges, aws.ToString(input.CopySourceRange))
+			return &s3.UploadPartCopyOutput{
+				CopyPartResult: &s3types.CopyPartResult{
+					ETag: aws.String(fmt.Sprintf("etag-%d", aws.ToInt32(input.PartNumber))),
+				},
+			}, nil
+		},
+		CompleteMultipartUploadFunc: func(_ context.Context, input *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			f.Lock()
+			defer f.Unlock()
+			f.Completed = input
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+		AbortMultipartUploadFunc: func(_ context.Context, _ *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+			f.Lock()
+			defer f.Unlock()
+			f.Aborted = true
+			return &s3.AbortMultipartUploadOutput{}, nil
+		},
+	}
+}
+
+func processCopy(t *testing.T, client forwarder.S3Client, size int64) error {
+	t.Helper()
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination/prefix",
+		S3Client:       client,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h.ProcessRecord(context.Background(), &forwarder.SQSMessage{
+		SQSMessage: events.SQSMessage{
+			Body: fmt.Sprintf(`{"copy": [{"uri": "s3://source/path/to/big%%20file.log", "size": %d}]}`, size),
+		},
+	})
+}
+
+func TestMultipartCopy(t *testing.T) {
+	t.Parallel()
+
+	var fake fakeMultipartS3
+	size := int64(12*gib + 1)
+	if err := processCopy(t, fake.Client(), size); err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.Copied != 0 {
+		t.Error("unexpected CopyObject call")
+	}
+
+	if fake.Aborted {
+		t.Error("unexpected abort")
+	}
+
+	if diff := cmp.Diff(fake.Created.Metadata, map[string]string{"origin": "source"}); diff != "" {
+		t.Error("unexpected metadata", diff)
+	}
+
+	if ct := aws.ToString(fake.Created.ContentType); ct != "text/plain" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	// parts must cover the whole object without overlap
+	var offset int64
+	ranges := make(map[int64]int64, len(fake.Ranges))
+	for _, r := range fake.Ranges {
+		var start, end int64
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil {
+			t.Fatal(err)
+		}
+		ranges[start] = end
+	}
+	for len(ranges) > 0 {
+		end, ok := ranges[offset]
+		if !ok {
+			t.Fatalf("missing range at offset %d", offset)
+		}
+		delete(ranges, offset)
+		offset = end + 1
+	}
+	if offset != size {
+		t.Fatalf("expected ranges to cover %d bytes, got %d", size, offset)
+	}
+
+	parts := fake.Completed.MultipartUpload.Parts
+	if len(parts) != 25 {
+		t.Fatalf("unexpected number of parts: %d", len(parts))
+	}
+	if !sort.SliceIsSorted(parts, func(i, j int) bool {
+		return aws.ToInt32(parts[i].PartNumber) < aws.ToInt32(parts[j].PartNumber)
+	}) {
+		t.Error("parts must be in ascending order")
+	}
+	for i, part := range parts {
+		if etag := aws.ToString(part.ETag); etag != fmt.Sprintf("etag-%d", i+1) {
+			t.Errorf("unexpected ETag for part %d: %q", i+1, etag)
+		}
+	}
+}
+
+func TestMultipartCopyAbort(t *testing.T) {
+	t.Parallel()
+
+	fake := fakeMultipartS3{FailPart: 3}
+	if err := processCopy(t, fake.Client(), 6*gib); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !fake.Aborted {
+		t.Error("expected upload to be aborted")
+	}
+
+	if fake.Completed != nil {
+		t.Error("unexpected complete")
+	}
+}
+
+func TestMultipartCopyThreshold(t *testing.T) {
+	t.Parallel()
+
+	var fake fakeMultipartS3
+	if err := processCopy(t, fake.Client(), 5*gib); err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.Copied != 1 || fake.Created != nil {
+		t.Error("expected a single CopyObject call")
+	}
+}
+
+func TestMultipartCopySourceClients(t *testing.T) {
+	t.Parallel()
+
+	sources, reads, _ := newSourceClients(t, nil, map[string]string{"source": "eu-west-1"})
+
+	var fake fakeMultipartS3
+	client := fake.Client()
+	client.HeadObjectFunc = func(_ context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		t.Error("source metadata must not be read through the destination client")
+		return nil, errSentinel
+	}
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination/prefix",
+		S3Client:       client,
+		Sources:        sources,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.ProcessRecord(context.Background(), &forwarder.SQSMessage{
+		SQSMessage: events.SQSMessage{
+			Body: fmt.Sprintf(`{"copy": [{"uri": "s3://source/path/to/big%%20file.log", "size": %d}]}`, 6*gib),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(reads, map[string]sourceClientKey{"source/path/to/big file.log": {Region: "eu-west-1"}}); diff != "" {
+		t.Error("unexpected reads", diff)
+	}
+
+	if ct := aws.ToString(fake.Created.ContentType); ct != "text/plain" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+}
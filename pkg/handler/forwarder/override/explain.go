@@ -0,0 +1,47 @@
+package override
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Explanation describes the effect of applying rules to an object.
+type Explanation struct {
+	Source          string       `json:"source"`
+	Matches         []*RuleMatch `json:"matches"`
+	ContentType     string       `json:"contentType,omitempty"`
+	ContentEncoding string       `json:"contentEncoding,omitempty"`
+	Destination     string       `json:"destination,omitempty"`
+	Key             string       `json:"key,omitempty"`
+	Ignored         bool         `json:"ignored,omitempty"`
+}
+
+// Explain applies sets to input, and reports which rules matched alongside
+// the resulting copy input. The input is modified in place.
+func Explain(ctx context.Context, sets Sets, input *s3.CopyObjectInput) *Explanation {
+	source, err := url.QueryUnescape(aws.ToString(input.CopySource))
+	if err != nil {
+		source = aws.ToString(input.CopySource)
+	}
+
+	var result Result
+	modified := sets.Apply(NewContext(ctx, &result), input)
+
+	matches := result.Matches
+	if matches == nil {
+		matches = []*RuleMatch{}
+	}
+
+	return &Explanation{
+		Source:          source,
+		Matches:         matches,
+		ContentType:     aws.ToString(input.ContentType),
+		ContentEncoding: aws.ToString(input.ContentEncoding),
+		Destination:     result.Destination,
+		Key:             aws.ToString(input.Key),
+		Ignored:         modified && input.Key == nil,
+	}
+}
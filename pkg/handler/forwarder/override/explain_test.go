@@ -0,0 +1,101 @@
+package override_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	rules := trimLeadingWhitespace(`
+	---
+	rules:
+	  - id: security
+	    match:
+	      source: '/CloudTrail/'
+	    override:
+	      destination: 'security'
+	  - id: ignoreDigest
+	    match:
+	      source: '/CloudTrail-Digest/'
+	    override:
+	      content-type: 'text/x-ignore'
+	`)
+
+	var custom override.Set
+	if err := yaml.Unmarshal([]byte(rules), &custom); err != nil {
+		t.Fatal(err)
+	}
+	custom.Name = "custom"
+	custom.Logger = logr.Discard()
+
+	presets, err := override.LoadPresets(logr.Discard(), "aws/v1", "infer/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets := append(override.Sets{&custom}, presets...)
+
+	testcases := []struct {
+		CopySource string
+		Expect     *override.Explanation
+	}{
+		{
+			CopySource: "bucket/AWSLogs/123456789012/CloudTrail/us-west-2/2024/03/07/123456789012_CloudTrail_us-west-2_20240307T1735Z_avVctZJaEJudp7oI.json.gz",
+			Expect: &override.Explanation{
+				Source: "bucket/AWSLogs/123456789012/CloudTrail/us-west-2/2024/03/07/123456789012_CloudTrail_us-west-2_20240307T1735Z_avVctZJaEJudp7oI.json.gz",
+				Matches: []*override.RuleMatch{
+					{Set: "custom", RuleID: "security", Continue: false},
+					{Set: "aws/v1", RuleID: "cloudtrail", Modified: true},
+				},
+				ContentType: "application/x-aws-cloudtrail",
+				Destination: "security",
+				Key:         "key",
+			},
+		},
+		{
+			CopySource: "bucket/AWSLogs/123456789012/CloudTrail-Digest/us-west-2/file.json.gz",
+			Expect: &override.Explanation{
+				Source: "bucket/AWSLogs/123456789012/CloudTrail-Digest/us-west-2/file.json.gz",
+				Matches: []*override.RuleMatch{
+					{Set: "custom", RuleID: "ignoreDigest", Modified: true},
+				},
+				Ignored: true,
+			},
+		},
+		{
+			CopySource: "bucket/data.json.gz",
+			Expect: &override.Explanation{
+				Source: "bucket/data.json.gz",
+				Matches: []*override.RuleMatch{
+					{Set: "infer/v1", RuleID: "gzip", Modified: true, Continue: true},
+					{Set: "infer/v1", RuleID: "json", Modified: true},
+				},
+				ContentType:     "application/json",
+				ContentEncoding: "gzip",
+				Key:             "key",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.CopySource, func(t *testing.T) {
+			t.Parallel()
+			input := &s3.CopyObjectInput{
+				CopySource: aws.String(tc.CopySource),
+				Key:        aws.String("key"),
+			}
+			if diff := cmp.Diff(override.Explain(context.Background(), sets, input), tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package override
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var (
+	rePlaceholder  = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+	errKeyTemplate = errors.New("invalid key template")
+	errCapture     = errors.New("unknown capture group")
+
+	keyPlaceholders = []string{"bucket", "key", "dirname", "basename", "yyyy", "mm", "dd", "hh"}
+)
+
+// KeyTemplate is a destination key containing placeholders in braces, e.g.
+// "{account}/{region}/{yyyy}/{mm}/{dd}/{basename}".
+//
+// Placeholders refer to groups captured by the source pattern, either by
+// name or by index, or to one of the following values:
+//   - bucket: the source bucket
+//   - key: the source key
+//   - dirname: the source key, excluding the final path element
+//   - basename: the final path element of the source key
+//   - yyyy, mm, dd, hh: the date and hour the object was created, in UTC
+//
+// Captured groups take precedence over all other values.
+type KeyTemplate string
+
+// Validate verifies all placeholders can be resolved. Placeholders may refer
+// to groups in source by name or index, and to named groups in patterns.
+func (k KeyTemplate) Validate(source *regexp.Regexp, patterns ...*regexp.Regexp) error {
+	if strings.Trim(string(k), "/") == "" {
+		return fmt.Errorf("%w: empty key", errKeyTemplate)
+	}
+
+	known := make(map[string]struct{})
+	for _, name := range keyPlaceholders {
+		known[name] = struct{}{}
+	}
+	if source != nil {
+		for i, name := range source.SubexpNames() {
+			known[strconv.Itoa(i)] = struct{}{}
+			if name != "" {
+				known[name] = struct{}{}
+			}
+		}
+	}
+	for name := range captureNames(patterns...) {
+		known[name] = struct{}{}
+	}
+
+	for _, match := range rePlaceholder.FindAllStringSubmatch(string(k), -1) {
+		if _, ok := known[match[1]]; !ok {
+			return fmt.Errorf("%w: unknown placeholder %q", errKeyTemplate, match[0])
+		}
+	}
+	return nil
+}
+
+// Expand returns the destination key for input. The destination path
+// preceding the source key in input is preserved. Date placeholders are
+// expanded from t.
+func (k KeyTemplate) Expand(input *s3.CopyObjectInput, captures map[string]string, t time.Time) (string, bool) {
+	copySource := aws.ToString(input.CopySource)
+	if v, err := url.QueryUnescape(copySource); err == nil {
+		copySource = v
+	}
+	bucket, key, ok := strings.Cut(copySource, "/")
+	if !ok {
+		return "", false
+	}
+
+	dirname := path.Dir(key)
+	if dirname == "." {
+		dirname = ""
+	}
+
+	t = t.UTC()
+	values := map[string]string{
+		"bucket":   bucket,
+		"key":      key,
+		"dirname":  dirname,
+		"basename": path.Base(key),
+		"yyyy":     t.Format("2006"),
+		"mm":       t.Format("01"),
+		"dd":       t.Format("02"),
+		"hh":       t.Format("15"),
+	}
+	maps.Copy(values, captures)
+
+	expanded := strings.TrimLeft(expand(string(k), values), "/")
+	if expanded == "" {
+		return "", false
+	}
+
+	prefix := strings.TrimSuffix(aws.ToString(input.Key), key)
+	return prefix + expanded, true
+}
+
+// usesTime verifies if the template refers to date placeholders which are not
+// captured by a pattern.
+func (k KeyTemplate) usesTime(captures map[string]string) bool {
+	for _, match := range rePlaceholder.FindAllStringSubmatch(string(k), -1) {
+		switch match[1] {
+		case "yyyy", "mm", "dd", "hh":
+			if _, ok := captures[match[1]]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// objectTime returns the creation time of the object stored in context, or
+// the current time if there is none.
+func objectTime(ctx context.Context) time.Time {
+	if o := ObjectFromContext(ctx); o != nil {
+		return o.Time(ctx)
+	}
+	return time.Now()
+}
+
+func expand(s string, values map[string]string) string {
+	return rePlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		return values[placeholder[1:len(placeholder)-1]]
+	})
+}
+
+// expandCaptures replaces placeholders in s with named capture groups.
+// Values without placeholders are returned unmodified.
+func expandCaptures(s string, captures map[string]string) string {
+	if !rePlaceholder.MatchString(s) {
+		return s
+	}
+	return expand(s, captures)
+}
+
+func captureNames(patterns ...*regexp.Regexp) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, re := range patterns {
+		if re == nil {
+			continue
+		}
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				names[name] = struct{}{}
+			}
+		}
+	}
+	return names
+}
+
+// validateCaptures verifies all placeholders in s refer to named groups in
+// patterns.
+func validateCaptures(s string, patterns ...*regexp.Regexp) error {
+	names := captureNames(patterns...)
+	for _, match := range rePlaceholder.FindAllStringSubmatch(s, -1) {
+		if _, ok := names[match[1]]; !ok {
+			return fmt.Errorf("%w: %q", errCapture, match[0])
+		}
+	}
+	return nil
+}
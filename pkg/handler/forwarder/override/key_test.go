@@ -0,0 +1,181 @@
+package override_test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	t.Parallel()
+
+	rules := trimLeadingWhitespace(`
+	---
+	rules:
+	  - id: cloudtrail
+	    match:
+	      source: '^[^/]+/AWSLogs/(?P<account>\d+)/CloudTrail/(?P<region>[^/]+)/(?P<yyyy>\d{4})/(?P<mm>\d{2})/(?P<dd>\d{2})/'
+	    override:
+	      key: '{account}/{region}/{yyyy}/{mm}/{dd}/{basename}'
+	  - id: bucket
+	    match:
+	      source: '^logs-([^/]+)/'
+	    override:
+	      key: '/by-team/{1}/{key}'
+	  - id: json
+	    match:
+	      source: '\.json$'
+	    override:
+	      content-type: 'application/x-ndjson'
+	`)
+
+	var set override.Set
+	if err := yaml.Unmarshal([]byte(rules), &set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		CopySource string
+		Key        string
+		Expect     string
+		Modified   bool
+	}{
+		{
+			CopySource: "trail/AWSLogs/123456789012/CloudTrail/us-east-1/2024/01/02/file%2B1.json.gz",
+			Key:        "archive/AWSLogs/123456789012/CloudTrail/us-east-1/2024/01/02/file+1.json.gz",
+			Expect:     "archive/123456789012/us-east-1/2024/01/02/file+1.json.gz",
+		},
+		{
+			// key rewrites do not prevent later rules from applying
+			CopySource: "logs-security/path/to/file.json",
+			Key:        "path/to/file.json",
+			Expect:     "by-team/security/path/to/file.json",
+			Modified:   true,
+		},
+		{
+			CopySource: "other/file.log",
+			Key:        "archive/file.log",
+			Expect:     "archive/file.log",
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+			input := &s3.CopyObjectInput{
+				CopySource: aws.String(tc.CopySource),
+				Key:        aws.String(tc.Key),
+			}
+			var result override.Result
+			modified := set.Apply(override.NewContext(context.Background(), &result), input)
+			if modified != tc.Modified {
+				t.Errorf("expected modified to be %t", tc.Modified)
+			}
+			if diff := cmp.Diff(aws.ToString(input.Key), tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestKeyTemplateTime(t *testing.T) {
+	t.Parallel()
+
+	var set override.Set
+	if err := yaml.Unmarshal([]byte(trimLeadingWhitespace(`
+	---
+	rules:
+	  - match:
+	      source: '\.log$'
+	    override:
+	      key: '{yyyy}/{mm}/{dd}/{hh}/{basename}'
+	`)), &set); err != nil {
+		t.Fatal(err)
+	}
+
+	eventTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", -5*3600))
+	lastModified := time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		Object *override.Object
+		Expect string
+	}{
+		{
+			// event time is preferred, and converted to UTC
+			Object: &override.Object{
+				EventTime: &eventTime,
+				HeadObject: func(context.Context) (*s3.HeadObjectOutput, error) {
+					panic("unexpected HeadObject")
+				},
+			},
+			Expect: "2024/01/02/08/file.log",
+		},
+		{
+			Object: &override.Object{
+				HeadObject: func(context.Context) (*s3.HeadObjectOutput, error) {
+					return &s3.HeadObjectOutput{LastModified: &lastModified}, nil
+				},
+			},
+			Expect: "2023/12/31/23/file.log",
+		},
+		{
+			Object: &override.Object{
+				Now: func() time.Time { return eventTime },
+			},
+			Expect: "2024/01/02/08/file.log",
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+			input := &s3.CopyObjectInput{
+				CopySource: aws.String("bucket/path/file.log"),
+				Key:        aws.String("path/file.log"),
+			}
+			set.Apply(override.NewObjectContext(context.Background(), tc.Object), input)
+			if diff := cmp.Diff(aws.ToString(input.Key), tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestKeyTemplateValidate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Source      string
+		Template    override.KeyTemplate
+		ExpectError bool
+	}{
+		{Template: "{bucket}/{yyyy}/{mm}/{dd}/{hh}/{basename}"},
+		{Source: `^(?P<account>\d+)/`, Template: "{account}/{dirname}/{0}"},
+		{Source: `^(\d+)/`, Template: "{2}", ExpectError: true},
+		{Template: "{account}/{key}", ExpectError: true},
+		{Template: "/", ExpectError: true},
+	}
+
+	for _, tc := range testcases {
+		var source *regexp.Regexp
+		if tc.Source != "" {
+			source = regexp.MustCompile(tc.Source)
+		}
+		err := tc.Template.Validate(source)
+		if (err != nil) != tc.ExpectError {
+			t.Errorf("%q: unexpected error: %v", tc.Template, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package override
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// IssueKind classifies problems found by Lint.
+type IssueKind string
+
+const (
+	// IssueUnreachable indicates a rule or set can never be applied.
+	IssueUnreachable IssueKind = "unreachable"
+	// IssueOverlap indicates rules match the same objects.
+	IssueOverlap IssueKind = "overlap"
+	// IssueNoEffect indicates a rule does not override anything.
+	IssueNoEffect IssueKind = "no-effect"
+)
+
+// Issue describes a problem with a rule or set.
+type Issue struct {
+	Kind    IssueKind `json:"kind"`
+	Set     string    `json:"set"`
+	RuleID  string    `json:"ruleId,omitempty"`
+	Message string    `json:"message"`
+}
+
+func (i *Issue) String() string {
+	s := fmt.Sprintf("%s: set %q", i.Kind, i.Set)
+	if i.RuleID != "" {
+		s += fmt.Sprintf(" rule %q", i.RuleID)
+	}
+	return s + ": " + i.Message
+}
+
+// Lint reports rules which are unreachable, overlap with other rules, or
+// have no effect. Overlap is only detected for rules with identical filters,
+// and rules are only deemed unreachable if preceded by a terminal rule with
+// an identical filter, by a terminal rule which only matches a source prefix
+// that the rule's source extends, or by a terminal rule which matches all
+// objects.
+func Lint(sets Sets) (issues []*Issue) {
+	for si, set := range sets {
+		setName := set.Name
+		if setName == "" {
+			setName = fmt.Sprintf("%d", si)
+		}
+
+		type prior struct {
+			id       string
+			terminal bool
+		}
+		type prefixRule struct {
+			id     string
+			prefix string
+		}
+		var (
+			rules    = set.rules()
+			seen     = make(map[string]prior, len(rules))
+			prefixes []prefixRule
+			catchAll string
+		)
+
+		// shadowedBy returns a prior terminal rule matching a prefix of
+		// every source matched by re.
+		shadowedBy := func(re *regexp.Regexp) (prefixRule, bool) {
+			if re == nil {
+				return prefixRule{}, false
+			}
+			prefix, _, ok := anchoredPrefix(re)
+			if !ok {
+				return prefixRule{}, false
+			}
+			for _, p := range prefixes {
+				if strings.HasPrefix(prefix, p.prefix) {
+					return p, true
+				}
+			}
+			return prefixRule{}, false
+		}
+
+		for i, rule := range rules {
+			id := rule.ID
+			if id == "" {
+				id = fmt.Sprintf("%d", i)
+			}
+			issue := func(kind IssueKind, format string, args ...any) {
+				issues = append(issues, &Issue{Kind: kind, Set: setName, RuleID: id, Message: fmt.Sprintf(format, args...)})
+			}
+
+			if catchAll != "" {
+				issue(IssueUnreachable, "rule %q matches all objects", catchAll)
+				continue
+			}
+
+			if rule.Override.isEmpty() && !isNoop(rule) {
+				issue(IssueNoEffect, "rule has no override")
+			}
+
+			terminal := rule.Override.modifies() && !rule.Continue
+
+			key := rule.Match.String()
+			prev, ok := seen[key]
+			if !ok || !prev.terminal {
+				if shadow, found := shadowedBy(rule.Match.Source); found {
+					issue(IssueUnreachable, "shadowed by rule %q matching source prefix %q", shadow.id, shadow.prefix)
+					continue
+				}
+			}
+
+			switch {
+			case ok && prev.terminal:
+				issue(IssueUnreachable, "shadowed by rule %q with identical filter", prev.id)
+			case ok:
+				issue(IssueOverlap, "identical filter to rule %q", prev.id)
+				seen[key] = prior{id: id, terminal: terminal}
+			default:
+				seen[key] = prior{id: id, terminal: terminal}
+			}
+
+			if terminal && rule.Match.matchesAll() {
+				catchAll = id
+			}
+			if prefix, ok := rule.Match.sourcePrefix(); ok && terminal {
+				prefixes = append(prefixes, prefixRule{id: id, prefix: prefix})
+			}
+		}
+
+		if catchAll != "" && si < len(sets)-1 {
+			for sj, next := range sets[si+1:] {
+				nextName := next.Name
+				if nextName == "" {
+					nextName = fmt.Sprintf("%d", si+1+sj)
+				}
+				issues = append(issues, &Issue{
+					Kind:    IssueUnreachable,
+					Set:     nextName,
+					Message: fmt.Sprintf("rule %q in set %q matches all objects", catchAll, setName),
+				})
+			}
+			return issues
+		}
+	}
+	return issues
+}
+
+func (a *Action) isEmpty() bool {
+	return a.ContentType == nil && a.ContentEncoding == nil && a.Destination == nil && a.Key == nil
+}
+
+// modifies reports whether applying the action terminates rule evaluation.
+func (a *Action) modifies() bool {
+	return a.ContentType != nil || a.ContentEncoding != nil
+}
+
+// isNoop identifies rules parsed from empty text values.
+func isNoop(r *Rule) bool {
+	return r.Match.Source != nil && r.Match.Source.String() == "^$" && r.Override.isEmpty()
+}
+
+// String returns a canonical representation of the filter.
+func (f *Filter) String() string {
+	var parts []string
+	add := func(name string, re *regexp.Regexp) {
+		if re != nil {
+			parts = append(parts, fmt.Sprintf("%s=%q", name, re.String()))
+		}
+	}
+	add("source", f.Source)
+	add("content-type", f.ContentType)
+	add("content-encoding", f.ContentEncoding)
+	add("storage-class", f.StorageClass)
+	for _, k := range sortedKeys(f.Metadata) {
+		add("metadata."+strings.ToLower(k), f.Metadata[k])
+	}
+	for _, k := range sortedKeys(f.Tags) {
+		add("tags."+k, f.Tags[k])
+	}
+	if f.Size != nil {
+		if f.Size.Min != nil {
+			parts = append(parts, fmt.Sprintf("size.min=%d", *f.Size.Min))
+		}
+		if f.Size.Max != nil {
+			parts = append(parts, fmt.Sprintf("size.max=%d", *f.Size.Max))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func sortedKeys(m map[string]*regexp.Regexp) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sourcePrefix returns the literal prefix of the source pattern if the filter
+// matches every object whose source begins with it, e.g. "^logs/".
+func (f *Filter) sourcePrefix() (string, bool) {
+	if f.Source == nil {
+		return "", false
+	}
+	rest := *f
+	rest.Source = nil
+	if !rest.matchesAll() {
+		return "", false
+	}
+	prefix, tail, ok := anchoredPrefix(f.Source)
+	if !ok || !matchesAllSyntax(tail) {
+		return "", false
+	}
+	return prefix, true
+}
+
+// anchoredPrefix returns the case sensitive literal which a regular
+// expression requires at the start of the input, and the remainder of the
+// expression.
+func anchoredPrefix(re *regexp.Regexp) (string, *syntax.Regexp, bool) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", nil, false
+	}
+	parsed = parsed.Simplify()
+	if parsed.Op != syntax.OpConcat || len(parsed.Sub) < 2 {
+		return "", nil, false
+	}
+	begin, literal := parsed.Sub[0], parsed.Sub[1]
+	if begin.Op != syntax.OpBeginText && begin.Op != syntax.OpBeginLine {
+		return "", nil, false
+	}
+	if literal.Op != syntax.OpLiteral || literal.Flags&syntax.FoldCase != 0 {
+		return "", nil, false
+	}
+	tail := &syntax.Regexp{Op: syntax.OpEmptyMatch}
+	if rest := parsed.Sub[2:]; len(rest) > 0 {
+		tail = &syntax.Regexp{Op: syntax.OpConcat, Sub: rest}
+	}
+	return string(literal.Rune), tail, true
+}
+
+// matchesAll reports whether the filter matches any object.
+func (f *Filter) matchesAll() bool {
+	for _, re := range []*regexp.Regexp{f.Source, f.ContentType, f.ContentEncoding, f.StorageClass} {
+		if re != nil && !matchesAll(re) {
+			return false
+		}
+	}
+	for _, m := range []map[string]*regexp.Regexp{f.Metadata, f.Tags} {
+		for _, re := range m {
+			if !matchesAll(re) {
+				return false
+			}
+		}
+	}
+	return f.Size == nil || (f.Size.Min == nil && f.Size.Max == nil)
+}
+
+// matchesAll reports whether a regular expression trivially matches all
+// strings, e.g. "", ".*" or "^.*$". Newlines are disregarded.
+func matchesAll(re *regexp.Regexp) bool {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return false
+	}
+	return matchesAllSyntax(parsed.Simplify())
+}
+
+func matchesAllSyntax(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return true
+	case syntax.OpCapture:
+		return matchesAllSyntax(re.Sub[0])
+	case syntax.OpStar:
+		return isAnyChar(re.Sub[0])
+	case syntax.OpConcat:
+		var wildcard bool
+		for _, sub := range re.Sub {
+			switch {
+			case sub.Op == syntax.OpBeginText || sub.Op == syntax.OpBeginLine:
+				if wildcard {
+					return false
+				}
+			case sub.Op == syntax.OpEndText || sub.Op == syntax.OpEndLine:
+				if !wildcard {
+					return false
+				}
+			case matchesAllSyntax(sub):
+				wildcard = true
+			default:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isAnyChar(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpCapture {
+		return isAnyChar(re.Sub[0])
+	}
+	return re.Op == syntax.OpAnyChar || re.Op == syntax.OpAnyCharNotNL
+}
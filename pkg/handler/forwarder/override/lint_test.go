@@ -0,0 +1,107 @@
+package override_test
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	rules := trimLeadingWhitespace(`
+	---
+	rules:
+	  - id: json
+	    match:
+	      source: '\.json$'
+	    override:
+	      content-type: 'application/json'
+	  - id: jsonAgain
+	    match:
+	      source: '\.json$'
+	    override:
+	      content-type: 'application/x-ndjson'
+	  - id: gzip
+	    match:
+	      source: '\.gz$'
+	    override:
+	      content-encoding: 'gzip'
+	    continue: true
+	  - id: gzipAgain
+	    match:
+	      source: '\.gz$'
+	    override:
+	      content-type: 'text/plain'
+	  - id: logs
+	    match:
+	      source: '^logs/'
+	    override:
+	      content-type: 'text/plain'
+	  - id: logsJSON
+	    match:
+	      source: '^logs/(.*)\.json$'
+	    override:
+	      content-type: 'application/json'
+	  - id: logsPrefix
+	    match:
+	      source: '^log'
+	    override:
+	      content-type: 'text/plain'
+	  - id: noop
+	    match:
+	      source: 'txt'
+	  - id: everything
+	    match:
+	      source: '^(.*)$'
+	    override:
+	      content-type: 'text/plain'
+	  - id: after
+	    match:
+	      source: 'csv'
+	    override:
+	      content-type: 'text/csv'
+	`)
+
+	var custom override.Set
+	if err := yaml.Unmarshal([]byte(rules), &custom); err != nil {
+		t.Fatal(err)
+	}
+	custom.Name = "custom"
+
+	presets, err := override.LoadPresets(logr.Discard(), "aws/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []*override.Issue{
+		{Kind: override.IssueUnreachable, Set: "custom", RuleID: "jsonAgain", Message: `shadowed by rule "json" with identical filter`},
+		{Kind: override.IssueOverlap, Set: "custom", RuleID: "gzipAgain", Message: `identical filter to rule "gzip"`},
+		{Kind: override.IssueUnreachable, Set: "custom", RuleID: "logsJSON", Message: `shadowed by rule "logs" matching source prefix "logs/"`},
+		{Kind: override.IssueNoEffect, Set: "custom", RuleID: "noop", Message: "rule has no override"},
+		{Kind: override.IssueUnreachable, Set: "custom", RuleID: "after", Message: `rule "everything" matches all objects`},
+		{Kind: override.IssueUnreachable, Set: "aws/v1", Message: `rule "everything" in set "custom" matches all objects`},
+	}
+
+	if diff := cmp.Diff(override.Lint(append(override.Sets{&custom}, presets...)), expect); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestLintPresets(t *testing.T) {
+	t.Parallel()
+
+	for _, preset := range []string{"aws/v1", "aws/v2"} {
+		sets, err := override.LoadPresets(logr.Discard(), preset, "infer/v1", "tags/v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, issue := range override.Lint(sets) {
+			t.Error(issue)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package override
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type objectKey struct{}
+
+// Object provides attributes of the source object which are not part of the
+// copy request. Attributes are retrieved only when a rule requires them, and
+// at most once per object.
+type Object struct {
+	// Size of the object, if known in advance.
+	Size *int64
+	// EventTime is the time of the event which announced the object, if
+	// known in advance.
+	EventTime *time.Time
+	// Now returns the current time. It is only used if neither the event
+	// time nor the last modified time of the object are available.
+	Now func() time.Time
+	// HeadObject retrieves object metadata.
+	HeadObject func(context.Context) (*s3.HeadObjectOutput, error)
+	// GetObjectTagging retrieves object tags.
+	GetObjectTagging func(context.Context) (*s3.GetObjectTaggingOutput, error)
+
+	headOnce sync.Once
+	head     *s3.HeadObjectOutput
+	headErr  error
+
+	tagsOnce sync.Once
+	tags     map[string]string
+	tagsErr  error
+}
+
+// NewObjectContext returns a context which provides object attributes to rules.
+func NewObjectContext(ctx context.Context, o *Object) context.Context {
+	return context.WithValue(ctx, objectKey{}, o)
+}
+
+// ObjectFromContext returns the object stored in context, if any.
+func ObjectFromContext(ctx context.Context) *Object {
+	o, _ := ctx.Value(objectKey{}).(*Object)
+	return o
+}
+
+func (o *Object) headObject(ctx context.Context) (*s3.HeadObjectOutput, error) {
+	o.headOnce.Do(func() {
+		if o.HeadObject == nil {
+			o.headErr = fmt.Errorf("%w: metadata", errUnavailable)
+			return
+		}
+		o.head, o.headErr = o.HeadObject(ctx)
+		if o.headErr == nil && o.head == nil {
+			o.head = &s3.HeadObjectOutput{}
+		}
+	})
+	return o.head, o.headErr
+}
+
+// Metadata returns the user metadata of the object. Keys are lowercase.
+func (o *Object) Metadata(ctx context.Context) (map[string]string, error) {
+	head, err := o.headObject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]string, len(head.Metadata))
+	for k, v := range head.Metadata {
+		metadata[strings.ToLower(k)] = v
+	}
+	return metadata, nil
+}
+
+// StorageClass returns the storage class of the object.
+func (o *Object) StorageClass(ctx context.Context) (string, error) {
+	head, err := o.headObject(ctx)
+	if err != nil {
+		return "", err
+	}
+	if head.StorageClass == "" {
+		// HEAD omits the storage class for standard objects
+		return string(types.StorageClassStandard), nil
+	}
+	return string(head.StorageClass), nil
+}
+
+// ContentLength returns the size of the object in bytes.
+func (o *Object) ContentLength(ctx context.Context) (int64, error) {
+	if o.Size != nil {
+		return *o.Size, nil
+	}
+	head, err := o.headObject(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return aws.ToInt64(head.ContentLength), nil
+}
+
+// Tags returns the tags set on the object.
+func (o *Object) Tags(ctx context.Context) (map[string]string, error) {
+	o.tagsOnce.Do(func() {
+		if o.GetObjectTagging == nil {
+			o.tagsErr = fmt.Errorf("%w: tags", errUnavailable)
+			return
+		}
+		output, err := o.GetObjectTagging(ctx)
+		if err != nil {
+			o.tagsErr = err
+			return
+		}
+		o.tags = make(map[string]string)
+		if output != nil {
+			for _, tag := range output.TagSet {
+				o.tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		}
+	})
+	return o.tags, o.tagsErr
+}
+
+// Time returns the time the object was created. The event time is preferred,
+// followed by the last modified time of the object and the current time.
+func (o *Object) Time(ctx context.Context) time.Time {
+	if o.EventTime != nil {
+		return *o.EventTime
+	}
+	if head, err := o.headObject(ctx); err == nil && head.LastModified != nil {
+		return *head.LastModified
+	}
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
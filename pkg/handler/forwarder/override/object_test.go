@@ -0,0 +1,290 @@
+package override_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gopkg.in/yaml.v3"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+)
+
+// fakeObject counts requests made to retrieve object attributes.
+type fakeObject struct {
+	Size         *int64
+	Length       int64
+	StorageClass types.StorageClass
+	Metadata     map[string]string
+	Tags         map[string]string
+	Err          error
+
+	Heads, Taggings atomic.Int32
+}
+
+func (f *fakeObject) Object() *override.Object {
+	return &override.Object{
+		Size: f.Size,
+		HeadObject: func(context.Context) (*s3.HeadObjectOutput, error) {
+			f.Heads.Add(1)
+			if f.Err != nil {
+				return nil, f.Err
+			}
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(f.Length),
+				StorageClass:  f.StorageClass,
+				Metadata:      f.Metadata,
+			}, nil
+		},
+		GetObjectTagging: func(context.Context) (*s3.GetObjectTaggingOutput, error) {
+			f.Taggings.Add(1)
+			if f.Err != nil {
+				return nil, f.Err
+			}
+			var output s3.GetObjectTaggingOutput
+			for k, v := range f.Tags {
+				output.TagSet = append(output.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+			return &output, nil
+		},
+	}
+}
+
+func TestFilterObject(t *testing.T) {
+	t.Parallel()
+
+	rules := trimLeadingWhitespace(`
+	---
+	rules:
+	  - id: source
+	    match:
+	      source: '^logs/'
+	    override:
+	      content-type: 'text/plain'
+	  - id: large
+	    match:
+	      size:
+	        min: 1024
+	    override:
+	      content-type: 'application/x-large'
+	  - id: archived
+	    match:
+	      storage-class: '^(GLACIER|DEEP_ARCHIVE)$'
+	    override:
+	      content-type: 'text/x-ignore'
+	  - id: metadata
+	    match:
+	      metadata:
+	        Format: '^(?P<format>csv|json)$'
+	    override:
+	      content-type: 'text/{format}'
+	  - id: tags
+	    match:
+	      source: '\.log$'
+	      tags:
+	        team: '^security$'
+	        env: '^$'
+	    override:
+	      content-type: 'application/x-security'
+	`)
+
+	var set override.Set
+	if err := yaml.Unmarshal([]byte(rules), &set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		CopySource        string
+		Object            *fakeObject
+		ExpectContentType string
+		ExpectIgnored     bool
+		ExpectHeads       int32
+		ExpectTaggings    int32
+	}{
+		{
+			// object attributes are not retrieved for earlier matches
+			CopySource:        "logs/a.log",
+			Object:            &fakeObject{},
+			ExpectContentType: "text/plain",
+		},
+		{
+			// known size avoids a HEAD request
+			CopySource:        "bucket/a.log",
+			Object:            &fakeObject{Size: aws.Int64(2048)},
+			ExpectContentType: "application/x-large",
+		},
+		{
+			CopySource:        "bucket/a.log",
+			Object:            &fakeObject{Length: 2048},
+			ExpectContentType: "application/x-large",
+			ExpectHeads:       1,
+		},
+		{
+			CopySource:    "bucket/a.log",
+			Object:        &fakeObject{StorageClass: types.StorageClassGlacier},
+			ExpectIgnored: true,
+			ExpectHeads:   1,
+		},
+		{
+			CopySource:        "bucket/a.log",
+			Object:            &fakeObject{Metadata: map[string]string{"format": "csv"}},
+			ExpectContentType: "text/csv",
+			ExpectHeads:       1,
+		},
+		{
+			CopySource:        "bucket/a.log",
+			Object:            &fakeObject{Tags: map[string]string{"team": "security"}},
+			ExpectContentType: "application/x-security",
+			ExpectHeads:       1,
+			ExpectTaggings:    1,
+		},
+		{
+			CopySource:     "bucket/a.log",
+			Object:         &fakeObject{Tags: map[string]string{"team": "security", "env": "dev"}},
+			ExpectHeads:    1,
+			ExpectTaggings: 1,
+		},
+		{
+			// tags are not retrieved unless the remaining filter matches
+			CopySource:  "bucket/a.json",
+			Object:      &fakeObject{Tags: map[string]string{"team": "security"}},
+			ExpectHeads: 1,
+		},
+		{
+			// errors retrieving attributes are treated as a mismatch
+			CopySource:     "bucket/a.log",
+			Object:         &fakeObject{Err: errors.New("access denied")},
+			ExpectHeads:    1,
+			ExpectTaggings: 1,
+		},
+		{
+			// rules requiring attributes never match without an object
+			CopySource: "bucket/a.log",
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+			input := &s3.CopyObjectInput{
+				CopySource: aws.String(tc.CopySource),
+				Key:        aws.String("key"),
+			}
+
+			ctx := context.Background()
+			if tc.Object != nil {
+				ctx = override.NewObjectContext(ctx, tc.Object.Object())
+			}
+			set.Apply(ctx, input)
+
+			if ignored := input.Key == nil; ignored != tc.ExpectIgnored {
+				t.Errorf("expected ignored to be %t", tc.ExpectIgnored)
+			}
+			if diff := cmp.Diff(aws.ToString(input.ContentType), tc.ExpectContentType); diff != "" {
+				t.Error("unexpected content type", diff)
+			}
+			if tc.Object == nil {
+				return
+			}
+			if n := tc.Object.Heads.Load(); n != tc.ExpectHeads {
+				t.Errorf("expected %d HEAD requests, got %d", tc.ExpectHeads, n)
+			}
+			if n := tc.Object.Taggings.Load(); n != tc.ExpectTaggings {
+				t.Errorf("expected %d tagging requests, got %d", tc.ExpectTaggings, n)
+			}
+		})
+	}
+}
+
+func TestFilterObjectValidate(t *testing.T) {
+	t.Parallel()
+
+	rules := trimLeadingWhitespace(`
+	---
+	rules:
+	  - match:
+	      tags:
+	        type: '^(?P<contentType>.+)$'
+	    override:
+	      content-type: '{type}'
+	`)
+
+	var set override.Set
+	if err := yaml.Unmarshal([]byte(rules), &set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Validate(); err == nil {
+		t.Fatal("expected error for unknown capture group")
+	}
+}
+
+func TestTagsPreset(t *testing.T) {
+	t.Parallel()
+
+	sets, err := override.LoadPresets(logr.Discard(), "tags/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		Tags   map[string]string
+		Expect *s3.CopyObjectInput
+	}{
+		{
+			Tags: map[string]string{"observe:ignore": "true"},
+			Expect: &s3.CopyObjectInput{
+				CopySource: aws.String("bucket/key"),
+			},
+		},
+		{
+			Tags: map[string]string{
+				"observe:content-type":     "application/x-ndjson",
+				"observe:content-encoding": "gzip",
+			},
+			Expect: &s3.CopyObjectInput{
+				CopySource:        aws.String("bucket/key"),
+				Key:               aws.String("key"),
+				ContentType:       aws.String("application/x-ndjson"),
+				ContentEncoding:   aws.String("gzip"),
+				MetadataDirective: types.MetadataDirectiveReplace,
+			},
+		},
+		{
+			Tags: map[string]string{"observe:content-type": "invalid"},
+			Expect: &s3.CopyObjectInput{
+				CopySource: aws.String("bucket/key"),
+				Key:        aws.String("key"),
+			},
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+			object := &fakeObject{Tags: tc.Tags}
+			input := &s3.CopyObjectInput{
+				CopySource: aws.String("bucket/key"),
+				Key:        aws.String("key"),
+			}
+			override.Sets(sets).Apply(override.NewObjectContext(context.Background(), object.Object()), input)
+			if diff := cmp.Diff(input, tc.Expect, cmpopts.IgnoreUnexported(s3.CopyObjectInput{})); diff != "" {
+				t.Error(diff)
+			}
+			// tags are retrieved once across all rules
+			if n := object.Taggings.Load(); n != 1 {
+				t.Errorf("expected 1 tagging request, got %d", n)
+			}
+		})
+	}
+}
@@ -67,6 +67,7 @@ func LoadPresets(logger logr.Logger, names ...string) (ss []*Set, err error) {
 		}
 
 		ss = append(ss, &Set{
+			Name:   name,
 			Logger: logger.WithValues("set", name),
 			Rules:  rules,
 		})
@@ -122,6 +122,39 @@ func TestPresets(t *testing.T) {
 						MetadataDirective: types.MetadataDirectiveReplace,
 					},
 				},
+				{
+					Input: &s3.CopyObjectInput{
+						CopySource: aws.String("test-bucket/hohoho.json.zst"),
+					},
+					Expect: &s3.CopyObjectInput{
+						CopySource:        aws.String("test-bucket/hohoho.json.zst"),
+						ContentType:       aws.String("application/json"),
+						ContentEncoding:   aws.String("zstd"),
+						MetadataDirective: types.MetadataDirectiveReplace,
+					},
+				},
+				{
+					Input: &s3.CopyObjectInput{
+						CopySource: aws.String("test-bucket/hohoho.json.bz2"),
+					},
+					Expect: &s3.CopyObjectInput{
+						CopySource:        aws.String("test-bucket/hohoho.json.bz2"),
+						ContentType:       aws.String("application/json"),
+						ContentEncoding:   aws.String("bzip2"),
+						MetadataDirective: types.MetadataDirectiveReplace,
+					},
+				},
+				{
+					Input: &s3.CopyObjectInput{
+						CopySource: aws.String("test-bucket/hohoho.json.sz"),
+					},
+					Expect: &s3.CopyObjectInput{
+						CopySource:        aws.String("test-bucket/hohoho.json.sz"),
+						ContentType:       aws.String("application/json"),
+						ContentEncoding:   aws.String("snappy"),
+						MetadataDirective: types.MetadataDirectiveReplace,
+					},
+				},
 				{
 					Input: &s3.CopyObjectInput{
 						CopySource:  aws.String("test-bucket/hohoho.parquet"),
@@ -156,3 +189,88 @@ func TestPresets(t *testing.T) {
 		})
 	}
 }
+
+func TestPresetsAWSv2(t *testing.T) {
+	t.Parallel()
+
+	ss, err := override.LoadPresets(logr.Discard(), "aws/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		CopySource            string
+		ExpectContentType     string
+		ExpectContentEncoding string
+	}{
+		{
+			// rules from aws/v1 are retained
+			CopySource:            "test-bucket/AWSLogs/123456789012/CloudTrail/us-west-2/2024/03/07/123456789012_CloudTrail_us-west-2_20240307T1735Z_avVctZJaEJudp7oI.json.gz",
+			ExpectContentType:     "application/x-aws-cloudtrail",
+			ExpectContentEncoding: "",
+		},
+		{
+			CopySource:            "test-bucket/AWSLogs/123456789012/elasticloadbalancing/us-west-2/2024/05/23/123456789012_elasticloadbalancing_us-west-2_ac5f85808922711e98f8e02481e016be_20240523T0015Z_127.69.70.85_5cpyxp74.log.gz",
+			ExpectContentType:     "application/x-aws-elasticloadbalancing",
+			ExpectContentEncoding: "gzip",
+		},
+		{
+			CopySource:            "test-bucket/AWSLogs/123456789012/elasticloadbalancing/us-east-2/2023/10/04/conn_log.123456789012_elasticloadbalancing_us-east-2_app.my-loadbalancer.1234567890abcdef_20231004T1720Z_198.51.100.1_5cpyxp74.log.gz",
+			ExpectContentType:     "application/x-aws-alb-connection",
+			ExpectContentEncoding: "gzip",
+		},
+		{
+			CopySource:            "test-bucket/AWSLogs/123456789012/WAFLogs/us-east-1/TEST-WEBACL/2021/10/28/19/50/123456789012_waflogs_us-east-1_TEST-WEBACL_20211028T1950Z_e0ca43b5.log.gz",
+			ExpectContentType:     "application/x-aws-waf",
+			ExpectContentEncoding: "gzip",
+		},
+		{
+			CopySource:            "test-bucket/cloudfront/E2K2LNL5N3WR51.2024-01-01-00.a1b2c3d4.gz",
+			ExpectContentType:     "application/x-aws-cloudfront",
+			ExpectContentEncoding: "gzip",
+		},
+		{
+			CopySource:        "test-bucket/logs/2024-01-01-00-16-39-4E7A8D1C7B2F4A1B",
+			ExpectContentType: "application/x-aws-s3access",
+		},
+		{
+			CopySource:        "test-bucket/logs/123456789012/us-east-1/source-bucket/2024/01/01/2024-01-01-00-16-39-4E7A8D1C7B2F4A1B",
+			ExpectContentType: "application/x-aws-s3access",
+		},
+		{
+			CopySource:            "test-bucket/AWSLogs/123456789012/vpcdnsquerylogs/vpc-0123456789abcdef0/2024/01/01/123456789012_vpcdnsquerylogs_vpc-0123456789abcdef0_20240101T0005Z_9b1b75d1.log.gz",
+			ExpectContentType:     "application/x-aws-route53resolver",
+			ExpectContentEncoding: "gzip",
+		},
+		{
+			CopySource:            "test-bucket/AWSLogs/123456789012/network-firewall/alert/2024/01/01/00/123456789012_network-firewall_alert_us-east-1_my-firewall_202401010005_9b1b75d1.log.gz",
+			ExpectContentType:     "application/x-aws-networkfirewall",
+			ExpectContentEncoding: "gzip",
+		},
+		{
+			CopySource:            "test-bucket/AWSLogs/123456789012/GuardDuty/us-east-1/2024/01/01/0b1c2d3e-4f5a-6b7c-8d9e-0f1a2b3c4d5e.jsonl.gz",
+			ExpectContentType:     "application/x-aws-guardduty",
+			ExpectContentEncoding: "gzip",
+		},
+		{
+			CopySource:        "test-bucket/SecurityHub/2024/01/01/00/findings-1-2024-01-01-00-00-00-0b1c2d3e-4f5a-6b7c-8d9e-0f1a2b3c4d5e",
+			ExpectContentType: "application/x-aws-securityhub",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.CopySource, func(t *testing.T) {
+			t.Parallel()
+			input := &s3.CopyObjectInput{
+				CopySource: aws.String(tc.CopySource),
+			}
+			override.Sets(ss).Apply(context.Background(), input)
+			if diff := cmp.Diff(aws.ToString(input.ContentType), tc.ExpectContentType); diff != "" {
+				t.Error("unexpected content type", diff)
+			}
+			if diff := cmp.Diff(aws.ToString(input.ContentEncoding), tc.ExpectContentEncoding); diff != "" {
+				t.Error("unexpected content encoding", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package override
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"gopkg.in/yaml.v3"
+)
+
+// maxRuleSetSize bounds the size of a rule set retrieved from S3.
+const maxRuleSetSize = 1024 * 1024
+
+var (
+	errUnsupportedScheme = errors.New("unsupported scheme")
+	errMalformedURI      = errors.New("malformed URI")
+	errMissingClient     = errors.New("missing client")
+	errRuleSetSize       = fmt.Errorf("rule set exceeds %d bytes", maxRuleSetSize)
+	errEmptyParameter    = errors.New("empty parameter")
+)
+
+type GetObjectAPIClient interface {
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+type GetParameterAPIClient interface {
+	GetParameter(context.Context, *ssm.GetParameterInput, ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// Loader retrieves a YAML encoded rule set.
+type Loader func(context.Context) ([]byte, error)
+
+// NewLoader returns a Loader for a rule set stored in an S3 object
+// (s3://bucket/key) or SSM parameter (ssm://name, or ssm:///path/to/name).
+func NewLoader(uri string, s3Client GetObjectAPIClient, ssmClient GetParameterAPIClient) (Loader, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", uri, err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		if s3Client == nil {
+			return nil, fmt.Errorf("%q: %w", uri, errMissingClient)
+		}
+		bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+		if bucket == "" || key == "" {
+			return nil, fmt.Errorf("%q: %w", uri, errMalformedURI)
+		}
+		return func(ctx context.Context) ([]byte, error) {
+			output, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(key),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get object: %w", err)
+			}
+			defer output.Body.Close()
+
+			data, err := io.ReadAll(io.LimitReader(output.Body, maxRuleSetSize+1))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read object: %w", err)
+			}
+			if len(data) > maxRuleSetSize {
+				return nil, errRuleSetSize
+			}
+			return data, nil
+		}, nil
+	case "ssm":
+		if ssmClient == nil {
+			return nil, fmt.Errorf("%q: %w", uri, errMissingClient)
+		}
+		name := strings.TrimPrefix(uri, "ssm://")
+		if name == "" {
+			return nil, fmt.Errorf("%q: %w", uri, errMalformedURI)
+		}
+		return func(ctx context.Context) ([]byte, error) {
+			output, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+				Name:           aws.String(name),
+				WithDecryption: aws.Bool(true),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get parameter: %w", err)
+			}
+			if output.Parameter == nil || output.Parameter.Value == nil {
+				return nil, errEmptyParameter
+			}
+			return []byte(*output.Parameter.Value), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("%q: %w", uri, errUnsupportedScheme)
+	}
+}
+
+// ParseRules decodes and validates a YAML rule set, using the same format
+// as presets.
+func ParseRules(data []byte) ([]*Rule, error) {
+	var rules []*Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rules: %w", err)
+	}
+	if err := validateRules(rules); err != nil {
+		return nil, fmt.Errorf("failed to validate rules: %w", err)
+	}
+	return rules, nil
+}
+
+// RemoteSet is a rule set retrieved from an external source.
+type RemoteSet struct {
+	*Set
+	Load Loader
+	// TTL is the interval between refreshes. Zero disables refreshing.
+	TTL time.Duration
+	// Destinations lists the names of destinations rules may route objects
+	// to. Rule sets routing objects elsewhere are rejected. If nil,
+	// destinations are not verified.
+	Destinations []string
+
+	// data is the last successfully loaded rule set.
+	data []byte
+}
+
+// Refresh loads the latest version of the rule set. If the latest version
+// cannot be loaded or is invalid, the current rules are retained.
+func (r *RemoteSet) Refresh(ctx context.Context) error {
+	data, err := r.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load rule set %q: %w", r.Name, err)
+	}
+	if r.data != nil && bytes.Equal(data, r.data) {
+		return nil
+	}
+
+	rules, err := ParseRules(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse rule set %q: %w", r.Name, err)
+	}
+	if r.Destinations != nil {
+		if err := validateDestinations(rules, r.Destinations); err != nil {
+			return fmt.Errorf("failed to validate rule set %q: %w", r.Name, err)
+		}
+	}
+	r.Replace(rules)
+	r.data = data
+	r.Logger.V(3).Info("loaded rule set", "rules", len(rules))
+	return nil
+}
+
+// Run refreshes the rule set every TTL until the context is done. Errors
+// are logged, and the last valid rules remain in effect.
+func (r *RemoteSet) Run(ctx context.Context) {
+	if r.TTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.TTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				r.Logger.Error(err, "failed to refresh rule set, retaining previous rules")
+			}
+		}
+	}
+}
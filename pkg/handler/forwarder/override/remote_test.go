@@ -0,0 +1,233 @@
+package override_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+var (
+	validRules = trimLeadingWhitespace(`
+	- id: csv
+	  match:
+	    source: '\.csv$'
+	  override:
+	    content-type: 'text/csv'
+	`)
+	updatedRules = trimLeadingWhitespace(`
+	- id: json
+	  match:
+	    source: '\.json$'
+	  override:
+	    content-type: 'application/json'
+	`)
+	invalidRules = trimLeadingWhitespace(`
+	- id: invalid
+	  match:
+	    source: '\.json$'
+	  override:
+	    content-type: '{missing}'
+	`)
+)
+
+func TestNewLoader(t *testing.T) {
+	t.Parallel()
+
+	s3Client := &awstest.S3Client{
+		GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			body := fmt.Sprintf("%s/%s", aws.ToString(params.Bucket), aws.ToString(params.Key))
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+	}
+	ssmClient := &awstest.SSMClient{
+		GetParameterFunc: func(_ context.Context, params *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+			if !aws.ToBool(params.WithDecryption) {
+				return nil, errors.New("expected decryption")
+			}
+			return &ssm.GetParameterOutput{
+				Parameter: &ssmtypes.Parameter{Value: params.Name},
+			}, nil
+		},
+	}
+
+	testcases := []struct {
+		URI         string
+		Expect      string
+		ExpectError bool
+	}{
+		{
+			URI:    "s3://bucket/path/to/rules.yaml",
+			Expect: "bucket/path/to/rules.yaml",
+		},
+		{
+			URI:    "ssm://rules",
+			Expect: "rules",
+		},
+		{
+			URI:    "ssm:///observe/forwarder/rules",
+			Expect: "/observe/forwarder/rules",
+		},
+		{
+			URI:         "s3://bucket",
+			ExpectError: true,
+		},
+		{
+			URI:         "ssm://",
+			ExpectError: true,
+		},
+		{
+			URI:         "https://example.com/rules.yaml",
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.URI, func(t *testing.T) {
+			t.Parallel()
+			load, err := override.NewLoader(tc.URI, s3Client, ssmClient)
+			if (err != nil) != tc.ExpectError {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			data, err := load(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(data), tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRemoteSetRefresh(t *testing.T) {
+	t.Parallel()
+
+	var (
+		data    = validRules
+		loadErr error
+	)
+
+	set := &override.RemoteSet{
+		Set: &override.Set{
+			Name:   "s3://bucket/rules.yaml",
+			Logger: logr.Discard(),
+		},
+		Load: func(context.Context) ([]byte, error) {
+			return []byte(data), loadErr
+		},
+	}
+
+	contentType := func(source string) string {
+		input := &s3.CopyObjectInput{
+			CopySource: aws.String(source),
+			Key:        aws.String("key"),
+		}
+		set.Apply(context.Background(), input)
+		return aws.ToString(input.ContentType)
+	}
+
+	steps := []struct {
+		Data        string
+		LoadErr     error
+		ExpectError bool
+		ExpectCSV   string
+		ExpectJSON  string
+	}{
+		{
+			Data:      validRules,
+			ExpectCSV: "text/csv",
+		},
+		{
+			// invalid rules retain the last good set
+			Data:        invalidRules,
+			ExpectError: true,
+			ExpectCSV:   "text/csv",
+		},
+		{
+			LoadErr:     errors.New("access denied"),
+			ExpectError: true,
+			ExpectCSV:   "text/csv",
+		},
+		{
+			Data:       updatedRules,
+			ExpectJSON: "application/json",
+		},
+		{
+			Data:        "not: [a list",
+			ExpectError: true,
+			ExpectJSON:  "application/json",
+		},
+	}
+
+	for i, step := range steps {
+		data, loadErr = step.Data, step.LoadErr
+		err := set.Refresh(context.Background())
+		if (err != nil) != step.ExpectError {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if got := contentType("bucket/a.csv"); got != step.ExpectCSV {
+			t.Errorf("step %d: expected %q for csv, got %q", i, step.ExpectCSV, got)
+		}
+		if got := contentType("bucket/a.json"); got != step.ExpectJSON {
+			t.Errorf("step %d: expected %q for json, got %q", i, step.ExpectJSON, got)
+		}
+	}
+}
+
+func TestRemoteSetDestinations(t *testing.T) {
+	t.Parallel()
+
+	data := trimLeadingWhitespace(`
+	- id: archive
+	  match:
+	    source: '\.csv$'
+	  override:
+	    destination: archive
+	`)
+
+	set := &override.RemoteSet{
+		Set: &override.Set{
+			Name:   "ssm://rules",
+			Logger: logr.Discard(),
+		},
+		Load: func(context.Context) ([]byte, error) {
+			return []byte(data), nil
+		},
+		Destinations: []string{"archive"},
+	}
+	if err := set.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a refreshed rule set must not route objects to unknown destinations
+	data = strings.ReplaceAll(data, "destination: archive", "destination: missing")
+	err := set.Refresh(context.Background())
+	if !errors.Is(err, override.ErrUnknownDestination) {
+		t.Fatalf("expected unknown destination error, got %v", err)
+	}
+
+	var result override.Result
+	set.Apply(override.NewContext(context.Background(), &result), &s3.CopyObjectInput{
+		CopySource: aws.String("bucket/a.csv"),
+		Key:        aws.String("a.csv"),
+	})
+	if result.Destination != "archive" {
+		t.Errorf("expected previous rules to be retained, got destination %q", result.Destination)
+	}
+}
@@ -0,0 +1,42 @@
+package override
+
+import "context"
+
+type resultKey struct{}
+
+// Result records decisions taken by rules beyond modifying the copy input.
+type Result struct {
+	// Destination is the name of the destination selected by a rule.
+	Destination string
+	// Key is the destination key set by a rule.
+	Key string
+	// RuleID identifies the first rule which modified the copy input.
+	// Rules without an ID are identified by their index within a set.
+	RuleID string
+	// Set is the name of the set containing RuleID.
+	Set string
+	// Matches lists every rule which matched the copy input, in order of
+	// evaluation.
+	Matches []*RuleMatch
+}
+
+// RuleMatch describes a rule which matched the copy input.
+type RuleMatch struct {
+	Set    string `json:"set"`
+	RuleID string `json:"ruleId"`
+	// Modified is false for rules which only select a destination or key.
+	Modified bool `json:"modified"`
+	// Continue indicates evaluation proceeded to subsequent rules.
+	Continue bool `json:"continue"`
+}
+
+// NewContext returns a context which collects the result of applying rules.
+func NewContext(ctx context.Context, r *Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, r)
+}
+
+// ResultFromContext returns the result stored in context, if any.
+func ResultFromContext(ctx context.Context) *Result {
+	r, _ := ctx.Value(resultKey{}).(*Result)
+	return r
+}
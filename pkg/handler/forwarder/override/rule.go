@@ -7,17 +7,27 @@ import (
 	"net/url"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
 	"github.com/mitchellh/mapstructure"
 )
 
+// DestinationName matches names of destinations which rules route objects
+// to. Names start with a letter, followed by letters, digits, hyphens or
+// underscores.
+var DestinationName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
 var (
 	reIdentifier      = regexp.MustCompile("^([a-zA-Z][a-zA-Z0-9/]+)?$")
 	errIDFormat       = errors.New("malformed id")
+	errDestination    = errors.New("malformed destination")
+	errUnavailable    = errors.New("object attributes unavailable")
 	ignoreContentType = "text/x-ignore"
 )
 
@@ -26,10 +36,40 @@ type Filter struct {
 	Source          *regexp.Regexp `mapstructure:"source"`
 	ContentType     *regexp.Regexp `mapstructure:"content-type"`
 	ContentEncoding *regexp.Regexp `mapstructure:"content-encoding"`
+
+	// The following attributes are retrieved from the source object only if
+	// a rule requires them. Missing metadata keys and tags match as empty.
+	Metadata     map[string]*regexp.Regexp `mapstructure:"metadata"`
+	Tags         map[string]*regexp.Regexp `mapstructure:"tags"`
+	StorageClass *regexp.Regexp            `mapstructure:"storage-class"`
+	Size         *SizeRange                `mapstructure:"size"`
+}
+
+// SizeRange matches object sizes in bytes. Bounds are inclusive.
+type SizeRange struct {
+	Min *int64 `mapstructure:"min"`
+	Max *int64 `mapstructure:"max"`
+}
+
+func (r *SizeRange) contains(size int64) bool {
+	if r.Min != nil && size < *r.Min {
+		return false
+	}
+	if r.Max != nil && size > *r.Max {
+		return false
+	}
+	return true
 }
 
 // Match input object.
+// Filters on object attributes never match, since no object is available.
 func (f *Filter) Match(input *s3.CopyObjectInput) bool {
+	return f.MatchContext(context.Background(), input)
+}
+
+// MatchContext matches input object, retrieving object attributes through
+// the Object stored in context if needed.
+func (f *Filter) MatchContext(ctx context.Context, input *s3.CopyObjectInput) bool {
 	if f.Source != nil {
 		cs, _ := url.QueryUnescape(aws.ToString(input.CopySource))
 		if !f.Source.MatchString(cs) {
@@ -42,7 +82,129 @@ func (f *Filter) Match(input *s3.CopyObjectInput) bool {
 	if f.ContentEncoding != nil && !f.ContentEncoding.MatchString(aws.ToString(input.ContentEncoding)) {
 		return false
 	}
-	return true
+	if !f.requiresObject() {
+		return true
+	}
+
+	object := ObjectFromContext(ctx)
+	if object == nil {
+		return false
+	}
+	ok, err := f.matchObject(ctx, object)
+	if err != nil {
+		logr.FromContextOrDiscard(ctx).V(1).Info("failed to retrieve object attributes", "error", err.Error())
+		return false
+	}
+	return ok
+}
+
+func (f *Filter) requiresObject() bool {
+	return f.Size != nil || f.StorageClass != nil || len(f.Metadata) > 0 || len(f.Tags) > 0
+}
+
+// matchObject verifies object attributes, ordered such that we avoid
+// retrieving tags unless all other attributes match.
+func (f *Filter) matchObject(ctx context.Context, object *Object) (bool, error) {
+	if f.Size != nil {
+		size, err := object.ContentLength(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !f.Size.contains(size) {
+			return false, nil
+		}
+	}
+	if f.StorageClass != nil {
+		storageClass, err := object.StorageClass(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !f.StorageClass.MatchString(storageClass) {
+			return false, nil
+		}
+	}
+	if len(f.Metadata) > 0 {
+		metadata, err := object.Metadata(ctx)
+		if err != nil {
+			return false, err
+		}
+		for k, re := range f.Metadata {
+			if !re.MatchString(metadata[strings.ToLower(k)]) {
+				return false, nil
+			}
+		}
+	}
+	if len(f.Tags) > 0 {
+		tags, err := object.Tags(ctx)
+		if err != nil {
+			return false, err
+		}
+		for k, re := range f.Tags {
+			if !re.MatchString(tags[k]) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// captures returns the groups matched by the source pattern, keyed by both
+// name and index, and the named groups matched by metadata and tag patterns.
+func (f *Filter) captures(ctx context.Context, input *s3.CopyObjectInput) map[string]string {
+	captures := make(map[string]string)
+	if f.Source != nil {
+		cs, _ := url.QueryUnescape(aws.ToString(input.CopySource))
+		if match := f.Source.FindStringSubmatch(cs); match != nil {
+			for i, name := range f.Source.SubexpNames() {
+				captures[strconv.Itoa(i)] = match[i]
+				if name != "" {
+					captures[name] = match[i]
+				}
+			}
+		}
+	}
+
+	// object attributes have already been retrieved while matching
+	if object := ObjectFromContext(ctx); object != nil {
+		if len(f.Metadata) > 0 {
+			if metadata, err := object.Metadata(ctx); err == nil {
+				for k, re := range f.Metadata {
+					captureNamed(captures, re, metadata[strings.ToLower(k)])
+				}
+			}
+		}
+		if len(f.Tags) > 0 {
+			if tags, err := object.Tags(ctx); err == nil {
+				for k, re := range f.Tags {
+					captureNamed(captures, re, tags[k])
+				}
+			}
+		}
+	}
+	return captures
+}
+
+func captureNamed(captures map[string]string, re *regexp.Regexp, s string) {
+	match := re.FindStringSubmatch(s)
+	if match == nil {
+		return
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			captures[name] = match[i]
+		}
+	}
+}
+
+// patterns returns the patterns which may contribute named captures.
+func (f *Filter) patterns() (patterns []*regexp.Regexp) {
+	for _, re := range f.Metadata {
+		patterns = append(patterns, re)
+	}
+	for _, re := range f.Tags {
+		patterns = append(patterns, re)
+	}
+	return patterns
 }
 
 // Action to be applied when copying an object.
@@ -50,20 +212,60 @@ type Action struct {
 	// Content Type override
 	ContentType     *string `mapstructure:"content-type"`
 	ContentEncoding *string `mapstructure:"content-encoding"`
+	// Destination selects a named destination to copy the object to
+	Destination *string `mapstructure:"destination"`
+	// Key rewrites the destination key, relative to the destination path.
+	// See KeyTemplate for supported placeholders.
+	Key *KeyTemplate `mapstructure:"key"`
 }
 
-func (a *Action) Apply(_ context.Context, input *s3.CopyObjectInput) bool {
-	if ct := a.ContentType; ct != nil {
-		if *ct == ignoreContentType {
+// Apply action to input.
+// Selecting a destination or key does not modify the content, so actions
+// which only select a destination or key do not terminate rule evaluation.
+func (a *Action) Apply(ctx context.Context, input *s3.CopyObjectInput) bool {
+	return a.apply(ctx, input, nil)
+}
+
+func (a *Action) apply(ctx context.Context, input *s3.CopyObjectInput, captures map[string]string) bool {
+	result := ResultFromContext(ctx)
+	if a.Key != nil && input.Key != nil {
+		// first matching rule wins
+		if result == nil || result.Key == "" {
+			// only look up the object time if the key requires it, since
+			// it may need a HEAD request
+			var t time.Time
+			if a.Key.usesTime(captures) {
+				t = objectTime(ctx)
+			}
+			if key, ok := a.Key.Expand(input, captures, t); ok {
+				input.Key = &key
+				if result != nil {
+					result.Key = key
+				}
+			}
+		}
+	}
+	if a.Destination != nil {
+		// first matching rule wins
+		if result != nil && result.Destination == "" {
+			result.Destination = *a.Destination
+		}
+	}
+	if (a.Destination != nil || a.Key != nil) && a.ContentType == nil && a.ContentEncoding == nil {
+		return false
+	}
+	if a.ContentType != nil {
+		if ct := expandCaptures(*a.ContentType, captures); ct == ignoreContentType {
 			// drop content
 			input.Key = nil
 		} else {
-			input.ContentType = ct
+			input.ContentType = &ct
 			input.MetadataDirective = types.MetadataDirectiveReplace
 		}
 	}
 	if a.ContentEncoding != nil {
-		input.ContentEncoding = a.ContentEncoding
+		ce := expandCaptures(*a.ContentEncoding, captures)
+		input.ContentEncoding = &ce
 		input.MetadataDirective = types.MetadataDirectiveReplace
 	}
 	return true
@@ -78,10 +280,15 @@ type Rule struct {
 }
 
 func (r *Rule) Apply(ctx context.Context, input *s3.CopyObjectInput) bool {
-	if r.Match.Match(input) {
-		return r.Override.Apply(ctx, input)
+	_, modified := r.apply(ctx, input)
+	return modified
+}
+
+func (r *Rule) apply(ctx context.Context, input *s3.CopyObjectInput) (matched, modified bool) {
+	if !r.Match.MatchContext(ctx, input) {
+		return false, false
 	}
-	return false
+	return true, r.Override.apply(ctx, input, r.Match.captures(ctx, input))
 }
 
 // Validate rule is sane.
@@ -89,6 +296,22 @@ func (r *Rule) Validate() error {
 	if !reIdentifier.MatchString(r.ID) {
 		return fmt.Errorf("%w: %q does not match allowed format %q", errIDFormat, r.ID, reIdentifier.String())
 	}
+	if d := r.Override.Destination; d != nil && !DestinationName.MatchString(*d) {
+		return fmt.Errorf("%w: %q does not match allowed format %q", errDestination, *d, DestinationName.String())
+	}
+	if k := r.Override.Key; k != nil {
+		if err := k.Validate(r.Match.Source, r.Match.patterns()...); err != nil {
+			return err
+		}
+	}
+	for _, v := range []*string{r.Override.ContentType, r.Override.ContentEncoding} {
+		if v == nil {
+			continue
+		}
+		if err := validateCaptures(*v, append(r.Match.patterns(), r.Match.Source)...); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -119,6 +342,37 @@ func (r *Rule) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// DestinationRule routes objects to a named destination.
+type DestinationRule struct {
+	Rule
+}
+
+// UnmarshalText populates a destination rule from a "pattern=destination" pair.
+func (r *DestinationRule) UnmarshalText(text []byte) error {
+	if err := r.Rule.UnmarshalText(text); err != nil {
+		return err
+	}
+	r.Override.Destination, r.Override.ContentType = r.Override.ContentType, nil
+	return nil
+}
+
+// KeyRule rewrites destination keys.
+type KeyRule struct {
+	Rule
+}
+
+// UnmarshalText populates a key rule from a "pattern=template" pair.
+func (r *KeyRule) UnmarshalText(text []byte) error {
+	if err := r.Rule.UnmarshalText(text); err != nil {
+		return err
+	}
+	if ct := r.Override.ContentType; ct != nil {
+		key := KeyTemplate(*ct)
+		r.Override.Key, r.Override.ContentType = &key, nil
+	}
+	return nil
+}
+
 // UnmarshalYAML handles compiling regexp.
 func (r *Rule) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var v any
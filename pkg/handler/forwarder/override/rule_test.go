@@ -1,10 +1,13 @@
 package override_test
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/go-cmp/cmp"
 	"gopkg.in/yaml.v3"
 
@@ -149,3 +152,63 @@ func TestRuleYAML(t *testing.T) {
 		}
 	}
 }
+
+func TestDestinationRule(t *testing.T) {
+	t.Parallel()
+
+	var first, second override.DestinationRule
+	if err := first.UnmarshalText([]byte(`CloudTrail=security`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.UnmarshalText([]byte(`.*=other`)); err != nil {
+		t.Fatal(err)
+	}
+
+	set := &override.Set{
+		Rules: []*override.Rule{
+			&first.Rule,
+			&second.Rule,
+			{
+				Match: override.Filter{
+					Source: regexp.MustCompile(`\.json\.gz$`),
+				},
+				Override: override.Action{
+					ContentType: ptr("application/x-aws-cloudtrail"),
+				},
+			},
+		},
+	}
+	if err := set.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var result override.Result
+	input := &s3.CopyObjectInput{
+		CopySource: aws.String("bucket/AWSLogs/123456789012/CloudTrail/us-west-2/file.json.gz"),
+	}
+
+	if !set.Apply(override.NewContext(context.Background(), &result), input) {
+		t.Fatal("expected input to be modified")
+	}
+
+	if result.Destination != "security" {
+		t.Errorf("expected first matching destination, got %q", result.Destination)
+	}
+
+	// destination rules must not prevent subsequent rules from applying
+	if diff := cmp.Diff(aws.ToString(input.ContentType), "application/x-aws-cloudtrail"); diff != "" {
+		t.Error("unexpected content type", diff)
+	}
+}
+
+func TestDestinationRuleValidate(t *testing.T) {
+	t.Parallel()
+
+	var rule override.DestinationRule
+	if err := rule.UnmarshalText([]byte(`.*=!invalid`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := rule.Validate(); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 
@@ -11,6 +13,8 @@ import (
 )
 
 var (
+	ErrUnknownDestination = errors.New("unknown destination")
+
 	errDuplicate        = errors.New("duplicate ID")
 	errMissingDelimiter = errors.New("missing delimiter")
 	defaultDelimiter    = "="
@@ -18,19 +22,54 @@ var (
 
 // Set is a sequence of rules.
 type Set struct {
+	Name   string
 	Logger logr.Logger
 	Rules  []*Rule
+
+	// mu guards Rules against concurrent replacement.
+	mu sync.RWMutex
+}
+
+// rules returns the current sequence of rules.
+func (s *Set) rules() []*Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Rules
+}
+
+// Replace the rules in a set. Rules should be validated beforehand.
+func (s *Set) Replace(rules []*Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Rules = rules
 }
 
 func (s *Set) Apply(ctx context.Context, input *s3.CopyObjectInput) (modified bool) {
-	for i, rule := range s.Rules {
-		if rule.Apply(ctx, input) {
+	result := ResultFromContext(ctx)
+	for i, rule := range s.rules() {
+		matched, ruleModified := rule.apply(ctx, input)
+		if !matched {
+			continue
+		}
+		id := rule.ID
+		if id == "" {
+			id = fmt.Sprintf("%d", i)
+		}
+		if result != nil {
+			result.Matches = append(result.Matches, &RuleMatch{
+				Set:      s.Name,
+				RuleID:   id,
+				Modified: ruleModified,
+				Continue: rule.Continue,
+			})
+		}
+		if ruleModified {
 			modified = true
-			id := rule.ID
-			if id == "" {
-				id = fmt.Sprintf("%d", i)
-			}
 			s.Logger.V(3).Info("applied rule", "id", id)
+			if result != nil && result.RuleID == "" {
+				result.RuleID = id
+				result.Set = s.Name
+			}
 			if !rule.Continue {
 				return
 			}
@@ -41,8 +80,12 @@ func (s *Set) Apply(ctx context.Context, input *s3.CopyObjectInput) (modified bo
 
 // Validate rules do not have duplicate ids.
 func (s *Set) Validate() error {
-	seen := make(map[string]struct{}, len(s.Rules))
-	for i, rule := range s.Rules {
+	return validateRules(s.rules())
+}
+
+func validateRules(rules []*Rule) error {
+	seen := make(map[string]struct{}, len(rules))
+	for i, rule := range rules {
 		id := fmt.Sprintf("%d", i)
 		if rule.ID != "" {
 			id = rule.ID
@@ -59,8 +102,44 @@ func (s *Set) Validate() error {
 	return nil
 }
 
+// ValidateDestinations verifies rules only route objects to one of the
+// named destinations.
+func (s *Set) ValidateDestinations(names []string) error {
+	if err := validateDestinations(s.rules(), names); err != nil {
+		return fmt.Errorf("set %q: %w", s.Name, err)
+	}
+	return nil
+}
+
+func validateDestinations(rules []*Rule, names []string) error {
+	for i, rule := range rules {
+		d := rule.Override.Destination
+		if d == nil || slices.Contains(names, *d) {
+			continue
+		}
+		id := fmt.Sprintf("%d", i)
+		if rule.ID != "" {
+			id = rule.ID
+		}
+		return fmt.Errorf("rule %q: %w: %q", id, ErrUnknownDestination, *d)
+	}
+	return nil
+}
+
 type Sets []*Set
 
+// ValidateDestinations verifies rules in all sets only route objects to one
+// of the named destinations.
+func (ss Sets) ValidateDestinations(names []string) error {
+	var errs []error
+	for _, s := range ss {
+		if err := s.ValidateDestinations(names); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (ss Sets) Apply(ctx context.Context, input *s3.CopyObjectInput) (modified bool) {
 	for _, s := range ss {
 		if s.Apply(ctx, input) {
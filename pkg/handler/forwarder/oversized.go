@@ -0,0 +1,258 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-logr/logr"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http"
+)
+
+// OversizedMode determines how objects exceeding MaxFileSize are handled.
+type OversizedMode string
+
+const (
+	// OversizedSkip does not copy the object.
+	OversizedSkip OversizedMode = "skip"
+	// OversizedFail marks the message as failed.
+	OversizedFail OversizedMode = "fail"
+	// OversizedTruncate forwards the first MaxFileSize bytes of the object.
+	OversizedTruncate OversizedMode = "truncate"
+	// OversizedSplit forwards the whole object in chunks of at most
+	// MaxFileSize bytes, split along line boundaries.
+	OversizedSplit OversizedMode = "split"
+)
+
+const (
+	lineScanBufferSize = 32 * 1024
+)
+
+var (
+	ErrInvalidOversizedMode = errors.New("invalid oversized mode")
+	ErrObjectTooLarge       = errors.New("object exceeds maximum file size")
+
+	errEncodedObject = errors.New("cannot forward part of an encoded object")
+)
+
+func (m OversizedMode) Validate() error {
+	switch m {
+	case "", OversizedSkip, OversizedFail, OversizedTruncate, OversizedSplit:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidOversizedMode, m)
+	}
+}
+
+// copyRanges forwards an oversized object to a destination through ranged
+// reads of at most MaxFileSize bytes. Only the first range is forwarded
+// when truncating. When splitting, every range after the first is written
+// to a separate key for S3 destinations.
+func (h *Handler) copyRanges(ctx context.Context, destination *Destination, sourceURL *url.URL, copyInput *s3.CopyObjectInput, copyRecord *CopyRecord) (written int64, parts int, err error) {
+	size := aws.ToInt64(copyRecord.Size)
+	if h.OversizedMode == OversizedTruncate {
+		size = h.MaxFileSize
+	}
+
+	var versionID *string
+	if copyRecord.VersionID != "" {
+		versionID = aws.String(copyRecord.VersionID)
+	}
+
+	for offset := int64(0); offset < size; parts++ {
+		end := min(offset+h.MaxFileSize, size)
+
+		key := aws.ToString(copyInput.Key)
+		if parts > 0 && destination.URI.Scheme == "s3" {
+			key = fmt.Sprintf("%s.part%d", key, parts)
+		}
+
+		n, err := h.copyRange(ctx, destination, &objectRange{
+			Bucket:    aws.String(sourceURL.Host),
+			Key:       aws.String(strings.TrimLeft(sourceURL.Path, "/")),
+			VersionID: versionID,
+			Offset:    offset,
+			Length:    end - offset,
+		}, copyInput, key, end < size)
+		if err != nil {
+			return written, parts, fmt.Errorf("failed to copy range at offset %d: %w", offset, err)
+		}
+		if n == 0 {
+			return written, parts, fmt.Errorf("empty range at offset %d: %w", offset, io.ErrUnexpectedEOF)
+		}
+		offset += n
+		written += n
+	}
+	return written, parts, nil
+}
+
+// objectRange identifies a byte range of a source object.
+type objectRange struct {
+	Bucket    *string
+	Key       *string
+	VersionID *string
+	Offset    int64
+	Length    int64
+}
+
+// header formats the range as an HTTP Range header.
+func (r *objectRange) header() string {
+	return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+}
+
+func (r *objectRange) getObjectInput() *s3.GetObjectInput {
+	return &s3.GetObjectInput{
+		Bucket:    r.Bucket,
+		Key:       r.Key,
+		VersionId: r.VersionID,
+		Range:     aws.String(r.header()),
+	}
+}
+
+// rangeReaderAt reads from an object range through ranged GetObject
+// requests, so that a line boundary can be found without reading the
+// whole range.
+type rangeReaderAt struct {
+	ctx    context.Context
+	h      *Handler
+	client S3Client
+	*objectRange
+}
+
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.Length {
+		return 0, io.EOF
+	}
+	getResp, err := r.h.getObject(r.ctx, r.client, (&objectRange{
+		Bucket:    r.Bucket,
+		Key:       r.Key,
+		VersionID: r.VersionID,
+		Offset:    r.Offset + off,
+		Length:    min(int64(len(p)), r.Length-off),
+	}).getObjectInput())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer getResp.Body.Close()
+
+	n, err := io.ReadFull(getResp.Body, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// lineRange shortens a range so that it ends after its last newline. The
+// range is left unchanged if it contains no newline.
+func (h *Handler) lineRange(ctx context.Context, client S3Client, r *objectRange) (*objectRange, error) {
+	lineEnd, err := lastLineEnd(&rangeReaderAt{ctx: ctx, h: h, client: client, objectRange: r}, r.Length)
+	if err != nil {
+		return nil, err
+	}
+	if lineEnd == 0 {
+		logr.FromContextOrDiscard(ctx).V(1).Info("no line boundary found in range; splitting line", "range", r.header())
+		return r, nil
+	}
+	trimmed := *r
+	trimmed.Length = lineEnd
+	return &trimmed, nil
+}
+
+// copyRange forwards a single range of an object, returning the number of
+// bytes written. If trimLine is set, the range is cut after the last newline.
+//
+// Ranges are streamed from source to destination. HTTP destinations decode
+// records, and so receive ranges through the same copy path as whole
+// objects.
+func (h *Handler) copyRange(ctx context.Context, destination *Destination, r *objectRange, copyInput *s3.CopyObjectInput, key string, trimLine bool) (int64, error) {
+	client := destination.S3Client
+
+	if ce := aws.ToString(copyInput.ContentEncoding); ce != "" && ce != "identity" {
+		return 0, fmt.Errorf("%w: %q", errEncodedObject, ce)
+	}
+
+	if destination.URI.Scheme != "s3" {
+		if trimLine {
+			var err error
+			if r, err = h.lineRange(ctx, client, r); err != nil {
+				return 0, err
+			}
+		}
+		rangeInput := *copyInput
+		rangeInput.Key = aws.String(key)
+		out, err := client.CopyObject(s3http.WithSourceRange(ctx, r.header()), &rangeInput)
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy object: %w", err)
+		}
+		if n, ok := s3http.BytesCopied(out); ok {
+			return n, nil
+		}
+		return r.Length, nil
+	}
+
+	getResp, err := h.getObject(ctx, client, r.getObjectInput())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer getResp.Body.Close()
+
+	contentType, contentEncoding := getResp.ContentType, getResp.ContentEncoding
+	if copyInput.ContentType != nil {
+		contentType = copyInput.ContentType
+	}
+	if copyInput.ContentEncoding != nil {
+		contentEncoding = copyInput.ContentEncoding
+	}
+	if ce := aws.ToString(contentEncoding); ce != "" && ce != "identity" {
+		return 0, fmt.Errorf("%w: %q", errEncodedObject, ce)
+	}
+
+	n := r.Length
+	if getResp.ContentLength != nil {
+		n = min(n, *getResp.ContentLength)
+	}
+	if trimLine {
+		lineRange, err := h.lineRange(ctx, client, r)
+		if err != nil {
+			return 0, err
+		}
+		n = min(n, lineRange.Length)
+	}
+
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:          copyInput.Bucket,
+		Key:             aws.String(key),
+		Body:            io.LimitReader(getResp.Body, n),
+		ContentLength:   aws.Int64(n),
+		ContentType:     contentType,
+		ContentEncoding: contentEncoding,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to put object: %w", err)
+	}
+	return n, nil
+}
+
+// lastLineEnd returns the offset following the last newline within the
+// first size bytes of r, or zero if there is none.
+func lastLineEnd(r io.ReaderAt, size int64) (int64, error) {
+	buf := make([]byte, lineScanBufferSize)
+	for end := size; end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := r.ReadAt(chunk, start); err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("failed to read body: %w", err)
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
@@ -0,0 +1,271 @@
+package forwarder_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+func TestOversizedMode(t *testing.T) {
+	t.Parallel()
+
+	// 30 bytes, with lines of 10 bytes each
+	source := "line0001\nline0002\nline0003\n"
+
+	testcases := []struct {
+		Mode          forwarder.OversizedMode
+		ContentType   string
+		ExpectError   error
+		ExpectPuts    map[string]string
+		ExpectOutcome *forwarder.CopyOutcome
+	}{
+		{
+			Mode: forwarder.OversizedSkip,
+			ExpectOutcome: &forwarder.CopyOutcome{
+				URI:    "s3://source/a.log",
+				Status: forwarder.OutcomeSkippedSize,
+			},
+		},
+		{
+			Mode:        forwarder.OversizedFail,
+			ExpectError: forwarder.ErrObjectTooLarge,
+			ExpectOutcome: &forwarder.CopyOutcome{
+				URI:    "s3://source/a.log",
+				Status: forwarder.OutcomeFailedSize,
+			},
+		},
+		{
+			Mode: forwarder.OversizedTruncate,
+			ExpectPuts: map[string]string{
+				"prefix/a.log": "line0001\nline00",
+			},
+			ExpectOutcome: &forwarder.CopyOutcome{
+				URI:         "s3://source/a.log",
+				Destination: "s3://destination/prefix",
+				Key:         "prefix/a.log",
+				Status:      forwarder.OutcomeTruncated,
+				Bytes:       15,
+				Parts:       1,
+			},
+		},
+		{
+			Mode: forwarder.OversizedSplit,
+			ExpectPuts: map[string]string{
+				"prefix/a.log":       "line0001\n",
+				"prefix/a.log.part1": "line0002\n",
+				"prefix/a.log.part2": "line0003\n",
+			},
+			ExpectOutcome: &forwarder.CopyOutcome{
+				URI:         "s3://source/a.log",
+				Destination: "s3://destination/prefix",
+				Key:         "prefix/a.log",
+				Status:      forwarder.OutcomeSplit,
+				Bytes:       27,
+				Parts:       3,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(string(tc.Mode), func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu   sync.Mutex
+				puts = make(map[string]string)
+			)
+
+			h, err := forwarder.New(&forwarder.Config{
+				DestinationURI: "s3://destination/prefix",
+				MaxFileSize:    15,
+				OversizedMode:  tc.Mode,
+				S3Client: &awstest.S3Client{
+					GetObjectFunc: func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+						var start, end int
+						if _, err := fmt.Sscanf(aws.ToString(input.Range), "bytes=%d-%d", &start, &end); err != nil {
+							return nil, err
+						}
+						end = min(end+1, len(source))
+						return &s3.GetObjectOutput{
+							Body:        io.NopCloser(bytes.NewBufferString(source[start:end])),
+							ContentType: aws.String("text/plain"),
+						}, nil
+					},
+					PutObjectFunc: func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+						data, err := io.ReadAll(input.Body)
+						if err != nil {
+							return nil, err
+						}
+						if ct := aws.ToString(input.ContentType); ct != "text/plain" {
+							t.Errorf("unexpected content type %q", ct)
+						}
+						mu.Lock()
+						defer mu.Unlock()
+						puts[aws.ToString(input.Key)] = string(data)
+						return &s3.PutObjectOutput{}, nil
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := &forwarder.SQSMessage{
+				SQSMessage: events.SQSMessage{
+					Body: fmt.Sprintf(`{"copy": [{"uri": "s3://source/a.log", "size": %d}]}`, len(source)),
+				},
+			}
+
+			err = h.ProcessRecord(context.Background(), result)
+			if diff := cmp.Diff(err, tc.ExpectError, cmpopts.EquateErrors()); diff != "" {
+				t.Error("unexpected error", diff)
+			}
+
+			if diff := cmp.Diff(puts, tc.ExpectPuts, cmpopts.EquateEmpty()); diff != "" {
+				t.Error("unexpected puts", diff)
+			}
+
+			if diff := cmp.Diff(result.Outcomes, []*forwarder.CopyOutcome{tc.ExpectOutcome}); diff != "" {
+				t.Error("unexpected outcome", diff)
+			}
+		})
+	}
+}
+
+func TestOversizedModeHTTP(t *testing.T) {
+	t.Parallel()
+
+	// 24 bytes, with records of 8 bytes each
+	source := "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"
+
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, string(body))
+	}))
+	defer srv.Close()
+
+	client, err := s3http.New(&s3http.Config{
+		DestinationURI: srv.URL,
+		GetObjectAPIClient: &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				var start, end int
+				if _, err := fmt.Sscanf(aws.ToString(input.Range), "bytes=%d-%d", &start, &end); err != nil {
+					return nil, err
+				}
+				end = min(end+1, len(source))
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewBufferString(source[start:end])),
+					ContentLength: aws.Int64(int64(end - start)),
+					ContentType:   aws.String("application/x-ndjson"),
+				}, nil
+			},
+		},
+		HTTPClient: srv.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: srv.URL,
+		MaxFileSize:    12,
+		OversizedMode:  forwarder.OversizedSplit,
+		S3Client:       client,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &forwarder.SQSMessage{
+		SQSMessage: events.SQSMessage{
+			Body: fmt.Sprintf(`{"copy": [{"uri": "s3://source/a.json", "size": %d}]}`, len(source)),
+		},
+	}
+
+	if err := h.ProcessRecord(context.Background(), result); err != nil {
+		t.Fatal(err)
+	}
+
+	// every range is decoded, rather than forwarded as raw bytes
+	expect := []string{"{\"n\":1}\n", "{\"n\":2}\n", "{\"n\":3}\n"}
+	if diff := cmp.Diff(requests, expect); diff != "" {
+		t.Error("unexpected requests", diff)
+	}
+
+	if n := len(result.Outcomes); n != 1 || result.Outcomes[0].Bytes != int64(len(source)) || result.Outcomes[0].Parts != 3 {
+		t.Errorf("unexpected outcomes: %v", result.Outcomes)
+	}
+}
+
+func TestOversizedModeEncoded(t *testing.T) {
+	t.Parallel()
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination",
+		MaxFileSize:    1,
+		OversizedMode:  forwarder.OversizedTruncate,
+		S3Client: &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:            io.NopCloser(bytes.NewBufferString("x")),
+					ContentEncoding: aws.String("gzip"),
+				}, nil
+			},
+			PutObjectFunc: func(_ context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				t.Error("encoded object must not be truncated")
+				return nil, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &forwarder.SQSMessage{
+		SQSMessage: events.SQSMessage{
+			Body: `{"copy": [{"uri": "s3://source/a.log.gz", "size": 10}]}`,
+		},
+	}
+
+	if err := h.ProcessRecord(context.Background(), result); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if n := len(result.Outcomes); n != 1 || result.Outcomes[0].Status != forwarder.OutcomeFailed {
+		t.Errorf("unexpected outcomes: %v", result.Outcomes)
+	}
+}
+
+func TestOversizedModeValidate(t *testing.T) {
+	t.Parallel()
+
+	err := (&forwarder.Config{
+		DestinationURI: "s3://destination",
+		S3Client:       &awstest.S3Client{},
+		OversizedMode:  "compress",
+	}).Validate()
+	if diff := cmp.Diff(err, forwarder.ErrInvalidOversizedMode, cmpopts.EquateErrors()); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,85 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// maxRetryDelay bounds how long a retry is postponed. It matches the
+// maximum delay SQS supports for new messages.
+const maxRetryDelay = 15 * time.Minute
+
+type SQSClient interface {
+	SendMessage(context.Context, *sqs.SendMessageInput, ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	ChangeMessageVisibility(context.Context, *sqs.ChangeMessageVisibilityInput, ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+type Queue interface {
+	Put(context.Context, ...any) error
+}
+
+// DelayedQueue is a queue which can postpone delivery, e.g. to honor the
+// delay requested by a throttled destination.
+type DelayedQueue interface {
+	Queue
+	// PutDelayed submits items which are delivered after delay.
+	PutDelayed(ctx context.Context, delay time.Duration, items ...any) error
+	// Delay postpones redelivery of a received message.
+	Delay(ctx context.Context, receiptHandle string, delay time.Duration) error
+}
+
+type QueueWrapper struct {
+	Client SQSClient
+	URL    string
+}
+
+var _ DelayedQueue = &QueueWrapper{}
+
+func (q *QueueWrapper) Put(ctx context.Context, items ...any) error {
+	return q.PutDelayed(ctx, 0, items...)
+}
+
+func (q *QueueWrapper) PutDelayed(ctx context.Context, delay time.Duration, items ...any) error {
+	for i, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal item %d: %w", i, err)
+		}
+
+		_, err = q.Client.SendMessage(ctx, &sqs.SendMessageInput{
+			MessageBody:  aws.String(string(data)),
+			QueueUrl:     aws.String(q.URL),
+			DelaySeconds: int32(min(delay, maxRetryDelay) / time.Second),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send message %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (q *QueueWrapper) Delay(ctx context.Context, receiptHandle string, delay time.Duration) error {
+	_, err := q.Client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.URL),
+		ReceiptHandle:     aws.String(receiptHandle),
+		VisibilityTimeout: int32(min(delay, maxRetryDelay) / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change message visibility: %w", err)
+	}
+	return nil
+}
+
+func NewQueue(client SQSClient, queueURL string) (*QueueWrapper, error) {
+	q := &QueueWrapper{
+		Client: client,
+		URL:    queueURL,
+	}
+
+	return q, nil
+}
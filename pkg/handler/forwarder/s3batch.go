@@ -0,0 +1,218 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/go-logr/logr"
+)
+
+// Result codes reported back to S3 Batch Operations.
+const (
+	S3BatchSucceeded        = "Succeeded"
+	S3BatchTemporaryFailure = "TemporaryFailure"
+	S3BatchPermanentFailure = "PermanentFailure"
+)
+
+var ErrMissingBucket = errors.New("missing bucket")
+
+// S3BatchJobEvent is an S3 Batch Operations invocation event.
+//
+// Schema versions 1.0 and 2.0 differ only in how tasks identify the bucket
+// and whether user arguments are provided, so a single type decodes both.
+type S3BatchJobEvent struct {
+	InvocationSchemaVersion string              `json:"invocationSchemaVersion"`
+	InvocationID            string              `json:"invocationId"`
+	Job                     events.S3BatchJobV2 `json:"job"`
+	Tasks                   []S3BatchJobTask    `json:"tasks"`
+}
+
+type S3BatchJobTask struct {
+	TaskID      string `json:"taskId"`
+	S3Key       string `json:"s3Key"`
+	S3VersionID string `json:"s3VersionId,omitempty"`
+	// S3BucketARN is set in schema version 1.0.
+	S3BucketARN string `json:"s3BucketArn,omitempty"`
+	// S3Bucket is set in schema version 2.0.
+	S3Bucket string `json:"s3Bucket,omitempty"`
+}
+
+// CopyRecord returns the copy record for the object referenced by a task.
+func (t *S3BatchJobTask) CopyRecord(schemaVersion string) (*CopyRecord, error) {
+	bucket := t.S3Bucket
+	if bucket == "" {
+		// arn:partition:s3:::bucket
+		_, bucket, _ = strings.Cut(t.S3BucketARN, ":::")
+	}
+	if bucket == "" {
+		return nil, ErrMissingBucket
+	}
+
+	key := t.S3Key
+	if schemaVersion == "1.0" {
+		// keys are URL encoded in schema version 1.0
+		decoded, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key: %w", err)
+		}
+		key = decoded
+	}
+
+	u := &url.URL{Scheme: "s3", Host: bucket, Path: "/" + key}
+	return &CopyRecord{
+		URI:       u.String(),
+		VersionID: t.S3VersionID,
+	}, nil
+}
+
+// isPermanentCopyError verifies if retrying a copy can never succeed.
+func isPermanentCopyError(err error) bool {
+	if errors.Is(err, ErrObjectTooLarge) {
+		return true
+	}
+	var retryable retryableError
+	if errors.As(err, &retryable) {
+		return !retryable.Retryable()
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey",
+			"NoSuchBucket",
+			"NoSuchVersion",
+			"AccessDenied",
+			"InvalidObjectState",
+			// HeadObject responses have no body, so errors are only
+			// identified by their status
+			"NotFound",
+			"Forbidden":
+			return true
+		}
+	}
+	return false
+}
+
+// statObject fills in the size and ETag of the object referenced by a
+// task. Unlike event notifications, tasks carry neither, yet the size is
+// needed to apply size limits and copy large objects in parts, and the
+// ETag identifies the object revision for deduplication.
+func (h *Handler) statObject(ctx context.Context, copyRecord *CopyRecord) error {
+	var client s3.HeadObjectAPIClient
+	if h.Sources != nil {
+		client = h.Sources
+	} else if c, ok := h.S3Client.(s3.HeadObjectAPIClient); ok {
+		client = c
+	} else {
+		return nil
+	}
+
+	sourceURL, err := url.Parse(copyRecord.URI)
+	if err != nil {
+		return fmt.Errorf("failed to parse source URI: %w", err)
+	}
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(sourceURL.Host),
+		Key:    aws.String(strings.TrimPrefix(sourceURL.Path, "/")),
+	}
+	if copyRecord.VersionID != "" {
+		input.VersionId = aws.String(copyRecord.VersionID)
+	}
+
+	head, err := client.HeadObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to get object metadata: %w", err)
+	}
+	copyRecord.Size = head.ContentLength
+	copyRecord.ETag = strings.Trim(aws.ToString(head.ETag), `"`)
+	return nil
+}
+
+// HandleS3Batch processes S3 Batch Operations tasks. Each task is processed
+// as an individual SQS message, so that it is recorded alongside all other
+// messages, and reported back with a result code for the completion report.
+// Batch Operations retries failed tasks, so copies which failed for some
+// destinations are not resubmitted to the queue.
+func (h *Handler) HandleS3Batch(ctx context.Context, request S3BatchJobEvent) (response events.S3BatchJobResponse, err error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("jobId", request.Job.ID)
+
+	response = events.S3BatchJobResponse{
+		InvocationSchemaVersion: request.InvocationSchemaVersion,
+		InvocationID:            request.InvocationID,
+		TreatMissingKeysAs:      S3BatchPermanentFailure,
+	}
+
+	var messages bytes.Buffer
+	encoder := json.NewEncoder(&messages)
+	for _, task := range request.Tasks {
+		result := events.S3BatchJobResult{
+			TaskID:     task.TaskID,
+			ResultCode: S3BatchSucceeded,
+		}
+
+		copyRecord, err := task.CopyRecord(request.InvocationSchemaVersion)
+		if err != nil {
+			result.ResultCode = S3BatchPermanentFailure
+			result.ResultString = err.Error()
+			response.Results = append(response.Results, result)
+			continue
+		}
+
+		if err := h.statObject(ctx, copyRecord); err != nil {
+			logger.Error(err, "failed to get task object", "taskId", task.TaskID)
+			result.ResultCode = S3BatchTemporaryFailure
+			if isPermanentCopyError(err) {
+				result.ResultCode = S3BatchPermanentFailure
+			}
+			result.ResultString = err.Error()
+			response.Results = append(response.Results, result)
+			continue
+		}
+
+		body, err := json.Marshal(&CopyEvent{Copy: []CopyRecord{*copyRecord}})
+		if err != nil {
+			return response, fmt.Errorf("failed to marshal copy event: %w", err)
+		}
+
+		message := &SQSMessage{
+			SQSMessage: events.SQSMessage{
+				MessageId:   task.TaskID,
+				Body:        string(body),
+				EventSource: "aws:s3:batch",
+			},
+			senderRetries: true,
+		}
+
+		if err := h.ProcessRecord(ctx, message); err != nil {
+			logger.Error(err, "failed to process task", "taskId", task.TaskID)
+			message.ErrorMessage = err.Error()
+			result.ResultCode = S3BatchTemporaryFailure
+			if isPermanentCopyError(err) {
+				result.ResultCode = S3BatchPermanentFailure
+			}
+			result.ResultString = err.Error()
+		}
+		response.Results = append(response.Results, result)
+
+		if e := encoder.Encode(message); e != nil {
+			logger.Error(e, "failed to encode message")
+		}
+	}
+
+	// results must be reported regardless, since objects have already been copied
+	if messages.Len() > 0 {
+		if err := h.WriteSQS(ctx, &messages); err != nil {
+			logger.Error(err, "failed to write messages")
+		}
+	}
+	return response, nil
+}
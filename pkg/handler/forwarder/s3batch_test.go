@@ -0,0 +1,318 @@
+package forwarder_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+func TestHandleS3Batch(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Event         string
+		CopyErr       error
+		HeadErr       error
+		Size          int64
+		MaxFileSize   int64
+		OversizedMode forwarder.OversizedMode
+		ExpectKeys    []string
+		Expect        events.S3BatchJobResponse
+	}{
+		{
+			Event: `{
+				"invocationSchemaVersion": "1.0",
+				"invocationId": "YXNkbGZqYWRmaiBhc2RmdW9hZHNmZGpmaGFzbGtkaGZza2RmaAo",
+				"job": {"id": "f3cc4f60-61f6-4a2b-8a21-d07600c373ce"},
+				"tasks": [
+					{
+						"taskId": "dGFza2lkZ29lc2hlcmUK",
+						"s3Key": "AWSLogs/a%2Bb+c.json",
+						"s3VersionId": "1",
+						"s3BucketArn": "arn:aws:s3:::my-bucket"
+					}
+				]
+			}`,
+			ExpectKeys: []string{"AWSLogs/a+b c.json"},
+			Expect: events.S3BatchJobResponse{
+				InvocationSchemaVersion: "1.0",
+				InvocationID:            "YXNkbGZqYWRmaiBhc2RmdW9hZHNmZGpmaGFzbGtkaGZza2RmaAo",
+				TreatMissingKeysAs:      "PermanentFailure",
+				Results: []events.S3BatchJobResult{
+					{TaskID: "dGFza2lkZ29lc2hlcmUK", ResultCode: "Succeeded"},
+				},
+			},
+		},
+		{
+			Event: `{
+				"invocationSchemaVersion": "2.0",
+				"invocationId": "YXNkbGZqYWRmaiBhc2RmdW9hZHNmZGpmaGFzbGtkaGZza2RmaAo",
+				"job": {"id": "f3cc4f60-61f6-4a2b-8a21-d07600c373ce", "userArguments": {"k1": "v1"}},
+				"tasks": [
+					{
+						"taskId": "dGFza2lkZ29lc2hlcmUK",
+						"s3Key": "AWSLogs/a+b c.json",
+						"s3VersionId": null,
+						"s3Bucket": "my-bucket"
+					}
+				]
+			}`,
+			ExpectKeys: []string{"AWSLogs/a+b c.json"},
+			Expect: events.S3BatchJobResponse{
+				InvocationSchemaVersion: "2.0",
+				InvocationID:            "YXNkbGZqYWRmaiBhc2RmdW9hZHNmZGpmaGFzbGtkaGZza2RmaAo",
+				TreatMissingKeysAs:      "PermanentFailure",
+				Results: []events.S3BatchJobResult{
+					{TaskID: "dGFza2lkZ29lc2hlcmUK", ResultCode: "Succeeded"},
+				},
+			},
+		},
+		{
+			Event: `{
+				"invocationSchemaVersion": "2.0",
+				"invocationId": "abc",
+				"job": {"id": "1"},
+				"tasks": [
+					{"taskId": "1", "s3Key": "a.json", "s3Bucket": "my-bucket"},
+					{"taskId": "2", "s3Key": "b.json"}
+				]
+			}`,
+			CopyErr:    errSentinel,
+			ExpectKeys: []string{"a.json"},
+			Expect: events.S3BatchJobResponse{
+				InvocationSchemaVersion: "2.0",
+				InvocationID:            "abc",
+				TreatMissingKeysAs:      "PermanentFailure",
+				Results: []events.S3BatchJobResult{
+					{
+						TaskID:       "1",
+						ResultCode:   "TemporaryFailure",
+						ResultString: `error copying file "s3://my-bucket/a.json" to "s3://destination": sentinel error`,
+					},
+					{
+						TaskID:       "2",
+						ResultCode:   "PermanentFailure",
+						ResultString: "missing bucket",
+					},
+				},
+			},
+		},
+		{
+			Event: `{
+				"invocationSchemaVersion": "2.0",
+				"invocationId": "abc",
+				"job": {"id": "1"},
+				"tasks": [
+					{"taskId": "1", "s3Key": "a.json", "s3Bucket": "my-bucket"}
+				]
+			}`,
+			CopyErr:    &smithy.GenericAPIError{Code: "NoSuchKey", Message: "missing"},
+			ExpectKeys: []string{"a.json"},
+			Expect: events.S3BatchJobResponse{
+				InvocationSchemaVersion: "2.0",
+				InvocationID:            "abc",
+				TreatMissingKeysAs:      "PermanentFailure",
+				Results: []events.S3BatchJobResult{
+					{
+						TaskID:       "1",
+						ResultCode:   "PermanentFailure",
+						ResultString: `error copying file "s3://my-bucket/a.json" to "s3://destination": api error NoSuchKey: missing`,
+					},
+				},
+			},
+		},
+		{
+			// tasks carry no size, so it is retrieved before applying limits
+			Event: `{
+				"invocationSchemaVersion": "2.0",
+				"invocationId": "abc",
+				"job": {"id": "1"},
+				"tasks": [
+					{"taskId": "1", "s3Key": "large.json", "s3VersionId": "2", "s3Bucket": "my-bucket"}
+				]
+			}`,
+			Size:          20,
+			MaxFileSize:   10,
+			OversizedMode: forwarder.OversizedFail,
+			Expect: events.S3BatchJobResponse{
+				InvocationSchemaVersion: "2.0",
+				InvocationID:            "abc",
+				TreatMissingKeysAs:      "PermanentFailure",
+				Results: []events.S3BatchJobResult{
+					{
+						TaskID:       "1",
+						ResultCode:   "PermanentFailure",
+						ResultString: `object exceeds maximum file size: "s3://my-bucket/large.json" is 20 bytes`,
+					},
+				},
+			},
+		},
+		{
+			Event: `{
+				"invocationSchemaVersion": "2.0",
+				"invocationId": "abc",
+				"job": {"id": "1"},
+				"tasks": [
+					{"taskId": "1", "s3Key": "small.json", "s3Bucket": "my-bucket"}
+				]
+			}`,
+			Size:        5,
+			MaxFileSize: 10,
+			ExpectKeys:  []string{"small.json"},
+			Expect: events.S3BatchJobResponse{
+				InvocationSchemaVersion: "2.0",
+				InvocationID:            "abc",
+				TreatMissingKeysAs:      "PermanentFailure",
+				Results: []events.S3BatchJobResult{
+					{TaskID: "1", ResultCode: "Succeeded"},
+				},
+			},
+		},
+		{
+			Event: `{
+				"invocationSchemaVersion": "2.0",
+				"invocationId": "abc",
+				"job": {"id": "1"},
+				"tasks": [
+					{"taskId": "1", "s3Key": "missing.json", "s3Bucket": "my-bucket"},
+					{"taskId": "2", "s3Key": "throttled.json", "s3Bucket": "my-bucket"}
+				]
+			}`,
+			HeadErr: &smithy.GenericAPIError{Code: "NotFound", Message: "Not Found"},
+			Expect: events.S3BatchJobResponse{
+				InvocationSchemaVersion: "2.0",
+				InvocationID:            "abc",
+				TreatMissingKeysAs:      "PermanentFailure",
+				Results: []events.S3BatchJobResult{
+					{
+						TaskID:       "1",
+						ResultCode:   "PermanentFailure",
+						ResultString: "failed to get object metadata: api error NotFound: Not Found",
+					},
+					{
+						TaskID:       "2",
+						ResultCode:   "TemporaryFailure",
+						ResultString: "failed to get object metadata: api error SlowDown: Please reduce your request rate.",
+					},
+				},
+			},
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			var event forwarder.S3BatchJobEvent
+			if err := json.Unmarshal([]byte(tc.Event), &event); err != nil {
+				t.Fatal(err)
+			}
+
+			var keys []string
+			h, err := forwarder.New(&forwarder.Config{
+				DestinationURI: "s3://destination",
+				MaxFileSize:    tc.MaxFileSize,
+				OversizedMode:  tc.OversizedMode,
+				S3Client: &awstest.S3Client{
+					HeadObjectFunc: func(_ context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+						if aws.ToString(input.Bucket) != "my-bucket" {
+							t.Errorf("unexpected bucket %q", aws.ToString(input.Bucket))
+						}
+						if tc.HeadErr != nil {
+							if aws.ToString(input.Key) == "throttled.json" {
+								return nil, &smithy.GenericAPIError{Code: "SlowDown", Message: "Please reduce your request rate."}
+							}
+							return nil, tc.HeadErr
+						}
+						return &s3.HeadObjectOutput{ContentLength: aws.Int64(tc.Size), ETag: aws.String(`"etag"`)}, nil
+					},
+					CopyObjectFunc: func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						keys = append(keys, aws.ToString(input.Key))
+						return nil, tc.CopyErr
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+			response, err := h.HandleS3Batch(ctx, event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(response, tc.Expect); diff != "" {
+				t.Error("unexpected response", diff)
+			}
+
+			if diff := cmp.Diff(keys, tc.ExpectKeys); diff != "" {
+				t.Error("unexpected copies", diff)
+			}
+		})
+	}
+}
+
+// TestHandleS3BatchPartialFailure verifies that a task which was copied to
+// some destinations only is reported as a temporary failure, and left for
+// Batch Operations to retry rather than resubmitted to the queue.
+func TestHandleS3BatchPartialFailure(t *testing.T) {
+	t.Parallel()
+
+	queue := &fakeQueue{}
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://primary",
+		S3Client:       &awstest.S3Client{},
+		Destinations: []*forwarder.DestinationConfig{
+			{
+				URI: "s3://secondary",
+				S3Client: &awstest.S3Client{
+					CopyObjectFunc: func(_ context.Context, _ *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						return nil, errSentinel
+					},
+				},
+			},
+		},
+		Queue: queue,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := lambdacontext.NewContext(context.Background(), lambdaContext)
+	response, err := h.HandleS3Batch(ctx, forwarder.S3BatchJobEvent{
+		InvocationSchemaVersion: "2.0",
+		InvocationID:            "abc",
+		Tasks: []forwarder.S3BatchJobTask{
+			{TaskID: "1", S3Key: "a.json", S3Bucket: "my-bucket"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []events.S3BatchJobResult{
+		{
+			TaskID:       "1",
+			ResultCode:   "TemporaryFailure",
+			ResultString: `error copying file "s3://my-bucket/a.json" to "s3://secondary": sentinel error`,
+		},
+	}
+	if diff := cmp.Diff(response.Results, expect); diff != "" {
+		t.Error("unexpected results", diff)
+	}
+	if len(queue.Items) != 0 {
+		t.Errorf("unexpected queue items: %v", queue.Items)
+	}
+}
@@ -0,0 +1,139 @@
+package s3http
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
+)
+
+// checkpointInterval is the minimum time between checkpoint writes for an
+// object. Progress made in the meantime is folded into the next write.
+const checkpointInterval = time.Second
+
+// objectCheckpoint is the checkpoint for the object being uploaded.
+type objectCheckpoint struct {
+	Key string
+	checkpoint.Checkpoint
+	// Ranged is set if the body starts at the checkpoint offset, rather
+	// than at the start of the object.
+	Ranged bool
+}
+
+type objectCheckpointContext struct{}
+
+// withObjectCheckpoint annotates context with the checkpoint for the object
+// being uploaded.
+func withObjectCheckpoint(ctx context.Context, c *objectCheckpoint) context.Context {
+	return context.WithValue(ctx, objectCheckpointContext{}, c)
+}
+
+func objectCheckpointFromContext(ctx context.Context) *objectCheckpoint {
+	c, _ := ctx.Value(objectCheckpointContext{}).(*objectCheckpoint)
+	return c
+}
+
+// checkpointWriter records progress in the background, so that handling
+// batches is not held up by the checkpoint store. Only the latest position
+// is written, at most once per interval.
+type checkpointWriter struct {
+	store    checkpoint.Store
+	key      string
+	interval time.Duration
+	logger   logr.Logger
+
+	mu      sync.Mutex
+	latest  checkpoint.Checkpoint
+	pending bool
+	written bool
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newCheckpointWriter(ctx context.Context, store checkpoint.Store, key string, interval time.Duration) *checkpointWriter {
+	w := &checkpointWriter{
+		store:    store,
+		key:      key,
+		interval: interval,
+		logger:   logr.FromContextOrDiscard(ctx),
+		notify:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run(ctx)
+	return w
+}
+
+// Update sets the position to be written. It does not block.
+func (w *checkpointWriter) Update(records int, offset int64) {
+	w.mu.Lock()
+	w.latest = checkpoint.Checkpoint{Records: records, Offset: offset}
+	w.pending = true
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *checkpointWriter) run(ctx context.Context) {
+	defer close(w.done)
+
+	var last time.Time
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.notify:
+		}
+
+		if wait := w.interval - time.Since(last); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-w.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		w.Flush(ctx)
+		last = time.Now()
+	}
+}
+
+// Stop background writes, waiting for any write in progress. It returns
+// whether a checkpoint was written.
+func (w *checkpointWriter) Stop() bool {
+	close(w.stop)
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+// Flush writes the latest position, if it has not been written yet.
+func (w *checkpointWriter) Flush(ctx context.Context) {
+	w.mu.Lock()
+	c, pending := w.latest, w.pending
+	w.pending = false
+	w.mu.Unlock()
+
+	if !pending {
+		return
+	}
+	if err := w.store.Put(ctx, w.key, c); err != nil {
+		w.logger.Error(err, "failed to store checkpoint")
+		return
+	}
+
+	w.mu.Lock()
+	w.written = true
+	w.mu.Unlock()
+}
@@ -0,0 +1,133 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// attribute names for DynamoDB items. The expiry attribute should be
+	// configured as the TTL attribute of the table so that DynamoDB
+	// removes checkpoints for objects which were never fully delivered.
+	keyAttribute     = "key"
+	recordsAttribute = "records"
+	offsetAttribute  = "offset"
+	expiryAttribute  = "expiresAt"
+
+	defaultTTL = 24 * time.Hour
+)
+
+var ErrMissingTableName = errors.New("missing table name")
+
+type DynamoDBClient interface {
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// DynamoDBStore records checkpoints in a DynamoDB table.
+type DynamoDBStore struct {
+	Client    DynamoDBClient
+	TableName string
+	TTL       time.Duration
+	Now       func() time.Time
+}
+
+var _ Store = &DynamoDBStore{}
+
+func (d *DynamoDBStore) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+func (d *DynamoDBStore) itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		keyAttribute: &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func (d *DynamoDBStore) Get(ctx context.Context, key string) (Checkpoint, error) {
+	out, err := d.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.TableName),
+		ConsistentRead: aws.Bool(true),
+		Key:            d.itemKey(key),
+	})
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to get item: %w", err)
+	}
+
+	v, ok := out.Item[recordsAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return Checkpoint{}, nil
+	}
+
+	// DynamoDB deletes expired items lazily, so we must check expiry ourselves.
+	if e, ok := out.Item[expiryAttribute].(*types.AttributeValueMemberN); ok {
+		expiresAt, err := strconv.ParseInt(e.Value, 10, 64)
+		if err != nil {
+			return Checkpoint{}, fmt.Errorf("failed to parse expiry: %w", err)
+		}
+		if d.now().Unix() >= expiresAt {
+			return Checkpoint{}, nil
+		}
+	}
+
+	var c Checkpoint
+	if c.Records, err = strconv.Atoi(v.Value); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to parse records: %w", err)
+	}
+	if o, ok := out.Item[offsetAttribute].(*types.AttributeValueMemberN); ok {
+		if c.Offset, err = strconv.ParseInt(o.Value, 10, 64); err != nil {
+			return Checkpoint{}, fmt.Errorf("failed to parse offset: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (d *DynamoDBStore) Put(ctx context.Context, key string, c Checkpoint) error {
+	ttl := d.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	item := d.itemKey(key)
+	item[recordsAttribute] = &types.AttributeValueMemberN{Value: strconv.Itoa(c.Records)}
+	item[offsetAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(c.Offset, 10)}
+	item[expiryAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(d.now().Add(ttl).Unix(), 10)}
+
+	if _, err := d.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.TableName),
+		Item:      item,
+	}); err != nil {
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoDBStore) Delete(ctx context.Context, key string) error {
+	if _, err := d.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.TableName),
+		Key:       d.itemKey(key),
+	}); err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return nil
+}
+
+func NewDynamoDBStore(client DynamoDBClient, tableName string) (*DynamoDBStore, error) {
+	if tableName == "" {
+		return nil, ErrMissingTableName
+	}
+	return &DynamoDBStore{
+		Client:    client,
+		TableName: tableName,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package checkpoint_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+var errSentinel = errors.New("sentinel error")
+
+func TestDynamoDBStoreGet(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+
+	testcases := []struct {
+		Item      map[string]types.AttributeValue
+		GetErr    error
+		Expect    checkpoint.Checkpoint
+		ExpectErr error
+	}{
+		{
+			// missing item
+		},
+		{
+			Item: map[string]types.AttributeValue{
+				"key":       &types.AttributeValueMemberS{Value: "a"},
+				"records":   &types.AttributeValueMemberN{Value: "42"},
+				"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix()+60, 10)},
+			},
+			Expect: checkpoint.Checkpoint{Records: 42},
+		},
+		{
+			Item: map[string]types.AttributeValue{
+				"key":       &types.AttributeValueMemberS{Value: "a"},
+				"records":   &types.AttributeValueMemberN{Value: "42"},
+				"offset":    &types.AttributeValueMemberN{Value: "1024"},
+				"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix()+60, 10)},
+			},
+			Expect: checkpoint.Checkpoint{Records: 42, Offset: 1024},
+		},
+		{
+			// expired, but not yet deleted
+			Item: map[string]types.AttributeValue{
+				"key":       &types.AttributeValueMemberS{Value: "a"},
+				"records":   &types.AttributeValueMemberN{Value: "42"},
+				"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			},
+		},
+		{
+			GetErr:    errSentinel,
+			ExpectErr: errSentinel,
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+			store, err := checkpoint.NewDynamoDBStore(&awstest.DynamoDBClient{
+				GetItemFunc: func(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+					if aws.ToString(input.TableName) != "table" {
+						t.Errorf("unexpected table %q", aws.ToString(input.TableName))
+					}
+					return &dynamodb.GetItemOutput{Item: tc.Item}, tc.GetErr
+				},
+			}, "table")
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.Now = func() time.Time { return now }
+
+			got, err := store.Get(context.Background(), "a")
+			if !errors.Is(err, tc.ExpectErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.Expect {
+				t.Fatalf("expected %v, got %v", tc.Expect, got)
+			}
+		})
+	}
+}
+
+func TestDynamoDBStorePut(t *testing.T) {
+	t.Parallel()
+
+	store, err := checkpoint.NewDynamoDBStore(&awstest.DynamoDBClient{
+		PutItemFunc: func(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			if records, ok := input.Item["records"].(*types.AttributeValueMemberN); !ok || records.Value != "42" {
+				t.Errorf("unexpected records: %v", input.Item["records"])
+			}
+			if offset, ok := input.Item["offset"].(*types.AttributeValueMemberN); !ok || offset.Value != "1024" {
+				t.Errorf("unexpected offset: %v", input.Item["offset"])
+			}
+			if expiresAt, ok := input.Item["expiresAt"].(*types.AttributeValueMemberN); !ok || expiresAt.Value != "1700000060" {
+				t.Errorf("unexpected expiry: %v", input.Item["expiresAt"])
+			}
+			return nil, errSentinel
+		},
+	}, "table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.TTL = time.Minute
+	store.Now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	if err := store.Put(context.Background(), "a", checkpoint.Checkpoint{Records: 42, Offset: 1024}); !errors.Is(err, errSentinel) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewDynamoDBStore(t *testing.T) {
+	t.Parallel()
+
+	if _, err := checkpoint.NewDynamoDBStore(&awstest.DynamoDBClient{}, ""); !errors.Is(err, checkpoint.ErrMissingTableName) {
+		t.Fatalf("expected ErrMissingTableName, got %v", err)
+	}
+}
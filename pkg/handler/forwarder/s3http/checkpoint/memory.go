@@ -0,0 +1,43 @@
+package checkpoint
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps checkpoints in memory. It is intended for testing.
+type MemoryStore struct {
+	items map[string]Checkpoint
+	sync.Mutex
+}
+
+var _ Store = &MemoryStore{}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (Checkpoint, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.items[key], nil
+}
+
+func (m *MemoryStore) Put(_ context.Context, key string, c Checkpoint) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.items == nil {
+		m.items = make(map[string]Checkpoint)
+	}
+	m.items[key] = c
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: make(map[string]Checkpoint),
+	}
+}
@@ -0,0 +1,36 @@
+package checkpoint_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := checkpoint.NewMemoryStore()
+
+	if c, _ := store.Get(ctx, "a"); c != (checkpoint.Checkpoint{}) {
+		t.Fatalf("unexpected checkpoint %v", c)
+	}
+
+	expect := checkpoint.Checkpoint{Records: 10, Offset: 120}
+	if err := store.Put(ctx, "a", expect); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ := store.Get(ctx, "a"); c != expect {
+		t.Fatalf("expected %v, got %v", expect, c)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ := store.Get(ctx, "a"); c != (checkpoint.Checkpoint{}) {
+		t.Fatalf("expected checkpoint to be deleted, got %v", c)
+	}
+}
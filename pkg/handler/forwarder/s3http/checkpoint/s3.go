@@ -0,0 +1,121 @@
+package checkpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var (
+	ErrMissingBucket       = errors.New("missing bucket")
+	ErrMalformedCheckpoint = errors.New("malformed checkpoint")
+)
+
+type S3Client interface {
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// S3Store records checkpoints as objects under a prefix. Object names are
+// derived from a hash of the checkpoint key, since keys may exceed the
+// maximum length of an object key. Checkpoints for objects which were never
+// fully delivered are not removed, and should be expired through a
+// lifecycle rule.
+type S3Store struct {
+	Client S3Client
+	Bucket string
+	Prefix string
+}
+
+var _ Store = &S3Store{}
+
+func (s *S3Store) objectKey(key string) *string {
+	sum := sha256.Sum256([]byte(key))
+	return aws.String(s.Prefix + hex.EncodeToString(sum[:]))
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (Checkpoint, error) {
+	out, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    s.objectKey(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	switch {
+	case errors.As(err, &noSuchKey):
+		return Checkpoint{}, nil
+	case err != nil:
+		return Checkpoint{}, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to read object: %w", err)
+	}
+	return parseCheckpoint(string(data))
+}
+
+// parseCheckpoint parses the record count, optionally followed by the
+// byte offset. Checkpoints written before offsets were recorded contain
+// only the record count.
+func parseCheckpoint(s string) (c Checkpoint, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Checkpoint{}, fmt.Errorf("%w: %q", ErrMalformedCheckpoint, s)
+	}
+	if c.Records, err = strconv.Atoi(fields[0]); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to parse records: %w", err)
+	}
+	if len(fields) > 1 {
+		if c.Offset, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return Checkpoint{}, fmt.Errorf("failed to parse offset: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, c Checkpoint) error {
+	if _, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         s.objectKey(key),
+		Body:        strings.NewReader(strconv.Itoa(c.Records) + " " + strconv.FormatInt(c.Offset, 10)),
+		ContentType: aws.String("text/plain"),
+	}); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if _, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    s.objectKey(key),
+	}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func NewS3Store(client S3Client, bucket, prefix string) (*S3Store, error) {
+	if bucket == "" {
+		return nil, ErrMissingBucket
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &S3Store{
+		Client: client,
+		Bucket: bucket,
+		Prefix: prefix,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package checkpoint_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+func TestS3Store(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		objects = make(map[string]string)
+	)
+
+	client := &awstest.S3Client{
+		GetObjectFunc: func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			data, ok := objects[aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key)]
+			if !ok {
+				return nil, &types.NoSuchKey{}
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(data))}, nil
+		},
+		PutObjectFunc: func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			data, err := io.ReadAll(input.Body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read body: %w", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			objects[aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key)] = string(data)
+			return &s3.PutObjectOutput{}, nil
+		},
+		DeleteObjectFunc: func(_ context.Context, input *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(objects, aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key))
+			return &s3.DeleteObjectOutput{}, nil
+		},
+	}
+
+	store, err := checkpoint.New("s3://bucket/checkpoints", client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if c, err := store.Get(ctx, "a"); err != nil || c != (checkpoint.Checkpoint{}) {
+		t.Fatalf("unexpected checkpoint %v: %v", c, err)
+	}
+
+	expect := checkpoint.Checkpoint{Records: 42, Offset: 1024}
+	if err := store.Put(ctx, "a", expect); err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range objects {
+		if !strings.HasPrefix(name, "bucket/checkpoints/") {
+			t.Errorf("unexpected object %q", name)
+		}
+	}
+
+	if c, err := store.Get(ctx, "a"); err != nil || c != expect {
+		t.Fatalf("expected %v, got %v: %v", expect, c, err)
+	}
+
+	// checkpoints may predate offsets
+	for name := range objects {
+		objects[name] = "42\n"
+	}
+	if c, err := store.Get(ctx, "a"); err != nil || c != (checkpoint.Checkpoint{Records: 42}) {
+		t.Fatalf("unexpected checkpoint %v: %v", c, err)
+	}
+
+	for name := range objects {
+		objects[name] = "42 1024 0"
+	}
+	if _, err := store.Get(ctx, "a"); !errors.Is(err, checkpoint.ErrMalformedCheckpoint) {
+		t.Fatalf("expected ErrMalformedCheckpoint, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Fatalf("expected checkpoint to be deleted: %v", objects)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		URI       string
+		ExpectErr error
+	}{
+		{URI: "s3://bucket"},
+		{URI: "dynamodb://table"},
+		{URI: "s3:///prefix", ExpectErr: checkpoint.ErrMissingBucket},
+		{URI: "dynamodb://", ExpectErr: checkpoint.ErrMissingTableName},
+		{URI: "https://example.com", ExpectErr: checkpoint.ErrUnsupportedURI},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.URI, func(t *testing.T) {
+			t.Parallel()
+			_, err := checkpoint.New(tc.URI, &awstest.S3Client{}, &awstest.DynamoDBClient{})
+			if !errors.Is(err, tc.ExpectErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var ErrUnsupportedURI = errors.New("unsupported checkpoint URI")
+
+// Checkpoint is the position up to which an object has been delivered.
+type Checkpoint struct {
+	// Records is the number of leading records delivered.
+	Records int
+	// Offset is the byte offset within the object following the last
+	// delivered record, or zero if unknown. It is only recorded for
+	// uncompressed input, and allows a retry to read the remainder of the
+	// object through a ranged request.
+	Offset int64
+}
+
+// Store records how many records of an object have been delivered, so that
+// a retry can resume where a previous attempt failed.
+type Store interface {
+	// Get returns the checkpoint for key, or the zero value if no
+	// checkpoint exists.
+	Get(ctx context.Context, key string) (Checkpoint, error)
+	// Put records the checkpoint for key.
+	Put(ctx context.Context, key string, c Checkpoint) error
+	// Delete removes the checkpoint for key, once all records have been
+	// delivered.
+	Delete(ctx context.Context, key string) error
+}
+
+// Key builds a checkpoint key for an object, or a byte range of an object,
+// forwarded to a destination. Since records are counted after decoding,
+// the key includes the content type and encoding. An empty key is returned
+// if the object has no ETag, since we cannot tell apart different revisions
+// of the same object.
+func Key(destination, bucket, key, etag, byteRange, contentType, contentEncoding string) string {
+	if etag == "" {
+		return ""
+	}
+	return strings.Join([]string{destination, bucket, key, etag, byteRange, contentType, contentEncoding}, "|")
+}
+
+// New returns a store for a URI of the form s3://bucket/prefix or
+// dynamodb://table.
+func New(uri string, s3Client S3Client, dynamoDBClient DynamoDBClient) (Store, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedURI, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		return NewS3Store(s3Client, u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "dynamodb":
+		return NewDynamoDBStore(dynamoDBClient, u.Host)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedURI, uri)
+	}
+}
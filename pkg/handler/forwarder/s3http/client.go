@@ -1,20 +1,28 @@
 package s3http
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/seekable"
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/batch"
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/decoders"
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/request"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/sniff"
 )
 
 var (
@@ -23,9 +31,14 @@ var (
 	errMissingBucket     = fmt.Errorf("missing bucket")
 	errMissingKey        = fmt.Errorf("missing key")
 	errMissingBody       = fmt.Errorf("missing body")
+	errEncodedRange      = fmt.Errorf("cannot copy range of an encoded object")
 )
 
-const copyObjectMemoryLimitBytes int64 = 32 * 1024 * 1024
+const (
+	copyObjectMemoryLimitBytes int64 = 32 * 1024 * 1024
+
+	instrumentationName = "github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http"
+)
 
 type GetObjectAPIClient interface {
 	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
@@ -36,6 +49,44 @@ type Client struct {
 	GetObjectAPIClient
 	RequestBuilder *request.Builder
 	GzipLevel      *int
+
+	// MaxBatchRecords and MaxBatchLatency flush batches before they reach
+	// the maximum size, if set.
+	MaxBatchRecords int
+	MaxBatchLatency time.Duration
+
+	// CheckpointStore records progress through objects, so that a retry
+	// resumes after the last delivered batch.
+	CheckpointStore checkpoint.Store
+
+	// Coalesce combines records from concurrent copies with the same
+	// content type into shared requests. Objects uploaded directly through
+	// PutObject are always submitted on their own.
+	Coalesce bool
+
+	// Tracer starts a span for each shared request.
+	Tracer trace.Tracer
+
+	// batchSizeLimit retains the batch size accepted by the destination
+	// after a request was rejected as too large.
+	batchSizeLimit batch.SizeLimit
+
+	coalescer batch.Coalescer
+}
+
+type sourceRangeContext struct{}
+
+// WithSourceRange returns a context which restricts CopyObject to a byte
+// range of the source object. The range is formatted as an HTTP Range
+// header, e.g. bytes=0-1023. Only objects without a content encoding can
+// be copied in ranges.
+func WithSourceRange(ctx context.Context, byteRange string) context.Context {
+	return context.WithValue(ctx, sourceRangeContext{}, byteRange)
+}
+
+func sourceRangeFromContext(ctx context.Context) string {
+	byteRange, _ := ctx.Value(sourceRangeContext{}).(string)
+	return byteRange
 }
 
 func queryUnescapeOrOriginal(s string) string {
@@ -109,8 +160,24 @@ func toPutInput(copyInput *s3.CopyObjectInput, body io.Reader, contentType, cont
 	return in
 }
 
-func toCopyOutput(*s3.PutObjectOutput) *s3.CopyObjectOutput {
-	return nil
+// bytesCopiedKey identifies the number of source bytes read by CopyObject
+// within the result metadata.
+type bytesCopiedKey struct{}
+
+// BytesCopied returns the number of bytes read from the source object by
+// CopyObject, if known.
+func BytesCopied(out *s3.CopyObjectOutput) (int64, bool) {
+	if out == nil {
+		return 0, false
+	}
+	n, ok := out.ResultMetadata.Get(bytesCopiedKey{}).(int64)
+	return n, ok
+}
+
+func toCopyOutput(_ *s3.PutObjectOutput, bytesCopied int64) *s3.CopyObjectOutput {
+	out := &s3.CopyObjectOutput{}
+	out.ResultMetadata.Set(bytesCopiedKey{}, bytesCopied)
+	return out
 }
 
 // CopyObject is treated as a GetObject call with our S3 client, and a PutObject to our HTTP destination.
@@ -123,6 +190,11 @@ func (c *Client) CopyObject(ctx context.Context, params *s3.CopyObjectInput, opt
 		return nil, fmt.Errorf("failed to copy object: %w", err)
 	}
 
+	byteRange := sourceRangeFromContext(ctx)
+	if byteRange != "" {
+		getInput.Range = aws.String(byteRange)
+	}
+
 	getResp, err := c.GetObject(ctx, getInput, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get object: %w", err)
@@ -135,10 +207,52 @@ func (c *Client) CopyObject(ctx context.Context, params *s3.CopyObjectInput, opt
 
 	if getResp.ContentLength != nil && *getResp.ContentLength == 0 {
 		logger.V(6).Info("skipping empty file")
-		return toCopyOutput(nil), nil
+		return toCopyOutput(nil, 0), nil
+	}
+
+	// content is inferred from the leading bytes, before deciding whether
+	// to resume from a checkpoint
+	body := bufio.NewReaderSize(getResp.Body, sniff.PeekSize)
+	peeked, err := body.Peek(sniff.PeekSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read object body: %w", err)
+	}
+
+	putInput := toPutInput(params, nil, getResp.ContentType, getResp.ContentEncoding)
+
+	if err := inferContent(ctx, params, putInput, bytes.NewReader(peeked)); err != nil {
+		return nil, fmt.Errorf("failed to inspect object body: %w", err)
+	}
+
+	// a range of an encoded object cannot be decoded
+	if !isIdentity(putInput.ContentEncoding) && byteRange != "" {
+		return nil, fmt.Errorf("%w: %q", errEncodedRange, aws.ToString(putInput.ContentEncoding))
 	}
 
-	seekableBody, cleanup, err := seekable.FromReader(getResp.Body, copyObjectMemoryLimitBytes)
+	if c.Coalesce {
+		ctx = withCoalesce(ctx)
+	}
+
+	bytesCopied := aws.ToInt64(getResp.ContentLength)
+	if state := c.getCheckpoint(ctx, getInput, getResp, putInput, byteRange); state != nil {
+		ctx = withObjectCheckpoint(ctx, state)
+
+		if state.Ranged {
+			rangeResp, err := c.getRemainder(ctx, getInput, getResp, state.Offset, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get object: %w", err)
+			}
+			defer func() {
+				if closeErr := rangeResp.Body.Close(); closeErr != nil && err == nil {
+					logger.Error(closeErr, "failed to close response body")
+				}
+			}()
+			body.Reset(rangeResp.Body)
+			bytesCopied = aws.ToInt64(rangeResp.ContentLength)
+		}
+	}
+
+	seekableBody, cleanup, err := seekable.FromReader(body, copyObjectMemoryLimitBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare object body: %w", err)
 	}
@@ -147,22 +261,73 @@ func (c *Client) CopyObject(ctx context.Context, params *s3.CopyObjectInput, opt
 			logger.V(4).Error(cleanupErr, "failed to cleanup seekable body")
 		}
 	}()
+	putInput.Body = seekableBody
+
+	putResp, err := c.PutObject(ctx, putInput, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put object: %w", err)
+	}
+	return toCopyOutput(putResp, bytesCopied), nil
+}
 
-	putInput := toPutInput(params, seekableBody, getResp.ContentType, getResp.ContentEncoding)
+func isIdentity(contentEncoding *string) bool {
+	ce := aws.ToString(contentEncoding)
+	return ce == "" || ce == "identity"
+}
 
-	// Infer content-encoding for gzip files when not already set by override rules.
-	// This handles the case where a custom override sets content-type but not content-encoding,
-	// causing Sets.Apply to stop before infer/v1's gzip rule can run.
-	// We check the source key (getInput.Key) since the destination key may differ or lack the .gz suffix.
-	if aws.ToString(putInput.ContentEncoding) == "" && strings.HasSuffix(aws.ToString(getInput.Key), ".gz") {
-		putInput.ContentEncoding = aws.String("gzip")
+// getCheckpoint looks up the checkpoint for an object, or returns nil if
+// checkpoints do not apply. The checkpoint key is only known here, since
+// PutObject has no notion of the source object revision. If the checkpoint
+// records an offset, and the object is not encoded, the remainder of the
+// object can be read through a ranged request.
+func (c *Client) getCheckpoint(ctx context.Context, getInput *s3.GetObjectInput, getResp *s3.GetObjectOutput, putInput *s3.PutObjectInput, byteRange string) *objectCheckpoint {
+	if c.CheckpointStore == nil || c.Coalesce {
+		return nil
 	}
 
-	putResp, err := c.PutObject(ctx, putInput, opts...)
+	key := checkpoint.Key(
+		c.RequestBuilder.URL,
+		aws.ToString(getInput.Bucket),
+		aws.ToString(getInput.Key),
+		aws.ToString(getResp.ETag),
+		byteRange,
+		aws.ToString(putInput.ContentType),
+		aws.ToString(putInput.ContentEncoding),
+	)
+	if key == "" {
+		return nil
+	}
+
+	logger := logr.FromContextOrDiscard(ctx)
+	state := &objectCheckpoint{Key: key}
+	cp, err := c.CheckpointStore.Get(ctx, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to put object: %w", err)
+		logger.Error(err, "failed to get checkpoint")
+		return state
+	}
+	state.Checkpoint = cp
+	state.Ranged = cp.Offset > 0 && byteRange == "" && isIdentity(putInput.ContentEncoding)
+	if cp.Records > 0 {
+		logger.V(3).Info("resuming from checkpoint", "records", cp.Records, "offset", cp.Offset, "ranged", state.Ranged)
+	}
+	return state
+}
+
+// getRemainder reads an object from offset onwards. The request is
+// conditional on the ETag, so that we do not resume within a different
+// revision of the object.
+func (c *Client) getRemainder(ctx context.Context, getInput *s3.GetObjectInput, getResp *s3.GetObjectOutput, offset int64, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	// a range starting at the end of the object is not satisfiable
+	if getResp.ContentLength != nil && offset >= *getResp.ContentLength {
+		return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(nil)), ContentLength: aws.Int64(0)}, nil
 	}
-	return toCopyOutput(putResp), nil
+
+	rangeInput := *getInput
+	rangeInput.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	rangeInput.IfMatch = getResp.ETag
+
+	// nolint:wrapcheck
+	return c.GetObject(ctx, &rangeInput, opts...)
 }
 
 // PutObject uploads to HTTP destination.
@@ -193,17 +358,62 @@ func (c *Client) PutObject(ctx context.Context, params *s3.PutObjectInput, _ ...
 		headers["Content-Encoding"] = "gzip"
 	}
 
-	err = batch.Run(ctx, &batch.RunInput{
+	if coalesceFromContext(ctx) {
+		if err := c.putCoalesced(ctx, params, dec, headers); err != nil {
+			return nil, fmt.Errorf("failed to process: %w", err)
+		}
+		return
+	}
+
+	runInput := &batch.RunInput{
 		Decoder:   dec,
 		GzipLevel: c.GzipLevel,
 		Handler: c.RequestBuilder.With(map[string]string{
 			"content-type": aws.ToString(params.ContentType),
 			"key":          aws.ToString(params.Key),
 		}, headers),
-	})
+		TooLarge:   request.IsTooLarge,
+		SizeLimit:  &c.batchSizeLimit,
+		MaxRecords: &c.MaxBatchRecords,
+		MaxLatency: &c.MaxBatchLatency,
+	}
+
+	state := objectCheckpointFromContext(ctx)
+	if c.CheckpointStore == nil || state == nil {
+		if err := batch.Run(ctx, runInput); err != nil {
+			return nil, fmt.Errorf("failed to process: %w", err)
+		}
+		return
+	}
+
+	if state.Ranged {
+		runInput.Start, runInput.StartOffset = state.Records, state.Offset
+	} else {
+		runInput.Skip = state.Records
+	}
+
+	// offsets within decoded content cannot be used to resume encoded objects
+	trackOffset := isIdentity(params.ContentEncoding)
+	writer := newCheckpointWriter(ctx, c.CheckpointStore, state.Key, checkpointInterval)
+	runInput.Progress = func(_ context.Context, records int, offset int64) {
+		if !trackOffset {
+			offset = 0
+		}
+		writer.Update(records, offset)
+	}
+
+	err = batch.Run(ctx, runInput)
+	written := writer.Stop()
 	if err != nil {
+		writer.Flush(ctx)
 		return nil, fmt.Errorf("failed to process: %w", err)
 	}
+
+	if written || state.Records > 0 {
+		if err := c.CheckpointStore.Delete(ctx, state.Key); err != nil {
+			logger.Error(err, "failed to delete checkpoint")
+		}
+	}
 	return
 }
 
@@ -212,9 +422,19 @@ func New(cfg *Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to validate config: %w", err)
 	}
 
+	tracerProvider := cfg.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = noop.NewTracerProvider()
+	}
+
 	return &Client{
 		GetObjectAPIClient: cfg.GetObjectAPIClient,
+		Tracer:             tracerProvider.Tracer(instrumentationName),
 		GzipLevel:          cfg.GzipLevel,
+		CheckpointStore:    cfg.CheckpointStore,
+		MaxBatchRecords:    cfg.MaxBatchRecords,
+		MaxBatchLatency:    cfg.MaxBatchLatency,
+		Coalesce:           cfg.Coalesce,
 		RequestBuilder: &request.Builder{
 			URL:    cfg.DestinationURI,
 			Client: cfg.HTTPClient,
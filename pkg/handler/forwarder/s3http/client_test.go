@@ -9,16 +9,21 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/go-cmp/cmp"
 	"github.com/lithammer/dedent"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
 	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
 )
 
@@ -31,8 +36,9 @@ func TestClientPut(t *testing.T) {
 
 	testcases := []struct {
 		*s3.PutObjectInput
-		Path   string
-		Expect string
+		Path     string
+		Coalesce bool
+		Expect   string
 	}{
 		{
 			PutObjectInput: &s3.PutObjectInput{
@@ -71,6 +77,26 @@ func TestClientPut(t *testing.T) {
 					{"hello": "world"}
 				`),
 		},
+		{
+			// only copies are coalesced
+			PutObjectInput: &s3.PutObjectInput{
+				Bucket:      aws.String("test"),
+				Key:         aws.String("deeply/nested/example.json"),
+				ContentType: aws.String("application/json"),
+				Body:        strings.NewReader(`{"hello": "world"}`),
+			},
+			Coalesce: true,
+			Expect: format(`
+				POST /?content-type=application%2Fjson&key=deeply%2Fnested%2Fexample.json HTTP/1.1
+				Host: 127.0.0.1:<removed>
+				Accept-Encoding: gzip
+				Content-Length: 19
+				Content-Type: application/x-ndjson
+				User-Agent: Go-http-client/1.1
+
+				{"hello": "world"}
+			`),
+		},
 	}
 
 	for i, tt := range testcases {
@@ -99,6 +125,7 @@ func TestClientPut(t *testing.T) {
 				DestinationURI:     fmt.Sprintf("%s/%s", s.URL, tt.Path),
 				GetObjectAPIClient: &awstest.S3Client{},
 				HTTPClient:         s.Client(),
+				Coalesce:           tt.Coalesce,
 			})
 			if err != nil {
 				t.Fatal(err)
@@ -117,6 +144,80 @@ func TestClientPut(t *testing.T) {
 	}
 }
 
+// TestCopyObjectCoalesce verifies that coalesced copies carry the source key
+// in-band, and that shared requests are traced independently of the copy
+// which created them.
+func TestCopyObjectCoalesce(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		reqPath string
+		reqBody bytes.Buffer
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		reqPath = r.URL.String()
+		reqBody.Write(body)
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	client, err := s3http.New(&s3http.Config{
+		DestinationURI: srv.URL,
+		GetObjectAPIClient: &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:        io.NopCloser(strings.NewReader(`{"hello": "world"}`)),
+					ContentType: aws.String("application/json"),
+				}, nil
+			},
+		},
+		HTTPClient:     srv.Client(),
+		Coalesce:       true,
+		TracerProvider: tracerProvider,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := tracerProvider.Tracer("test").Start(context.Background(), "copy")
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String("dst-bucket"),
+		Key:        aws.String("deeply/nested/example.json"),
+		CopySource: aws.String("src-bucket/deeply/nested/example.json"),
+	})
+	span.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("/?content-type=application%2Fjson", reqPath); diff != "" {
+		t.Error("unexpected path", diff)
+	}
+	if diff := cmp.Diff(`{"key":"deeply/nested/example.json","record":{"hello":"world"}}`+"\n", reqBody.String()); diff != "" {
+		t.Error("unexpected body", diff)
+	}
+
+	var batches int
+	for _, s := range recorder.Ended() {
+		if s.Name() != "coalesced batch" {
+			continue
+		}
+		batches++
+		if s.Parent().IsValid() {
+			t.Errorf("expected shared request to be traced in its own trace, got parent %v", s.Parent())
+		}
+	}
+	if batches != 1 {
+		t.Errorf("expected 1 coalesced batch span, got %d", batches)
+	}
+}
+
 // TestCopyObjectGzipInference verifies that when a custom content-type override sets
 // "application/x-aws-elasticloadbalancing" but leaves content-encoding nil, CopyObject
 // still correctly infers "gzip" from the ".gz" key suffix and decompresses the body.
@@ -176,7 +277,7 @@ func TestCopyObjectGzipInference(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = client.CopyObject(context.Background(), &s3.CopyObjectInput{
+	out, err := client.CopyObject(context.Background(), &s3.CopyObjectInput{
 		Bucket:          aws.String("dst-bucket"),
 		Key:             aws.String("output/alb.log"),
 		CopySource:      aws.String("src-bucket/logs/alb-2024-01-01.log.gz"),
@@ -187,6 +288,10 @@ func TestCopyObjectGzipInference(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	if n, ok := s3http.BytesCopied(out); !ok || n != int64(len(gzBytes)) {
+		t.Errorf("expected %d bytes copied, got %d", len(gzBytes), n)
+	}
+
 	got := reqBody.String()
 	t.Logf("received body:\n%s", got)
 
@@ -213,3 +318,319 @@ func TestCopyObjectGzipInference(t *testing.T) {
 		}
 	}
 }
+
+// TestCopyObjectContentInference verifies that CopyObject inspects the body
+// of objects for which neither override rules nor object metadata provide a
+// usable content type.
+func TestCopyObjectContentInference(t *testing.T) {
+	t.Parallel()
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := io.WriteString(gw, `[{"a": 1}, {"a": 2}]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		Body              []byte
+		SourceContentType *string
+		ContentType       *string
+		Expect            string
+	}{
+		{
+			// gzip compressed JSON array, with no hint in key or metadata
+			Body:              gzBuf.Bytes(),
+			SourceContentType: aws.String("binary/octet-stream"),
+			Expect: format(`
+				/?content-type=application%2Fx-json-array&key=output%2Fdata
+				{"a": 1}
+				{"a": 2}
+			`),
+		},
+		{
+			// explicit content types are not replaced
+			Body:        []byte("{\"a\": 1}\n{\"a\": 2}\n"),
+			ContentType: aws.String("text/plain"),
+			Expect: format(`
+				/?content-type=text%2Fplain&key=output%2Fdata
+				{"text":"{\"a\": 1}\n"}
+				{"text":"{\"a\": 2}\n"}
+			`),
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu  sync.Mutex
+				got strings.Builder
+			)
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				body, _ := io.ReadAll(r.Body)
+				got.WriteString(r.URL.RequestURI() + "\n")
+				got.Write(body)
+			}))
+			defer srv.Close()
+
+			client, err := s3http.New(&s3http.Config{
+				DestinationURI: srv.URL,
+				GetObjectAPIClient: &awstest.S3Client{
+					GetObjectFunc: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+						return &s3.GetObjectOutput{
+							Body:        io.NopCloser(bytes.NewReader(tc.Body)),
+							ContentType: tc.SourceContentType,
+						}, nil
+					},
+				},
+				HTTPClient: srv.Client(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.CopyObject(context.Background(), &s3.CopyObjectInput{
+				Bucket:      aws.String("dst-bucket"),
+				Key:         aws.String("output/data"),
+				CopySource:  aws.String("src-bucket/data"),
+				ContentType: tc.ContentType,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(got.String(), tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// TestCopyObjectGzipInferenceWithoutSuffix verifies that gzip content is
+// detected from the object body when neither the metadata nor the key
+// indicate a content encoding.
+func TestCopyObjectGzipInferenceWithoutSuffix(t *testing.T) {
+	t.Parallel()
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := io.WriteString(gw, "{\"n\":0}\n{\"n\":1}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		reqBody bytes.Buffer
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		reqBody.Write(body)
+	}))
+	defer srv.Close()
+
+	client, err := s3http.New(&s3http.Config{
+		DestinationURI: srv.URL,
+		GetObjectAPIClient: &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:        io.NopCloser(bytes.NewReader(gzBuf.Bytes())),
+					ContentType: aws.String("application/x-ndjson"),
+				}, nil
+			},
+		},
+		HTTPClient: srv.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.CopyObject(context.Background(), &s3.CopyObjectInput{
+		Bucket:     aws.String("dst-bucket"),
+		Key:        aws.String("output/data"),
+		CopySource: aws.String("src-bucket/logs/data"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("{\"n\":0}\n{\"n\":1}\n", reqBody.String()); diff != "" {
+		t.Error("unexpected body", diff)
+	}
+}
+
+// TestCopyObjectCheckpoint verifies that CopyObject skips records delivered
+// by a previous attempt, and removes the checkpoint once done.
+func TestCopyObjectCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	data := "{\"n\":0}\n{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"
+
+	var (
+		mu      sync.Mutex
+		reqBody bytes.Buffer
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		reqBody.Write(body)
+	}))
+	defer srv.Close()
+
+	store := checkpoint.NewMemoryStore()
+	key := checkpoint.Key(srv.URL, "src-bucket", "data.json", `"etag"`, "", "application/x-ndjson", "")
+	if err := store.Put(context.Background(), key, checkpoint.Checkpoint{Records: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := s3http.New(&s3http.Config{
+		DestinationURI: srv.URL,
+		GetObjectAPIClient: &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:        io.NopCloser(strings.NewReader(data)),
+					ContentType: aws.String("application/x-ndjson"),
+					ETag:        aws.String(`"etag"`),
+				}, nil
+			},
+		},
+		HTTPClient:      srv.Client(),
+		CheckpointStore: store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.CopyObject(context.Background(), &s3.CopyObjectInput{
+		Bucket:     aws.String("dst-bucket"),
+		Key:        aws.String("data.json"),
+		CopySource: aws.String("src-bucket/data.json"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("{\"n\":2}\n{\"n\":3}\n", reqBody.String()); diff != "" {
+		t.Error("unexpected body", diff)
+	}
+
+	if c, _ := store.Get(context.Background(), key); c != (checkpoint.Checkpoint{}) {
+		t.Errorf("expected checkpoint to be deleted, got %v", c)
+	}
+}
+
+// TestCopyObjectResumeRange verifies that a failed CopyObject records the
+// offset of the last delivered record, and that a retry reads only the
+// remainder of the object.
+func TestCopyObjectResumeRange(t *testing.T) {
+	t.Parallel()
+
+	data := "{\"n\":0}\n{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"
+
+	var (
+		mu       sync.Mutex
+		reqBody  bytes.Buffer
+		fail     = true
+		getInput []*s3.GetObjectInput
+		store    = checkpoint.NewMemoryStore()
+		key      string
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if fail && strings.Contains(string(body), `"n":2`) {
+			// batches are submitted concurrently, so wait for the preceding
+			// batches to be checkpointed before failing
+			for c, _ := store.Get(r.Context(), key); c.Records < 2; c, _ = store.Get(r.Context(), key) {
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+			}
+			w.WriteHeader(400)
+			return
+		}
+		reqBody.Write(body)
+	}))
+	defer srv.Close()
+	key = checkpoint.Key(srv.URL, "src-bucket", "data.json", `"etag"`, "", "application/x-ndjson", "")
+
+	client, err := s3http.New(&s3http.Config{
+		DestinationURI: srv.URL,
+		GetObjectAPIClient: &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				getInput = append(getInput, input)
+
+				body := data
+				if input.Range != nil {
+					var offset int
+					if _, err := fmt.Sscanf(aws.ToString(input.Range), "bytes=%d-", &offset); err != nil {
+						return nil, fmt.Errorf("unexpected range: %w", err)
+					}
+					body = data[offset:]
+				}
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(strings.NewReader(body)),
+					ContentLength: aws.Int64(int64(len(body))),
+					ContentType:   aws.String("application/x-ndjson"),
+					ETag:          aws.String(`"etag"`),
+				}, nil
+			},
+		},
+		HTTPClient:      srv.Client(),
+		CheckpointStore: store,
+		MaxBatchRecords: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copyInput := &s3.CopyObjectInput{
+		Bucket:     aws.String("dst-bucket"),
+		Key:        aws.String("data.json"),
+		CopySource: aws.String("src-bucket/data.json"),
+	}
+	if _, err := client.CopyObject(context.Background(), copyInput); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if c, _ := store.Get(context.Background(), key); c != (checkpoint.Checkpoint{Records: 2, Offset: 15}) {
+		t.Fatalf("unexpected checkpoint %v", c)
+	}
+
+	mu.Lock()
+	fail, getInput = false, nil
+	reqBody.Reset()
+	mu.Unlock()
+
+	out, err := client.CopyObject(context.Background(), copyInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s3http.BytesCopied(out); n != int64(len(data)-15) {
+		t.Errorf("unexpected bytes copied: %d", n)
+	}
+
+	// batches may be delivered in any order
+	records := strings.Fields(reqBody.String())
+	sort.Strings(records)
+	if diff := cmp.Diff([]string{`{"n":2}`, `{"n":3}`}, records); diff != "" {
+		t.Error("unexpected records", diff)
+	}
+	if len(getInput) != 2 || aws.ToString(getInput[1].Range) != "bytes=15-" || aws.ToString(getInput[1].IfMatch) != `"etag"` {
+		t.Errorf("expected conditional ranged request, got %v", getInput)
+	}
+	if c, _ := store.Get(context.Background(), key); c != (checkpoint.Checkpoint{}) {
+		t.Errorf("expected checkpoint to be deleted, got %v", c)
+	}
+}
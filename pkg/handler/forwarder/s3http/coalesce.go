@@ -0,0 +1,134 @@
+package s3http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/batch"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/decoders"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/request"
+)
+
+var contentTypeKey = attribute.Key("content-type")
+
+type coalesceContext struct{}
+
+// withCoalesce marks an upload as eligible for sharing requests with
+// concurrent uploads. Only CopyObject sets it, since callers of PutObject,
+// such as those writing SQS messages or errors, expect their records to be
+// submitted as is.
+func withCoalesce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, coalesceContext{}, true)
+}
+
+func coalesceFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(coalesceContext{}).(bool)
+	return v
+}
+
+// tracedHandler starts a span for each shared request, since requests are
+// submitted outside of the context of the copies contributing to them.
+type tracedHandler struct {
+	batch.Handler
+	Tracer      trace.Tracer
+	ContentType string
+}
+
+func (h *tracedHandler) Handle(ctx context.Context, r io.Reader) error {
+	ctx, span := h.Tracer.Start(ctx, "coalesced batch", trace.WithAttributes(contentTypeKey.String(h.ContentType)))
+	defer span.End()
+
+	err := h.Handler.Handle(ctx, r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	// nolint:wrapcheck
+	return err
+}
+
+// keyedRecord carries the source key of a record in-band, since batches
+// may contain records from several objects.
+type keyedRecord struct {
+	Key    string          `json:"key"`
+	Record json.RawMessage `json:"record"`
+}
+
+// keyedDecoder wraps each decoded record alongside its source key.
+type keyedDecoder struct {
+	decoders.Decoder
+	Key string
+}
+
+func (d *keyedDecoder) Decode(v any) error {
+	var record json.RawMessage
+	if err := d.Decoder.Decode(&record); err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	data, err := json.Marshal(keyedRecord{Key: d.Key, Record: record})
+	if err != nil {
+		return fmt.Errorf("failed to wrap record: %w", err)
+	}
+
+	if raw, ok := v.(*json.RawMessage); ok {
+		*raw = data
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal record: %w", err)
+	}
+	return nil
+}
+
+// Close releases the wrapped decoder.
+func (d *keyedDecoder) Close() error {
+	if closer, ok := d.Decoder.(io.Closer); ok {
+		// nolint:wrapcheck
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *Client) tracer() trace.Tracer {
+	if c.Tracer != nil {
+		return c.Tracer
+	}
+	return noop.Tracer{}
+}
+
+// putCoalesced uploads records in batches shared with concurrent uploads of
+// the same content type.
+func (c *Client) putCoalesced(ctx context.Context, params *s3.PutObjectInput, dec decoders.Decoder, headers map[string]string) error {
+	contentType := aws.ToString(params.ContentType)
+
+	// nolint:wrapcheck
+	return c.coalescer.Run(ctx, contentType, &batch.RunInput{
+		Decoder: &keyedDecoder{
+			Decoder: dec,
+			Key:     aws.ToString(params.Key),
+		},
+		GzipLevel: c.GzipLevel,
+		Handler: &tracedHandler{
+			Handler: c.RequestBuilder.With(map[string]string{
+				"content-type": contentType,
+			}, headers),
+			Tracer:      c.tracer(),
+			ContentType: contentType,
+		},
+		TooLarge:   request.IsTooLarge,
+		SizeLimit:  &c.batchSizeLimit,
+		MaxRecords: &c.MaxBatchRecords,
+		MaxLatency: &c.MaxBatchLatency,
+	})
+}
@@ -6,12 +6,18 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
 )
 
 var (
 	ErrInvalidDestination   = errors.New("invalid destination URI")
 	ErrMissingS3Client      = errors.New("missing S3 client")
 	ErrUnsupportedGzipLevel = errors.New("unsupported compression level")
+	ErrInvalidBatchLimit    = errors.New("invalid batch limit")
 )
 
 type Config struct {
@@ -19,6 +25,25 @@ type Config struct {
 	GetObjectAPIClient
 	HTTPClient *http.Client
 	GzipLevel  *int
+
+	// MaxBatchRecords and MaxBatchLatency optionally bound the number of
+	// records in each request, and how long records are buffered before
+	// being submitted.
+	MaxBatchRecords int
+	MaxBatchLatency time.Duration
+
+	// Coalesce combines records from concurrent copies with the same
+	// content type into shared requests. Each record is wrapped alongside
+	// its source key, and checkpoints are not used.
+	Coalesce bool
+
+	// TracerProvider is optional, and traces shared requests, which do not
+	// belong to any single copy.
+	TracerProvider trace.TracerProvider
+
+	// CheckpointStore is optional, and enables resuming partially
+	// uploaded objects.
+	CheckpointStore checkpoint.Store
 }
 
 func (c *Config) Validate() error {
@@ -46,5 +71,12 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.MaxBatchRecords < 0 {
+		errs = append(errs, fmt.Errorf("%w: max records must not be negative", ErrInvalidBatchLimit))
+	}
+	if c.MaxBatchLatency < 0 {
+		errs = append(errs, fmt.Errorf("%w: max latency must not be negative", ErrInvalidBatchLimit))
+	}
+
 	return errors.Join(errs...)
 }
@@ -48,6 +48,14 @@ func TestConfig(t *testing.T) {
 			},
 			ExpectError: s3http.ErrUnsupportedGzipLevel,
 		},
+		{
+			Config: s3http.Config{
+				DestinationURI:     "https://test",
+				MaxBatchRecords:    -1,
+				GetObjectAPIClient: &awstest.S3Client{},
+			},
+			ExpectError: s3http.ErrInvalidBatchLimit,
+		},
 	}
 
 	for i, tc := range testcases {
@@ -0,0 +1,48 @@
+package s3http
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-logr/logr"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/sniff"
+)
+
+// unknownContentTypes do not describe how to decode an object.
+var unknownContentTypes = map[string]struct{}{
+	"":                         {},
+	"application/octet-stream": {},
+	"binary/octet-stream":      {},
+}
+
+// inferContent sets content type and encoding by inspecting the leading
+// bytes of body. Values set by override rules or object metadata take
+// precedence over inferred values.
+func inferContent(ctx context.Context, params *s3.CopyObjectInput, putInput *s3.PutObjectInput, body io.ReadSeeker) error {
+	_, unknownType := unknownContentTypes[aws.ToString(putInput.ContentType)]
+	unknownEncoding := params.ContentEncoding == nil && aws.ToString(putInput.ContentEncoding) == ""
+	if !unknownType && !unknownEncoding {
+		return nil
+	}
+
+	data, err := sniff.Peek(body)
+	if err != nil {
+		return err
+	}
+
+	result := sniff.Detect(data)
+	logr.FromContextOrDiscard(ctx).V(4).Info("inferred content", "contentType", result.ContentType, "contentEncoding", result.ContentEncoding)
+
+	if unknownEncoding && result.ContentEncoding != "" {
+		putInput.ContentEncoding = aws.String(result.ContentEncoding)
+	}
+	// Inferring the content type of compressed data requires that we also
+	// know how to decompress it.
+	if unknownType && result.ContentType != "" && aws.ToString(putInput.ContentEncoding) == result.ContentEncoding {
+		putInput.ContentType = aws.String(result.ContentType)
+	}
+	return nil
+}
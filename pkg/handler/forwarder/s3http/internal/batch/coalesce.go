@@ -0,0 +1,186 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Coalescer combines records from concurrent runs into shared batches.
+// Runs with the same group key share a queue for as long as they overlap,
+// so that small inputs do not each result in a separate request. The zero
+// value is ready for use.
+type Coalescer struct {
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+// group is a queue shared by overlapping runs.
+type group struct {
+	*Queue
+	handle        func(context.Context, io.Reader) error
+	runs          int
+	maxRecordSize int
+}
+
+// owner tracks the batches containing records pushed by a single run.
+type owner struct {
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+func (o *owner) add() {
+	o.wg.Add(1)
+}
+
+func (o *owner) done(err error) {
+	if err != nil {
+		o.mu.Lock()
+		if o.err == nil {
+			o.err = err
+		}
+		o.mu.Unlock()
+	}
+	o.wg.Done()
+}
+
+// wait for all batches to be handled, returning the first error.
+func (o *owner) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.err
+	case <-ctx.Done():
+		return fmt.Errorf("cancelled wait: %w", ctx.Err())
+	}
+}
+
+// Run pushes all records from a decoder into the queue for key, and waits
+// until the batches containing them have been handled. The handler and
+// limits of the run which creates a group apply to all runs sharing it.
+// Skip, Start and Progress are not supported, since batches mix records
+// from several inputs.
+func (c *Coalescer) Run(ctx context.Context, key string, r *RunInput) error {
+	if r == nil {
+		return nil
+	}
+
+	g := c.acquire(key, r)
+	o := &owner{}
+
+	err := g.push(r.Decoder, o)
+	if closeErr := closeDecoder(r.Decoder); err == nil {
+		err = closeErr
+	}
+	if releaseErr := c.release(key, g); err == nil {
+		err = releaseErr
+	}
+	if err != nil {
+		return err
+	}
+	return o.wait(ctx)
+}
+
+func (c *Coalescer) acquire(key string, r *RunInput) *group {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if g, ok := c.groups[key]; ok {
+		g.runs++
+		return g
+	}
+
+	maxConcurrency, maxRecordSize, cfg := r.limits()
+	g := &group{
+		Queue:         NewQueue(cfg),
+		handle:        r.Handler.Handle,
+		runs:          1,
+		maxRecordSize: maxRecordSize,
+	}
+
+	// the splitter accumulates rejected records, so each batch needs its own
+	if r.TooLarge != nil {
+		g.handle = func(ctx context.Context, body io.Reader) error {
+			splitter := r.newSplitHandler(cfg.Delimiter)
+			if err := splitter.Handle(ctx, body); err != nil {
+				return err
+			}
+			return splitter.err()
+		}
+	}
+
+	// The group outlives the run which created it, and its batches mix
+	// records from other runs, so batches are handled in a neutral context
+	// rather than one carrying the values of the first run.
+	for range maxConcurrency {
+		go g.process(context.Background())
+	}
+
+	if c.groups == nil {
+		c.groups = make(map[string]*group)
+	}
+	c.groups[key] = g
+	return g
+}
+
+// release a group, closing its queue if no other run shares it. Closing
+// flushes any buffered records.
+func (c *Coalescer) release(key string, g *group) error {
+	c.mu.Lock()
+	g.runs--
+	last := g.runs == 0
+	if last {
+		delete(c.groups, key)
+	}
+	c.mu.Unlock()
+
+	if !last {
+		return nil
+	}
+	if err := g.Close(); err != nil {
+		return fmt.Errorf("failed to close queue: %w", err)
+	}
+	return nil
+}
+
+func (g *group) push(dec Decoder, o *owner) error {
+	var v json.RawMessage
+	for dec.More() {
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		if g.maxRecordSize > 0 && len(v) > g.maxRecordSize {
+			continue
+		}
+
+		// records are pushed regardless of the context of an individual
+		// run, since other runs may share the batch being flushed
+		if err := g.pushFrom(context.Background(), v, 0, o); err != nil {
+			return fmt.Errorf("failed to push: %w", err)
+		}
+	}
+	return nil
+}
+
+// process batches until the queue is closed, notifying the runs which
+// contributed records to each batch.
+func (g *group) process(ctx context.Context) {
+	for batch := range g.ch {
+		err := g.handle(ctx, batch.Buffer)
+		bufPool.Put(batch.Buffer)
+		for _, o := range batch.owners {
+			o.done(err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package batch_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/batch"
+)
+
+// blockingDecoder signals once all records have been decoded, and then
+// blocks until released.
+type blockingDecoder struct {
+	*json.Decoder
+	decoded chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDecoder) More() bool {
+	if d.Decoder.More() {
+		return true
+	}
+	close(d.decoded)
+	<-d.release
+	return false
+}
+
+func newBlockingDecoder(input string, release chan struct{}) *blockingDecoder {
+	return &blockingDecoder{
+		Decoder: json.NewDecoder(strings.NewReader(input)),
+		decoded: make(chan struct{}),
+		release: release,
+	}
+}
+
+func TestCoalescer(t *testing.T) {
+	t.Parallel()
+
+	errSentinel := errors.New("sentinel")
+
+	testcases := []struct {
+		Name       string
+		HandlerErr error
+	}{
+		{
+			Name: "shared",
+		},
+		{
+			Name:       "error",
+			HandlerErr: errSentinel,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu        sync.Mutex
+				batches   []string
+				coalescer batch.Coalescer
+			)
+
+			handler := batch.HandlerFunc(func(_ context.Context, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				if err != nil {
+					return fmt.Errorf("failed to read: %w", err)
+				}
+				mu.Lock()
+				batches = append(batches, string(data))
+				mu.Unlock()
+				return tc.HandlerErr
+			})
+
+			released := make(chan struct{})
+			closed := make(chan struct{})
+			close(closed)
+
+			first := newBlockingDecoder(`{"n":"a"}`, released)
+			second := newBlockingDecoder(`{"n":"b"}`, closed)
+
+			errs := make([]error, 2)
+			var wg sync.WaitGroup
+			for i, dec := range []batch.Decoder{first, second} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = coalescer.Run(context.Background(), "group", &batch.RunInput{
+						Decoder: dec,
+						Handler: handler,
+					})
+				}()
+				// ensure records are pushed in order
+				<-dec.(*blockingDecoder).decoded
+			}
+
+			// the first run holds the batch open until it completes
+			close(released)
+			wg.Wait()
+
+			for i, err := range errs {
+				if !errors.Is(err, tc.HandlerErr) {
+					t.Errorf("run %d: unexpected error: %v", i, err)
+				}
+			}
+			if diff := cmp.Diff([]string{"{\"n\":\"a\"}\n{\"n\":\"b\"}\n"}, batches); diff != "" {
+				t.Error("unexpected batches", diff)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 )
 
 var (
@@ -21,10 +22,29 @@ type QueueConfig struct {
 	Capacity     int  // channel capacity
 	GzipLevel    *int // gzip compression level
 	Delimiter    []byte
+
+	// MaxRecords flushes a batch once it contains this many records.
+	MaxRecords int
+	// MaxLatency flushes a batch once this much time has elapsed since its
+	// first record was pushed, regardless of its size.
+	MaxLatency time.Duration
+
+	// Offset is the position of the first record, if resuming from a
+	// previous run, and InputOffset is the input offset preceding it.
+	Offset      int
+	InputOffset int64
+	// Progress is called with the number of records handled, counting
+	// from the start of the input, whenever all batches up to that point
+	// have been handled successfully. The offset is the input offset
+	// following the last handled record, or zero if unknown. Calls are
+	// serialized.
+	Progress func(ctx context.Context, records int, offset int64)
 }
 
 // Queue appends item to buffer until batch size is reached.
 type Queue struct {
+	// mu guards the current chunk, which may be flushed by a timer
+	mu sync.Mutex
 
 	// the chunk currently being appended to
 	buffer *bytes.Buffer
@@ -37,13 +57,93 @@ type Queue struct {
 	newWriterFunc func(*bytes.Buffer) (io.Writer, io.Closer)
 	written       int
 	maxBatchSize  int
-	ch            chan *bytes.Buffer // channel containing batches
+	ch            chan *pendingBatch // channel containing batches
 	delimiter     []byte
+
+	records    int // records in current chunk
+	maxRecords int
+	maxLatency time.Duration
+	timer      *time.Timer
+	batches    int   // number of chunks flushed, used to discard stale timers
+	timerErr   error // error from a flush triggered by timer
+	closed     bool
+
+	// owners of records in the current chunk, if the queue is shared
+	owners map[*owner]struct{}
+
+	// position counts records pushed or skipped, and flushed is the
+	// position at which the current chunk starts. The input offset
+	// follows the last record pushed or skipped.
+	position    int
+	flushed     int
+	inputOffset int64
+	progress    *progressTracker
+}
+
+// pendingBatch is a chunk covering records from start up to end, which
+// is followed by offset in the input.
+type pendingBatch struct {
+	*bytes.Buffer
+	start, end int
+	offset     int64
+	owners     []*owner
+}
+
+// progressTracker reports the position up to which all batches have been
+// handled, since batches may be handled out of order.
+type progressTracker struct {
+	sync.Mutex
+	fn       func(context.Context, int, int64)
+	position int
+	offset   int64
+	handled  map[int]handledBatch
+}
+
+// handledBatch records where a handled batch ends, keyed by its start.
+type handledBatch struct {
+	end    int
+	offset int64
+}
+
+func (t *progressTracker) done(ctx context.Context, batch *pendingBatch) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.handled[batch.start] = handledBatch{end: batch.end, offset: batch.offset}
+
+	advanced := false
+	for {
+		h, ok := t.handled[t.position]
+		if !ok {
+			break
+		}
+		delete(t.handled, t.position)
+		t.position, t.offset, advanced = h.end, h.offset, true
+	}
+	if advanced {
+		t.fn(ctx, t.position, t.offset)
+	}
 }
 
 // Push a record to queue for batching.
 // We assume record includes delimiter.
 func (q *Queue) Push(ctx context.Context, record []byte) error {
+	return q.pushFrom(ctx, record, 0, nil)
+}
+
+// pushFrom pushes a record followed by offset in the input. If the queue
+// is shared, the run which pushed the record is notified once the batch
+// containing it has been handled.
+func (q *Queue) pushFrom(ctx context.Context, record []byte, offset int64, o *owner) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.timerErr != nil {
+		return q.timerErr
+	}
+
 	if q.maxBatchSize > 0 && q.written+len(record)+len(q.delimiter) > q.maxBatchSize {
 		if len(record) > q.maxBatchSize {
 			return fmt.Errorf("%w: %d", ErrRecordLenExceedsBatchSize, len(record))
@@ -62,6 +162,23 @@ func (q *Queue) Push(ctx context.Context, record []byte) error {
 		buf.Reset()
 		q.buffer = buf
 		q.writer, q.closer = q.newWriterFunc(buf)
+
+		if q.maxLatency > 0 {
+			batches := q.batches
+			q.timer = time.AfterFunc(q.maxLatency, func() {
+				q.expire(ctx, batches)
+			})
+		}
+	}
+
+	if o != nil {
+		if _, ok := q.owners[o]; !ok {
+			if q.owners == nil {
+				q.owners = make(map[*owner]struct{})
+			}
+			q.owners[o] = struct{}{}
+			o.add()
+		}
 	}
 
 	n, err := q.writer.Write(record)
@@ -77,26 +194,72 @@ func (q *Queue) Push(ctx context.Context, record []byte) error {
 		}
 		q.written += n
 	}
+	q.position++
+	q.inputOffset = offset
+	q.records++
+
+	if q.maxRecords > 0 && q.records >= q.maxRecords {
+		return q.flush(ctx)
+	}
 	return nil
 }
 
+// Skip accounts for a record followed by offset in the input which is not
+// submitted, so that progress reflects its position.
+func (q *Queue) Skip(offset int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.position++
+	q.inputOffset = offset
+}
+
+// expire flushes a chunk once MaxLatency has elapsed, unless it was
+// already flushed.
+func (q *Queue) expire(ctx context.Context, batches int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.batches != batches {
+		return
+	}
+	if err := q.flush(ctx); err != nil && q.timerErr == nil {
+		q.timerErr = fmt.Errorf("failed to flush expired batch: %w", err)
+	}
+}
+
 func (q *Queue) flush(ctx context.Context) error {
 	if q.written == 0 {
 		return nil
 	}
 
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	q.batches++
+	q.records = 0
+
 	if err := q.closer.Close(); err != nil {
 		return fmt.Errorf("failed to close buffer: %w", err)
 	}
 
 	q.written = 0
+	batch := &pendingBatch{Buffer: q.buffer, start: q.flushed, end: q.position, offset: q.inputOffset}
+	q.flushed = q.position
+	for o := range q.owners {
+		batch.owners = append(batch.owners, o)
+	}
+	clear(q.owners)
 
 	select {
-	case q.ch <- q.buffer:
+	case q.ch <- batch:
 		return nil
 	case <-ctx.Done():
 		bufPool.Put(q.buffer)
-		return fmt.Errorf("cancelled flush: %w", ctx.Err())
+		err := fmt.Errorf("cancelled flush: %w", ctx.Err())
+		for _, o := range batch.owners {
+			o.done(err)
+		}
+		return err
 	}
 }
 
@@ -106,16 +269,17 @@ func (q *Queue) Process(ctx context.Context, c Handler) error {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("cancelled process: %w", ctx.Err())
-		case buf, ok := <-q.ch:
+		case batch, ok := <-q.ch:
 			if !ok {
 				return nil
 			}
-			err := c.Handle(ctx, buf)
-			bufPool.Put(buf)
+			err := c.Handle(ctx, batch.Buffer)
+			bufPool.Put(batch.Buffer)
 			if err != nil {
 				// nolint:wrapcheck
 				return err
 			}
+			q.progress.done(ctx, batch)
 		}
 	}
 }
@@ -123,6 +287,14 @@ func (q *Queue) Process(ctx context.Context, c Handler) error {
 // Close the queue.
 // Flushing batches after close will currently result in panic.
 func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.timerErr != nil {
+		return q.timerErr
+	}
+	q.closed = true
+
 	if err := q.flush(context.Background()); err != nil {
 		return fmt.Errorf("failed to close: %w", err)
 	}
@@ -140,8 +312,22 @@ func NewQueue(cfg *QueueConfig) *Queue {
 			return buf, io.NopCloser(buf)
 		},
 		maxBatchSize: cfg.MaxBatchSize,
+		maxRecords:   cfg.MaxRecords,
+		maxLatency:   cfg.MaxLatency,
 		delimiter:    cfg.Delimiter,
-		ch:           make(chan *bytes.Buffer, cfg.Capacity),
+		ch:           make(chan *pendingBatch, cfg.Capacity),
+		position:     cfg.Offset,
+		flushed:      cfg.Offset,
+		inputOffset:  cfg.InputOffset,
+	}
+
+	if cfg.Progress != nil {
+		q.progress = &progressTracker{
+			fn:       cfg.Progress,
+			position: cfg.Offset,
+			offset:   cfg.InputOffset,
+			handled:  make(map[int]handledBatch),
+		}
 	}
 
 	if cfg.GzipLevel != nil {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -61,6 +62,22 @@ func TestQueue(t *testing.T) {
 				"hello world\n",
 			},
 		},
+		{
+			QueueConfig: &batch.QueueConfig{
+				MaxBatchSize: 100,
+				MaxRecords:   2,
+				Capacity:     1,
+			},
+			Records: []string{
+				"hello\n",
+				"world\n",
+				"ok\n",
+			},
+			ExpectedBatches: []string{
+				"hello\nworld\n",
+				"ok\n",
+			},
+		},
 	}
 
 	for i, tc := range testcases {
@@ -112,6 +129,58 @@ func TestQueue(t *testing.T) {
 	}
 }
 
+func TestQueueMaxLatency(t *testing.T) {
+	t.Parallel()
+
+	q := batch.NewQueue(&batch.QueueConfig{
+		MaxLatency: 10 * time.Millisecond,
+		Capacity:   1,
+	})
+	g, ctx := errgroup.WithContext(context.Background())
+
+	batches := make(chan string, 2)
+	g.Go(func() error {
+		err := q.Process(ctx, batch.HandlerFunc(func(_ context.Context, r io.Reader) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("failed to read: %w", err)
+			}
+			batches <- string(data)
+			return nil
+		}))
+		if err != nil {
+			return fmt.Errorf("failed to consume: %w", err)
+		}
+		return nil
+	})
+
+	// the first record must be submitted without further pushes
+	if err := q.Push(ctx, []byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-batches:
+		if got != "hello\n" {
+			t.Fatalf("unexpected batch %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for batch")
+	}
+
+	if err := q.Push(ctx, []byte("world\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-batches; got != "world\n" {
+		t.Fatalf("unexpected batch %q", got)
+	}
+}
+
 func BenchmarkQueue(b *testing.B) {
 	capacity := 100
 	q := batch.NewQueue(&batch.QueueConfig{
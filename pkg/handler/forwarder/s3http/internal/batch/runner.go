@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -16,11 +17,30 @@ const (
 	defaultCapacityFactor     = 2
 )
 
+// Decoder provides records to a run. Decoders which also implement
+// io.Closer are closed once the run no longer reads from them, including
+// when the run stops early.
 type Decoder interface {
 	More() bool
 	Decode(any) error
 }
 
+// resumableDecoder is implemented by decoders which report the input offset
+// following the last decoded record, from which decoding can resume.
+type resumableDecoder interface {
+	ResumeOffset() int64
+}
+
+// closeDecoder releases resources held by a decoder, such as temporary files.
+func closeDecoder(dec Decoder) error {
+	if closer, ok := dec.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("failed to close decoder: %w", err)
+		}
+	}
+	return nil
+}
+
 type Handler interface {
 	Handle(context.Context, io.Reader) error
 }
@@ -37,27 +57,55 @@ type RunInput struct {
 	Decoder
 	Handler
 
-	MaxConcurrency *int // how many handlers to run concurrenctly
-	MaxBatchSize   *int // maximum size in bytes for each batch
-	MaxRecordSize  *int // maximum size in bytes for each record
-	CapacityFactor *int // channel capacity, calculated as a multiple of concurrency
-	GzipLevel      *int // whether to enable gzip when writing batch
+	MaxConcurrency *int           // how many handlers to run concurrenctly
+	MaxBatchSize   *int           // maximum size in bytes for each batch
+	MaxRecordSize  *int           // maximum size in bytes for each record
+	MaxRecords     *int           // maximum number of records for each batch
+	MaxLatency     *time.Duration // maximum time a record is buffered before its batch is submitted
+	CapacityFactor *int           // channel capacity, calculated as a multiple of concurrency
+	GzipLevel      *int           // whether to enable gzip when writing batch
+
+	// TooLarge identifies handler errors caused by a batch exceeding the
+	// size accepted by the destination. If set, such batches are split in
+	// half and resubmitted, down to individual records.
+	TooLarge func(error) bool
+	// SizeLimit retains the size of batches accepted after splitting, so
+	// that subsequent batches are split before submission. It may be shared
+	// across runs.
+	SizeLimit *SizeLimit
+
+	// Skip is the number of leading records to discard, e.g. because they
+	// were handled by a previous run.
+	Skip int
+	// Start is the number of records preceding the input, and StartOffset
+	// the offset at which the input begins, if the input was read from
+	// where a previous run stopped. Unlike skipped records, records
+	// preceding Start are not part of the input.
+	Start       int
+	StartOffset int64
+	// Progress is called with the number of leading records which have
+	// been handled, including skipped records and records preceding
+	// Start. The offset follows the last handled record, or is zero if the
+	// decoder does not report offsets. Calls are serialized.
+	Progress func(ctx context.Context, records int, offset int64)
 }
 
-// Run processes all events from a decoder and feeds them into 1 or more batch handlers.
-func Run(ctx context.Context, r *RunInput) error {
-	if r == nil {
-		return nil
+// inputOffset returns the offset following the last decoded record.
+func (r *RunInput) inputOffset() int64 {
+	if dec, ok := r.Decoder.(resumableDecoder); ok {
+		return r.StartOffset + dec.ResumeOffset()
 	}
+	return 0
+}
 
-	g, ctx := errgroup.WithContext(ctx)
-
+// limits returns the concurrency, maximum record size and queue
+// configuration for a run, applying defaults for unset values.
+func (r *RunInput) limits() (maxConcurrency, maxRecordSize int, cfg *QueueConfig) {
 	var (
-		maxConcurrency = defaultMaxConcurrency
 		maxBatchSize   = defaultMaxBatchSize
-		maxRecordSize  = defaultMaxRecordSize
 		capacityFactor = defaultCapacityFactor
 	)
+	maxConcurrency, maxRecordSize = defaultMaxConcurrency, defaultMaxRecordSize
 
 	if v := r.MaxConcurrency; v != nil && *v > 0 {
 		maxConcurrency = *v
@@ -72,29 +120,89 @@ func Run(ctx context.Context, r *RunInput) error {
 		capacityFactor = *v
 	}
 
-	q := NewQueue(&QueueConfig{
+	cfg = &QueueConfig{
 		MaxBatchSize: maxBatchSize,
 		Capacity:     capacityFactor * maxConcurrency,
 		Delimiter:    []byte("\n"),
 		GzipLevel:    r.GzipLevel,
-	})
+	}
+	if v := r.MaxRecords; v != nil && *v > 0 {
+		cfg.MaxRecords = *v
+	}
+	if v := r.MaxLatency; v != nil && *v > 0 {
+		cfg.MaxLatency = *v
+	}
+	return maxConcurrency, maxRecordSize, cfg
+}
+
+// newSplitHandler returns a handler which splits batches rejected as too
+// large, or nil if splitting is disabled.
+func (r *RunInput) newSplitHandler(delimiter []byte) *splitHandler {
+	if r.TooLarge == nil {
+		return nil
+	}
+	limit := r.SizeLimit
+	if limit == nil {
+		limit = &SizeLimit{}
+	}
+	return &splitHandler{
+		Handler:   r.Handler,
+		TooLarge:  r.TooLarge,
+		Limit:     limit,
+		Delimiter: delimiter,
+		GzipLevel: r.GzipLevel,
+	}
+}
+
+// Run processes all events from a decoder and feeds them into 1 or more batch handlers.
+func Run(ctx context.Context, r *RunInput) (err error) {
+	if r == nil {
+		return nil
+	}
+	defer func() {
+		if closeErr := closeDecoder(r.Decoder); err == nil {
+			err = closeErr
+		}
+	}()
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	maxConcurrency, maxRecordSize, cfg := r.limits()
+	cfg.Offset = r.Start + r.Skip
+	cfg.InputOffset = r.StartOffset
+	cfg.Progress = r.Progress
+	q := NewQueue(cfg)
+
+	var (
+		handler  = r.Handler
+		splitter = r.newSplitHandler(cfg.Delimiter)
+	)
+	if splitter != nil {
+		handler = splitter
+	}
 
 	for range maxConcurrency {
-		g.Go(func() error { return q.Process(ctx, r.Handler) })
+		g.Go(func() error { return q.Process(ctx, handler) })
 	}
 
 	g.Go(func() error {
 		var v json.RawMessage
-		for r.More() {
+		for skipped := 0; r.More(); {
 			if err := r.Decode(&v); err != nil {
 				return fmt.Errorf("failed to decode: %w", err)
 			}
 
+			if skipped < r.Skip {
+				skipped++
+				continue
+			}
+
 			if maxRecordSize > 0 && len(v) > maxRecordSize {
+				q.Skip(r.inputOffset())
 				continue
 			}
 
-			if err := q.Push(ctx, v); err != nil {
+			if err := q.pushFrom(ctx, v, r.inputOffset(), nil); err != nil {
 				return fmt.Errorf("failed to push: %w", err)
 			}
 		}
@@ -104,6 +212,12 @@ func Run(ctx context.Context, r *RunInput) error {
 		return nil
 	})
 
-	// nolint:wrapcheck
-	return g.Wait()
+	if err := g.Wait(); err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+	if splitter != nil {
+		return splitter.err()
+	}
+	return nil
 }
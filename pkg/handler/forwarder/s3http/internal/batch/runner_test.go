@@ -1,13 +1,16 @@
 package batch_test
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -76,3 +79,226 @@ func TestRunner(t *testing.T) {
 		})
 	}
 }
+
+// closingDecoder records whether it was closed.
+type closingDecoder struct {
+	*json.Decoder
+	closed bool
+}
+
+func (d *closingDecoder) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestRunnerClosesDecoder(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+	dec := &closingDecoder{Decoder: json.NewDecoder(strings.NewReader(strings.Repeat("{}\n", 100)))}
+
+	err := batch.Run(context.Background(), &batch.RunInput{
+		Decoder:    dec,
+		MaxRecords: ptr(1),
+		Handler: batch.HandlerFunc(func(context.Context, io.Reader) error {
+			return errHandler
+		}),
+	})
+	if !errors.Is(err, errHandler) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !dec.closed {
+		t.Error("expected decoder to be closed when the run stops early")
+	}
+}
+
+var errTooLarge = errors.New("too large")
+
+func TestRunnerSplit(t *testing.T) {
+	t.Parallel()
+
+	record := `{"hello":"world"}`
+	large := `{"hello":"` + strings.Repeat("x", 40) + `"}`
+
+	testcases := []struct {
+		Name          string
+		GzipLevel     *int
+		Input         []string
+		ExpectRecords int
+		ExpectError   error
+	}{
+		{
+			Name:          "split",
+			Input:         []string{record, record, record, record, record, record},
+			ExpectRecords: 6,
+		},
+		{
+			Name:          "gzip",
+			GzipLevel:     ptr(gzip.BestSpeed),
+			Input:         []string{record, record, record, record, record, record},
+			ExpectRecords: 6,
+		},
+		{
+			Name:          "rejected",
+			Input:         []string{record, record, large, record, record},
+			ExpectRecords: 4,
+			ExpectError:   batch.ErrRecordRejected,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu      sync.Mutex
+				records int
+				now     = time.Now()
+				limit   = batch.SizeLimit{Now: func() time.Time { return now }}
+			)
+
+			handler := batch.HandlerFunc(func(_ context.Context, r io.Reader) error {
+				if tc.GzipLevel != nil {
+					gr, err := gzip.NewReader(r)
+					if err != nil {
+						return fmt.Errorf("failed to decompress: %w", err)
+					}
+					r = gr
+				}
+				data, err := io.ReadAll(r)
+				if err != nil {
+					return fmt.Errorf("failed to read: %w", err)
+				}
+				if len(data) > 40 {
+					return errTooLarge
+				}
+				mu.Lock()
+				records += strings.Count(string(data), "\n")
+				mu.Unlock()
+				return nil
+			})
+
+			// a second run starts from the limit learned by the first
+			for run := range 2 {
+				records = 0
+				err := batch.Run(context.Background(), &batch.RunInput{
+					Decoder:   json.NewDecoder(strings.NewReader(strings.Join(tc.Input, "\n"))),
+					Handler:   handler,
+					GzipLevel: tc.GzipLevel,
+					TooLarge:  func(err error) bool { return errors.Is(err, errTooLarge) },
+					SizeLimit: &limit,
+				})
+				if diff := cmp.Diff(err, tc.ExpectError, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("run %d: unexpected error: %s", run, diff)
+				}
+				if records != tc.ExpectRecords {
+					t.Errorf("run %d: expected %d records, got %d", run, tc.ExpectRecords, records)
+				}
+			}
+
+			// limits apply to compressed request bodies
+			if n := limit.Get(); n == 0 || (tc.GzipLevel == nil && n > 40) {
+				t.Errorf("unexpected size limit %d", n)
+			}
+
+			now = now.Add(time.Hour)
+			if n := limit.Get(); n != 0 {
+				t.Errorf("expected size limit to expire, got %d", n)
+			}
+		})
+	}
+}
+
+// resumableDecoder reports the offset following each decoded value.
+type resumableDecoder struct {
+	*json.Decoder
+}
+
+func (d *resumableDecoder) ResumeOffset() int64 {
+	return d.InputOffset()
+}
+
+func TestRunnerResume(t *testing.T) {
+	t.Parallel()
+
+	// the second record exceeds the maximum record size, but must still
+	// count towards progress
+	input := `{"n":0} {"n":"large"} {"n":2} {"n":3} {"n":4} {"n":5} {"n":6}`
+	errFail := errors.New("fail")
+
+	type position struct {
+		Records int
+		Offset  int64
+	}
+
+	run := func(r *batch.RunInput, fail string) ([]string, []position, error) {
+		var (
+			batches  []string
+			progress []position
+		)
+		r.MaxConcurrency = ptr(1)
+		r.MaxBatchSize = ptr(16)
+		r.MaxRecordSize = ptr(7)
+		r.Progress = func(_ context.Context, records int, offset int64) {
+			progress = append(progress, position{Records: records, Offset: offset})
+		}
+		r.Handler = batch.HandlerFunc(func(_ context.Context, r io.Reader) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("failed to read: %w", err)
+			}
+			if fail != "" && strings.Contains(string(data), fail) {
+				return errFail
+			}
+			batches = append(batches, string(data))
+			return nil
+		})
+		err := batch.Run(context.Background(), r)
+		return batches, progress, err
+	}
+
+	batches, progress, err := run(&batch.RunInput{
+		Decoder: &resumableDecoder{json.NewDecoder(strings.NewReader(input))},
+	}, `{"n":5}`)
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if diff := cmp.Diff([]string{"{\"n\":0}\n{\"n\":2}\n", "{\"n\":3}\n{\"n\":4}\n"}, batches); diff != "" {
+		t.Error("unexpected batches", diff)
+	}
+	if diff := cmp.Diff([]position{{3, 29}, {5, 45}}, progress); diff != "" {
+		t.Fatal("unexpected progress", diff)
+	}
+	last := progress[len(progress)-1]
+
+	// without offsets, we must skip records already handled
+	batches, progress, err = run(&batch.RunInput{
+		Decoder: json.NewDecoder(strings.NewReader(input)),
+		Skip:    last.Records,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"{\"n\":5}\n{\"n\":6}\n"}, batches); diff != "" {
+		t.Error("unexpected batches after skipping", diff)
+	}
+	if diff := cmp.Diff([]position{{7, 0}}, progress); diff != "" {
+		t.Error("unexpected progress after skipping", diff)
+	}
+
+	// with offsets, we can read from where the previous run stopped
+	batches, progress, err = run(&batch.RunInput{
+		Decoder:     &resumableDecoder{json.NewDecoder(strings.NewReader(input[last.Offset:]))},
+		Start:       last.Records,
+		StartOffset: last.Offset,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"{\"n\":5}\n{\"n\":6}\n"}, batches); diff != "" {
+		t.Error("unexpected batches after resume", diff)
+	}
+	if diff := cmp.Diff([]position{{7, int64(len(input))}}, progress); diff != "" {
+		t.Error("unexpected progress after resume", diff)
+	}
+}
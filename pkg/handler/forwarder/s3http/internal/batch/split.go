@@ -0,0 +1,234 @@
+package batch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var ErrRecordRejected = errors.New("records rejected")
+
+// defaultSizeLimitTTL is how long a size limit applies after the last
+// rejected batch, so that limits relax if the destination accepts larger
+// batches again.
+const defaultSizeLimitTTL = 15 * time.Minute
+
+// SizeLimit tracks the largest batch size accepted after a batch was
+// rejected as too large. Sizes refer to request bodies as submitted, i.e.
+// after compression. It is safe for concurrent use, and can be shared
+// across runs so that later batches avoid being rejected.
+type SizeLimit struct {
+	// TTL is how long the limit applies after the last rejected batch.
+	// Defaults to 15 minutes.
+	TTL time.Duration
+	Now func() time.Time
+
+	mu         sync.Mutex
+	rejected   int
+	accepted   int
+	rejectedAt time.Time
+}
+
+func (l *SizeLimit) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+// expire resets the limit once the TTL has passed since the last rejected
+// batch. The caller must hold the lock.
+func (l *SizeLimit) expire() {
+	ttl := l.TTL
+	if ttl <= 0 {
+		ttl = defaultSizeLimitTTL
+	}
+	if l.rejected > 0 && l.now().Sub(l.rejectedAt) >= ttl {
+		l.rejected, l.accepted = 0, 0
+	}
+}
+
+// Get returns the batch size limit, or zero if no batch has been rejected
+// recently.
+func (l *SizeLimit) Get() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	return l.accepted
+}
+
+func (l *SizeLimit) reject(size int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	if l.rejected == 0 || size < l.rejected {
+		l.rejected = size
+	}
+	if l.accepted >= l.rejected {
+		l.accepted = 0
+	}
+	l.rejectedAt = l.now()
+}
+
+func (l *SizeLimit) accept(size int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size < l.rejected && size > l.accepted {
+		l.accepted = size
+	}
+}
+
+// splitHandler resubmits batches which were rejected as too large in two
+// halves, split along record boundaries. Batches are submitted as received,
+// and only decoded if they need to be split. Records rejected on their own
+// are dropped, and reported once all batches have been processed.
+type splitHandler struct {
+	Handler
+	TooLarge  func(error) bool
+	Limit     *SizeLimit
+	Delimiter []byte
+	GzipLevel *int
+
+	mu          sync.Mutex
+	rejected    int
+	rejectedErr error
+}
+
+func (h *splitHandler) Handle(ctx context.Context, r io.Reader) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read batch: %w", err)
+	}
+	return h.handle(ctx, body, nil, false)
+}
+
+// handle submits body. The decoded records in body are passed as data if
+// known, and are otherwise only decoded if body must be split.
+func (h *splitHandler) handle(ctx context.Context, body, data []byte, split bool) error {
+	// avoid submitting batches which are likely to be rejected
+	if limit := h.Limit.Get(); limit > 0 && len(body) > limit {
+		if ok, err := h.split(ctx, body, data); ok {
+			return err
+		}
+	}
+
+	err := h.Handler.Handle(ctx, bytes.NewReader(body))
+	switch {
+	case err == nil:
+		if split {
+			h.Limit.accept(len(body))
+		}
+		return nil
+	case !h.TooLarge(err):
+		return err
+	}
+
+	h.Limit.reject(len(body))
+	if ok, splitErr := h.split(ctx, body, data); ok {
+		return splitErr
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rejected++
+	if h.rejectedErr == nil {
+		h.rejectedErr = err
+	}
+	return nil
+}
+
+// split submits each half of a batch separately. It returns false if the
+// batch contains a single record.
+func (h *splitHandler) split(ctx context.Context, body, data []byte) (bool, error) {
+	if data == nil {
+		var err error
+		if data, err = h.decode(body); err != nil {
+			return true, err
+		}
+	}
+
+	left, right, ok := splitRecords(data, h.Delimiter)
+	if !ok {
+		return false, nil
+	}
+	for _, part := range [][]byte{left, right} {
+		encoded, err := h.encode(part)
+		if err != nil {
+			return true, err
+		}
+		if err := h.handle(ctx, encoded, part, true); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func (h *splitHandler) decode(body []byte) ([]byte, error) {
+	if h.GzipLevel == nil {
+		return body, nil
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch: %w", err)
+	}
+	data, err := io.ReadAll(gr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch: %w", err)
+	}
+	return data, nil
+}
+
+func (h *splitHandler) encode(data []byte) ([]byte, error) {
+	if h.GzipLevel == nil {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	gw, err := gzip.NewWriterLevel(&buf, *h.GzipLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress batch: %w", err)
+	}
+	if _, err := gw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress batch: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress batch: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// err reports records which were rejected on their own.
+func (h *splitHandler) err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.rejected == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d: %w", ErrRecordRejected, h.rejected, h.rejectedErr)
+}
+
+// splitRecords splits data in two along a record boundary near the middle.
+// Data containing a single record cannot be split.
+func splitRecords(data, delimiter []byte) (left, right []byte, ok bool) {
+	if len(delimiter) == 0 {
+		return nil, nil, false
+	}
+
+	mid := len(data) / 2
+	if i := bytes.Index(data[mid:], delimiter); i >= 0 {
+		if at := mid + i + len(delimiter); at < len(data) {
+			return data[:at], data[at:], true
+		}
+	}
+	if i := bytes.LastIndex(data[:mid], delimiter); i >= 0 {
+		at := i + len(delimiter)
+		return data[:at], data[at:], true
+	}
+	return nil, nil, false
+}
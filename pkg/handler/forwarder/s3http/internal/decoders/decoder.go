@@ -17,6 +17,9 @@ var decoders = map[string]DecoderFactory{
 	"application/json":                       JSONDecoderFactory,
 	"application/x-csv":                      CSVDecoderFactory,
 	"application/x-ndjson":                   JSONDecoderFactory,
+	"application/x-json-array":               NestedJSONDecoderFactory,
+	"application/x-json-records":             NestedJSONDecoderFactory,
+	"application/vnd.apache.parquet":         ParquetDecoderFactory,
 	"text/plain":                             TextDecoderFactory,
 	"text/csv":                               CSVDecoderFactory,
 	"application/x-aws-cloudwatchlogs":       CloudWatchLogsDecoderFactory,
@@ -25,8 +28,16 @@ var decoders = map[string]DecoderFactory{
 	"application/x-aws-change":               FilteredJSONDecoderFactory(ConfigurationDiff{}),
 	"application/x-aws-cloudtrail":           NestedJSONDecoderFactory,
 	"application/x-aws-sqs":                  JSONDecoderFactory,
-	"application/x-aws-vpcflowlogs":          SSVDecoderFactory,
-	"application/x-aws-elasticloadbalancing": SSVDecoderFactory,
+	"application/x-aws-vpcflowlogs":          VPCFlowLogDecoderFactory,
+	"application/x-aws-elasticloadbalancing": ELBAccessLogDecoderFactory,
+	"application/x-aws-alb-connection":       ALBConnectionLogDecoderFactory,
+	"application/x-aws-cloudfront":           W3CDecoderFactory,
+	"application/x-aws-guardduty":            JSONDecoderFactory,
+	"application/x-aws-networkfirewall":      JSONDecoderFactory,
+	"application/x-aws-route53resolver":      JSONDecoderFactory,
+	"application/x-aws-s3access":             S3AccessLogDecoderFactory,
+	"application/x-aws-securityhub":          SecurityHubDecoderFactory,
+	"application/x-aws-waf":                  JSONDecoderFactory,
 }
 
 type Decoder interface {
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/decoders"
 )
@@ -32,6 +33,26 @@ func TestDecoders(t *testing.T) {
 			ContentType: "application/x-ndjson",
 			InputFile:   "testdata/example.ndjson",
 		},
+		{
+			ContentType:     "application/x-ndjson",
+			ContentEncoding: "gzip",
+			InputFile:       "testdata/example.ndjson.gz",
+		},
+		{
+			ContentType:     "application/x-ndjson",
+			ContentEncoding: "zstd",
+			InputFile:       "testdata/example.ndjson.zst",
+		},
+		{
+			ContentType:     "application/x-ndjson",
+			ContentEncoding: "bzip2",
+			InputFile:       "testdata/example.ndjson.bz2",
+		},
+		{
+			ContentType:     "application/x-ndjson",
+			ContentEncoding: "snappy",
+			InputFile:       "testdata/example.ndjson.sz",
+		},
 		{
 			ContentType: "application/x-aws-config",
 			InputFile:   "testdata/config.json",
@@ -58,6 +79,47 @@ func TestDecoders(t *testing.T) {
 			InputFile:      "testdata/cloudwatchlogs.json",
 			DisableRawJSON: true,
 		},
+		{
+			ContentType: "application/x-aws-cloudfront",
+			InputFile:   "testdata/cloudfront.log",
+		},
+		{
+			ContentType: "application/x-aws-s3access",
+			InputFile:   "testdata/s3access.log",
+		},
+		{
+			ContentType: "application/x-aws-vpcflowlogs",
+			InputFile:   "testdata/vpcflowlogs-custom.log",
+		},
+		{
+			ContentType: "application/x-aws-elasticloadbalancing",
+			InputFile:   "testdata/alb.log",
+		},
+		{
+			ContentType: "application/x-aws-elasticloadbalancing",
+			InputFile:   "testdata/nlb.log",
+		},
+		{
+			ContentType: "application/x-aws-elasticloadbalancing",
+			InputFile:   "testdata/classic.log",
+		},
+		{
+			ContentType: "application/x-aws-alb-connection",
+			InputFile:   "testdata/albconnection.log",
+		},
+		{
+			ContentType: "application/x-aws-securityhub",
+			InputFile:   "testdata/securityhub.json",
+		},
+		{
+			ContentType: "application/vnd.apache.parquet",
+			InputFile:   "testdata/example.parquet",
+		},
+		{
+			ContentType:     "application/vnd.apache.parquet",
+			ContentEncoding: "gzip",
+			InputFile:       "testdata/example.parquet.gz",
+		},
 	}
 
 	for _, tt := range testcases {
@@ -102,6 +164,70 @@ func TestDecoders(t *testing.T) {
 	}
 }
 
+// TestResumeOffset verifies that decoding from the offset reported after
+// each record yields the remaining records.
+func TestResumeOffset(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		ContentType string
+		InputFile   string
+	}{
+		{
+			ContentType: "application/x-ndjson",
+			InputFile:   "testdata/example.ndjson",
+		},
+		{
+			ContentType: "text/plain",
+			InputFile:   "testdata/example.txt",
+		},
+	}
+
+	type resumer interface {
+		ResumeOffset() int64
+	}
+
+	decodeAll := func(t *testing.T, contentType string, data []byte) (records []string, offsets []int64) {
+		t.Helper()
+		dec, err := decoders.Get("", contentType, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok := dec.(resumer)
+		if !ok {
+			t.Fatalf("%T does not report offsets", dec)
+		}
+		for dec.More() {
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, string(v))
+			offsets = append(offsets, r.ResumeOffset())
+		}
+		return records, offsets
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.InputFile, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := io.ReadAll(readFile(t, tt.InputFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			records, offsets := decodeAll(t, tt.ContentType, data)
+			for i, offset := range offsets {
+				remaining, _ := decodeAll(t, tt.ContentType, data[offset:])
+				if diff := cmp.Diff(records[i+1:], remaining, cmpopts.EquateEmpty()); diff != "" {
+					t.Fatalf("unexpected records resuming from %d: %s", offset, diff)
+				}
+			}
+		})
+	}
+}
+
 func readFile(t *testing.T, filename string) io.Reader {
 	t.Helper()
 	file, err := os.Open(filename)
@@ -0,0 +1,140 @@
+package decoders
+
+import "strings"
+
+// ALBAccessLogFields lists the fields of an Application Load Balancer access
+// log record, in order. Fields are only ever appended to the format, so
+// records from earlier versions decode correctly.
+var ALBAccessLogFields = []Field{
+	stringField("type"),
+	stringField("time"),
+	stringField("elb"),
+	addressField("client"),
+	addressField("target"),
+	numberField("request_processing_time"),
+	numberField("target_processing_time"),
+	numberField("response_processing_time"),
+	numberField("elb_status_code"),
+	numberField("target_status_code"),
+	numberField("received_bytes"),
+	numberField("sent_bytes"),
+	requestField("request"),
+	stringField("user_agent"),
+	stringField("ssl_cipher"),
+	stringField("ssl_protocol"),
+	stringField("target_group_arn"),
+	stringField("trace_id"),
+	stringField("domain_name"),
+	stringField("chosen_cert_arn"),
+	numberField("matched_rule_priority"),
+	stringField("request_creation_time"),
+	stringField("actions_executed"),
+	stringField("redirect_url"),
+	stringField("error_reason"),
+	stringField("target_port_list"),
+	stringField("target_status_code_list"),
+	stringField("classification"),
+	stringField("classification_reason"),
+	stringField("conn_trace_id"),
+	stringField("transformed_host"),
+	stringField("transformed_uri"),
+	stringField("request_transform_status"),
+}
+
+// NLBAccessLogFields lists the fields of a Network Load Balancer TLS access
+// log record, in order. Version 1.0 records end at tls_protocol_version.
+var NLBAccessLogFields = []Field{
+	stringField("type"),
+	stringField("version"),
+	stringField("time"),
+	stringField("elb"),
+	stringField("listener"),
+	addressField("client"),
+	addressField("destination"),
+	numberField("connection_time"),
+	numberField("tls_handshake_time"),
+	numberField("received_bytes"),
+	numberField("sent_bytes"),
+	stringField("incoming_tls_alert"),
+	stringField("chosen_cert_arn"),
+	stringField("chosen_cert_serial"),
+	stringField("tls_cipher"),
+	stringField("tls_protocol_version"),
+	stringField("tls_named_group"),
+	stringField("domain_name"),
+	stringField("alpn_fe_protocol"),
+	stringField("alpn_be_protocol"),
+	stringField("alpn_client_preference_list"),
+	stringField("tls_connection_creation_time"),
+}
+
+// ClassicAccessLogFields lists the fields of a Classic Load Balancer access
+// log record, in order. TCP listeners omit request details.
+var ClassicAccessLogFields = []Field{
+	stringField("time"),
+	stringField("elb"),
+	addressField("client"),
+	addressField("backend"),
+	numberField("request_processing_time"),
+	numberField("backend_processing_time"),
+	numberField("response_processing_time"),
+	numberField("elb_status_code"),
+	numberField("backend_status_code"),
+	numberField("received_bytes"),
+	numberField("sent_bytes"),
+	requestField("request"),
+	stringField("user_agent"),
+	stringField("ssl_cipher"),
+	stringField("ssl_protocol"),
+}
+
+// ALBConnectionLogFields lists the fields of an Application Load Balancer
+// connection log record, in order.
+var ALBConnectionLogFields = []Field{
+	stringField("timestamp"),
+	stringField("client_ip"),
+	numberField("client_port"),
+	numberField("listener_port"),
+	stringField("tls_protocol"),
+	stringField("tls_cipher"),
+	numberField("tls_handshake_latency"),
+	stringField("leaf_client_cert_subject"),
+	stringField("leaf_client_cert_validity"),
+	stringField("leaf_client_cert_serial_number"),
+	stringField("tls_verify_status"),
+	stringField("conn_trace_id"),
+}
+
+// ELBAccessLogDecoderFactory handles access logs for Application, Network
+// and Classic Load Balancers. The load balancer type is determined per
+// record.
+var ELBAccessLogDecoderFactory = LineDecoderFactory(ELBAccessLogParser)
+
+func ELBAccessLogParser(map[string]string) LineParser {
+	return func(line string) ([]byte, error) {
+		values := splitFields(line)
+		return encodeRecord(elbAccessLogFields(values), values), nil
+	}
+}
+
+// elbAccessLogFields identifies the load balancer type from the first
+// value of a record. Application Load Balancer records begin with the
+// request type, Network Load Balancer records with the listener type, and
+// Classic Load Balancer records with a timestamp.
+func elbAccessLogFields(values []string) []Field {
+	if len(values) == 0 {
+		return nil
+	}
+	switch strings.ToLower(values[0]) {
+	case "http", "https", "h2", "grpcs", "ws", "wss":
+		return ALBAccessLogFields
+	case "tls":
+		return NLBAccessLogFields
+	default:
+		return ClassicAccessLogFields
+	}
+}
+
+// ALBConnectionLogDecoderFactory handles Application Load Balancer
+// connection logs.
+var ALBConnectionLogDecoderFactory = LineDecoderFactory(FieldsParser(ALBConnectionLogFields...))
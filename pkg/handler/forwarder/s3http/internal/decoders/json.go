@@ -8,7 +8,18 @@ import (
 )
 
 func JSONDecoderFactory(r io.Reader, _ map[string]string) Decoder {
-	return json.NewDecoder(r)
+	return &JSONDecoder{Decoder: json.NewDecoder(r)}
+}
+
+// JSONDecoder decodes a stream of JSON values. Since values are self
+// delimiting, decoding can resume from the end of any value.
+type JSONDecoder struct {
+	*json.Decoder
+}
+
+// ResumeOffset returns the byte offset following the last decoded value.
+func (dec *JSONDecoder) ResumeOffset() int64 {
+	return dec.InputOffset()
 }
 
 func FilteredJSONDecoderFactory(record any) DecoderFactory {
@@ -0,0 +1,453 @@
+package decoders
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/seekable"
+)
+
+const (
+	// parquetMemoryLimitBytes bounds how much of a non-seekable input is
+	// kept in memory before spilling to disk.
+	parquetMemoryLimitBytes int64 = 32 * 1024 * 1024
+	// parquetRowBatchSize is the number of rows read at a time. Rows are
+	// read from one row group at a time, so memory use is bounded by the
+	// size of a batch and the pages it spans, rather than the file.
+	parquetRowBatchSize = 128
+	// julianUnixEpoch is the Julian day number of the Unix epoch.
+	julianUnixEpoch = 2440588
+)
+
+var ErrNotReaderAt = errors.New("parquet input does not support random access")
+
+// ParquetDecoderFactory handles Apache Parquet files. Parquet metadata is
+// stored in a footer, so the input should implement io.ReaderAt and
+// io.Seeker, as returned by seekable.FromReader. Other inputs are buffered
+// in full before decoding.
+func ParquetDecoderFactory(r io.Reader, _ map[string]string) Decoder {
+	body, cleanup, err := seekable.FromReader(r, parquetMemoryLimitBytes)
+	if err != nil {
+		return &errorDecoder{fmt.Errorf("failed to buffer parquet file: %w", err)}
+	}
+
+	dec := &ParquetDecoder{cleanup: cleanup}
+	dec.err = dec.open(body)
+	return dec
+}
+
+// ParquetDecoder emits each row of a Parquet file as a JSON object. Logical
+// types are converted to their JSON equivalent, e.g. timestamps are encoded
+// as RFC 3339 strings.
+type ParquetDecoder struct {
+	convert   parquetConverter
+	columns   [][]parquet.Value
+	rowGroups []parquet.RowGroup
+	rows      parquet.Rows
+	buf       []parquet.Row
+	pending   []parquet.Row
+	cleanup   func() error
+	err       error
+}
+
+func (dec *ParquetDecoder) open(body io.ReadSeeker) (err error) {
+	readerAt, ok := body.(io.ReaderAt)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotReaderAt, body)
+	}
+
+	size, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to seek: %w", err)
+	}
+
+	// malformed schemas may cause a panic rather than an error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to open parquet file: %v", r)
+		}
+	}()
+
+	file, err := parquet.OpenFile(readerAt, size, parquet.SkipBloomFilters(true), parquet.SkipPageIndex(true))
+	if err != nil {
+		return fmt.Errorf("failed to open parquet file: %w", err)
+	}
+
+	_, dec.convert = compileParquetNode(file.Schema())
+	dec.rowGroups = file.RowGroups()
+	dec.buf = make([]parquet.Row, parquetRowBatchSize)
+	return nil
+}
+
+// advance reads the next batch of rows, moving on to the next row group
+// once the current one is exhausted.
+func (dec *ParquetDecoder) advance() {
+	for len(dec.pending) == 0 && dec.err == nil {
+		if dec.rows == nil {
+			if len(dec.rowGroups) == 0 {
+				dec.close()
+				return
+			}
+			dec.rows = dec.rowGroups[0].Rows()
+			dec.rowGroups = dec.rowGroups[1:]
+		}
+
+		n, err := dec.rows.ReadRows(dec.buf)
+		dec.pending = dec.buf[:n]
+		switch {
+		case errors.Is(err, io.EOF):
+			if closeErr := dec.rows.Close(); closeErr != nil {
+				dec.err = fmt.Errorf("failed to close row group: %w", closeErr)
+			}
+			dec.rows = nil
+		case err != nil:
+			dec.err = fmt.Errorf("failed to read rows: %w", err)
+		}
+	}
+}
+
+// close releases the input once all rows have been read.
+func (dec *ParquetDecoder) close() {
+	if err := dec.Close(); err != nil && dec.err == nil {
+		dec.err = err
+	}
+}
+
+// Close releases the input, including any temporary file used to buffer it.
+// It must be called if decoding stops before all rows have been read.
+func (dec *ParquetDecoder) Close() error {
+	var errs []error
+	if dec.rows != nil {
+		if err := dec.rows.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close row group: %w", err))
+		}
+		dec.rows = nil
+	}
+	dec.rowGroups, dec.pending = nil, nil
+	if dec.cleanup != nil {
+		if err := dec.cleanup(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to clean up: %w", err))
+		}
+		dec.cleanup = nil
+	}
+	return errors.Join(errs...)
+}
+
+func (dec *ParquetDecoder) Decode(v any) error {
+	dec.advance()
+	if dec.err != nil {
+		dec.close()
+		return dec.err
+	}
+	if len(dec.pending) == 0 {
+		return io.EOF
+	}
+	row := dec.pending[0]
+	dec.pending = dec.pending[1:]
+
+	dec.columns = dec.columns[:0]
+	row.Range(func(_ int, values []parquet.Value) bool {
+		dec.columns = append(dec.columns, values)
+		return true
+	})
+
+	data, err := json.Marshal(dec.convert(parquetLevels{}, dec.columns))
+	if err != nil {
+		return fmt.Errorf("failed to encode row: %w", err)
+	}
+
+	if r, ok := v.(*json.RawMessage); ok {
+		*r = data
+	} else if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode row: %w", err)
+	}
+	return nil
+}
+
+// More checks if there is more input.
+func (dec *ParquetDecoder) More() bool {
+	dec.advance()
+	return len(dec.pending) > 0 || dec.err != nil
+}
+
+// parquetLevels tracks the repetition and definition levels of a node.
+type parquetLevels struct {
+	repetitionDepth int
+	definitionLevel int
+}
+
+// parquetConverter converts the column values of a node into a JSON
+// compatible value.
+type parquetConverter func(levels parquetLevels, columns [][]parquet.Value) any
+
+// compileParquetNode returns a converter for a node, along with the number of
+// columns it spans. Groups are converted to objects, repeated nodes to arrays
+// and leaves according to their logical type.
+func compileParquetNode(node parquet.Node) (int, parquetConverter) {
+	switch {
+	case node.Optional():
+		n, convert := compileParquetNode(parquet.Required(node))
+		return n, func(levels parquetLevels, columns [][]parquet.Value) any {
+			levels.definitionLevel++
+			if columns[0][0].DefinitionLevel() < levels.definitionLevel {
+				return nil
+			}
+			return convert(levels, columns)
+		}
+	case node.Repeated():
+		n, convert := compileParquetNode(parquet.Required(node))
+		return n, func(levels parquetLevels, columns [][]parquet.Value) any {
+			levels.repetitionDepth++
+			levels.definitionLevel++
+			elems := []any{}
+			if columns[0][0].DefinitionLevel() < levels.definitionLevel {
+				return elems
+			}
+
+			// each element starts at a value with a repetition level no
+			// greater than the current depth
+			remaining := columns
+			for len(remaining[0]) > 0 {
+				elem := make([][]parquet.Value, len(remaining))
+				next := make([][]parquet.Value, len(remaining))
+				for i, column := range remaining {
+					k := 1
+					for k < len(column) && column[k].RepetitionLevel() > levels.repetitionDepth {
+						k++
+					}
+					elem[i], next[i] = column[:k], column[k:]
+				}
+				elems = append(elems, convert(levels, elem))
+				remaining = next
+			}
+			return elems
+		}
+	case node.Leaf():
+		t := node.Type()
+		return 1, func(_ parquetLevels, columns [][]parquet.Value) any {
+			if value := columns[0][0]; !value.IsNull() {
+				return convertParquetLeaf(t, parquetValue(value))
+			}
+			return nil
+		}
+	}
+
+	fields := node.Fields()
+	if lt := node.Type().LogicalType(); lt != nil && len(fields) == 1 && !fields[0].Leaf() {
+		switch {
+		case lt.List != nil:
+			return compileParquetList(fields[0])
+		case lt.Map != nil:
+			return compileParquetMap(fields[0])
+		}
+	}
+
+	offsets := make([]int, len(fields)+1)
+	converters := make([]parquetConverter, len(fields))
+	for i, field := range fields {
+		var n int
+		n, converters[i] = compileParquetNode(field)
+		offsets[i+1] = offsets[i] + n
+	}
+	return offsets[len(fields)], func(levels parquetLevels, columns [][]parquet.Value) any {
+		m := make(map[string]any, len(fields))
+		for i, field := range fields {
+			m[field.Name()] = converters[i](levels, columns[offsets[i]:offsets[i+1]])
+		}
+		return m
+	}
+}
+
+// compileParquetList handles the repeated group within a LIST. The group
+// usually wraps a single element, but legacy writers may repeat the element
+// directly.
+func compileParquetList(group parquet.Node) (int, parquetConverter) {
+	n, convert := compileParquetNode(group)
+	if len(group.Fields()) != 1 {
+		return n, convert
+	}
+	name := group.Fields()[0].Name()
+	return n, func(levels parquetLevels, columns [][]parquet.Value) any {
+		elems, _ := convert(levels, columns).([]any)
+		for i, elem := range elems {
+			if m, ok := elem.(map[string]any); ok {
+				elems[i] = m[name]
+			}
+		}
+		return elems
+	}
+}
+
+// compileParquetMap handles the repeated key-value group within a MAP. Keys
+// which are not strings are formatted as strings.
+func compileParquetMap(group parquet.Node) (int, parquetConverter) {
+	n, convert := compileParquetNode(group)
+	return n, func(levels parquetLevels, columns [][]parquet.Value) any {
+		elems, _ := convert(levels, columns).([]any)
+		m := make(map[string]any, len(elems))
+		for _, elem := range elems {
+			if kv, ok := elem.(map[string]any); ok {
+				key, ok := kv["key"].(string)
+				if !ok {
+					key = fmt.Sprint(kv["key"])
+				}
+				m[key] = kv["value"]
+			}
+		}
+		return m
+	}
+}
+
+// parquetValue returns the primitive value of a leaf. Byte arrays which are
+// not valid UTF-8 are kept as bytes, and therefore encoded as base64.
+func parquetValue(v parquet.Value) any {
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return v.Int32()
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Int96:
+		return v.Int96()
+	case parquet.Float:
+		return v.Float()
+	case parquet.Double:
+		return v.Double()
+	case parquet.ByteArray:
+		if b := v.ByteArray(); utf8.Valid(b) {
+			return string(b)
+		}
+	}
+	return bytes.Clone(v.ByteArray())
+}
+
+// convertParquetLeaf converts a primitive value according to its logical
+// type.
+func convertParquetLeaf(t parquet.Type, v any) any {
+	if lt := t.LogicalType(); lt != nil {
+		switch {
+		case lt.Timestamp != nil:
+			if n, ok := toInt64(v); ok {
+				var ts time.Time
+				switch {
+				case lt.Timestamp.Unit.Millis != nil:
+					ts = time.UnixMilli(n)
+				case lt.Timestamp.Unit.Micros != nil:
+					ts = time.UnixMicro(n)
+				default:
+					ts = time.Unix(0, n)
+				}
+				return ts.UTC().Format(time.RFC3339Nano)
+			}
+		case lt.Date != nil:
+			if n, ok := toInt64(v); ok {
+				return time.Unix(n*24*60*60, 0).UTC().Format(time.DateOnly)
+			}
+		case lt.Time != nil:
+			if n, ok := toInt64(v); ok {
+				d := time.Duration(n)
+				switch {
+				case lt.Time.Unit.Millis != nil:
+					d *= time.Millisecond
+				case lt.Time.Unit.Micros != nil:
+					d *= time.Microsecond
+				}
+				return time.Time{}.Add(d).Format("15:04:05.999999999")
+			}
+		case lt.Decimal != nil:
+			if unscaled := toBigInt(v); unscaled != nil {
+				return json.Number(formatDecimal(unscaled, int(lt.Decimal.Scale)))
+			}
+		case lt.UUID != nil:
+			if b, ok := v.([]byte); ok && len(b) == 16 {
+				s := hex.EncodeToString(b)
+				return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+			}
+		case lt.Json != nil:
+			if s, ok := v.(string); ok && json.Valid([]byte(s)) {
+				return json.RawMessage(s)
+			}
+		}
+	}
+
+	switch v := v.(type) {
+	case deprecated.Int96:
+		// legacy timestamps, encoded as nanoseconds within a Julian day
+		nanos := int64(uint64(v[1])<<32 | uint64(v[0]))
+		days := int64(v[2]) - julianUnixEpoch
+		return time.Unix(days*24*60*60, nanos).UTC().Format(time.RFC3339Nano)
+	case float32:
+		return convertFloat(float64(v))
+	case float64:
+		return convertFloat(v)
+	}
+	return v
+}
+
+// convertFloat encodes values which JSON cannot represent as strings.
+func convertFloat(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
+
+func toInt64(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// toBigInt converts the unscaled value of a decimal. Byte arrays contain a
+// big-endian two's complement integer.
+func toBigInt(v any) *big.Int {
+	var b []byte
+	switch v := v.(type) {
+	case int32:
+		return big.NewInt(int64(v))
+	case int64:
+		return big.NewInt(v)
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil
+	}
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return n
+}
+
+func formatDecimal(unscaled *big.Int, scale int) string {
+	if scale <= 0 {
+		return unscaled.String()
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	for len(digits) <= scale {
+		digits = "0" + digits
+	}
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
@@ -0,0 +1,248 @@
+package decoders
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LineParser converts a line of text into a JSON object. Lines which do not
+// contain a record return nil.
+type LineParser func(line string) ([]byte, error)
+
+// LineDecoderFactory returns a factory for decoders which emit one record
+// per line. The parser is constructed per object, since it may track state
+// such as headers.
+func LineDecoderFactory(newParser func(map[string]string) LineParser) DecoderFactory {
+	return func(r io.Reader, params map[string]string) Decoder {
+		return &LineDecoder{
+			Reader: bufio.NewReader(r),
+			Parse:  newParser(params),
+		}
+	}
+}
+
+// LineDecoder reads records from lines of text.
+type LineDecoder struct {
+	*bufio.Reader
+	Parse LineParser
+
+	next []byte
+	err  error
+	done bool
+}
+
+// advance reads lines until a record is found.
+func (dec *LineDecoder) advance() {
+	for dec.next == nil && dec.err == nil && !dec.done {
+		line, err := dec.ReadString('\n')
+		switch {
+		case errors.Is(err, io.EOF):
+			dec.done = true
+		case err != nil:
+			dec.err = fmt.Errorf("failed to read line: %w", err)
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+		dec.next, dec.err = dec.Parse(line)
+	}
+}
+
+func (dec *LineDecoder) Decode(v any) error {
+	dec.advance()
+	if dec.err != nil {
+		return dec.err
+	}
+	if dec.next == nil {
+		return io.EOF
+	}
+	data := dec.next
+	dec.next = nil
+
+	// avoid unmarshalling if possible
+	if r, ok := v.(*json.RawMessage); ok {
+		*r = data
+	} else if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode record: %w", err)
+	}
+	return nil
+}
+
+// More checks if there is more input.
+func (dec *LineDecoder) More() bool {
+	dec.advance()
+	return dec.next != nil || dec.err != nil
+}
+
+// FieldType determines how a field value is encoded.
+type FieldType int
+
+const (
+	// FieldString values are encoded as strings.
+	FieldString FieldType = iota
+	// FieldNumber values are encoded as numbers where possible.
+	FieldNumber
+	// FieldAddress values of the form "ip:port" are split into "<name>_ip"
+	// and "<name>_port" fields.
+	FieldAddress
+	// FieldRequest values containing an HTTP request line are split into
+	// "<name>_method", "<name>_url" and "<name>_protocol" fields.
+	FieldRequest
+)
+
+// Field describes a value within a record.
+type Field struct {
+	Name string
+	Type FieldType
+}
+
+// Fields returns string fields for a list of names.
+func Fields(names ...string) []Field {
+	fields := make([]Field, len(names))
+	for i, name := range names {
+		fields[i] = Field{Name: name}
+	}
+	return fields
+}
+
+func stringField(name string) Field  { return Field{Name: name} }
+func numberField(name string) Field  { return Field{Name: name, Type: FieldNumber} }
+func addressField(name string) Field { return Field{Name: name, Type: FieldAddress} }
+func requestField(name string) Field { return Field{Name: name, Type: FieldRequest} }
+
+// FieldsParser returns a parser for lines of space separated values with a
+// fixed set of fields.
+func FieldsParser(fields ...Field) func(map[string]string) LineParser {
+	return func(map[string]string) LineParser {
+		return func(line string) ([]byte, error) {
+			return encodeRecord(fields, splitFields(line)), nil
+		}
+	}
+}
+
+// splitFields splits a line on spaces. Quoted segments may contain spaces,
+// and a backslash within quotes escapes the following character. A value
+// enclosed in square brackets may also contain spaces. Quotes and enclosing
+// brackets are removed.
+func splitFields(line string) (fields []string) {
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		if line[i] == '[' {
+			if end := strings.IndexByte(line[i:], ']'); end >= 0 && (i+end+1 == len(line) || line[i+end+1] == ' ') {
+				fields = append(fields, line[i+1:i+end])
+				i += end + 1
+				continue
+			}
+		}
+
+		var b strings.Builder
+		for quoted := false; i < len(line) && (quoted || line[i] != ' '); i++ {
+			switch {
+			case line[i] == '"':
+				quoted = !quoted
+			case quoted && line[i] == '\\' && i+1 < len(line):
+				i++
+				b.WriteByte(line[i])
+			default:
+				b.WriteByte(line[i])
+			}
+		}
+		fields = append(fields, b.String())
+	}
+	return fields
+}
+
+// encodeRecord encodes values as a JSON object keyed by the field at the
+// same position. Empty values, values of "-", and values without a field are
+// omitted.
+func encodeRecord(fields []Field, values []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{`)
+	for i, value := range values {
+		if i >= len(fields) {
+			break
+		}
+		if value == "" || value == "-" {
+			continue
+		}
+		field := fields[i]
+		switch field.Type {
+		case FieldNumber:
+			writeNumber(&buf, field.Name, value)
+		case FieldAddress:
+			ip, port := value, ""
+			if i := strings.LastIndexByte(value, ':'); i >= 0 && !strings.HasSuffix(value, "]") {
+				ip, port = value[:i], value[i+1:]
+			}
+			writeMember(&buf, field.Name+"_ip", strings.Trim(ip, "[]"))
+			if port != "" {
+				writeNumber(&buf, field.Name+"_port", port)
+			}
+		case FieldRequest:
+			parts := strings.Fields(value)
+			if strings.Trim(value, "- ") == "" {
+				// TCP listeners have no request
+				continue
+			}
+			if len(parts) != 3 {
+				writeMember(&buf, field.Name, value)
+				continue
+			}
+			writeMember(&buf, field.Name+"_method", parts[0])
+			writeMember(&buf, field.Name+"_url", parts[1])
+			writeMember(&buf, field.Name+"_protocol", parts[2])
+		default:
+			writeMember(&buf, field.Name, value)
+		}
+	}
+	buf.WriteString(`}`)
+	return buf.Bytes()
+}
+
+// writeMember writes a string member to a JSON object.
+func writeMember(buf *bytes.Buffer, name, value string) {
+	if buf.Len() != 1 {
+		buf.WriteString(`,`)
+	}
+	writeString(buf, name)
+	buf.WriteString(`:`)
+	writeString(buf, value)
+}
+
+// writeNumber writes a numeric member to a JSON object. Values which are not
+// valid numbers are written as strings.
+func writeNumber(buf *bytes.Buffer, name, value string) {
+	if _, err := strconv.ParseFloat(value, 64); err != nil || !json.Valid([]byte(value)) {
+		writeMember(buf, name, value)
+		return
+	}
+	if buf.Len() != 1 {
+		buf.WriteString(`,`)
+	}
+	writeString(buf, name)
+	buf.WriteString(`:`)
+	buf.WriteString(value)
+}
+
+// writeString writes s as a JSON string without escaping HTML characters,
+// which are common in URLs.
+func writeString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	// strings always encode successfully
+	_ = enc.Encode(s)
+	// discard trailing newline
+	buf.Truncate(buf.Len() - 1)
+}
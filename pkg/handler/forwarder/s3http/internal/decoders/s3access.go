@@ -0,0 +1,35 @@
+package decoders
+
+// S3AccessLogFields lists the fields of an S3 server access log record, in
+// order. Fields appended by future revisions of the format are discarded.
+var S3AccessLogFields = Fields(
+	"bucket_owner",
+	"bucket",
+	"time",
+	"remote_ip",
+	"requester",
+	"request_id",
+	"operation",
+	"key",
+	"request_uri",
+	"http_status",
+	"error_code",
+	"bytes_sent",
+	"object_size",
+	"total_time",
+	"turn_around_time",
+	"referer",
+	"user_agent",
+	"version_id",
+	"host_id",
+	"signature_version",
+	"cipher_suite",
+	"authentication_type",
+	"host_header",
+	"tls_version",
+	"access_point_arn",
+	"acl_required",
+)
+
+// S3AccessLogDecoderFactory handles S3 server access logs.
+var S3AccessLogDecoderFactory = LineDecoderFactory(FieldsParser(S3AccessLogFields...))
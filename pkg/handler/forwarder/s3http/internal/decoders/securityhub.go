@@ -0,0 +1,83 @@
+package decoders
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SecurityHubDecoderFactory handles Security Hub findings exports. Exports
+// consist of a stream of JSON values, each of which may be an EventBridge
+// event wrapping findings, a GetFindings response, or a single finding.
+// Every finding is emitted as a separate record.
+func SecurityHubDecoderFactory(r io.Reader, _ map[string]string) Decoder {
+	return &SecurityHubDecoder{
+		decoder: json.NewDecoder(r),
+	}
+}
+
+type SecurityHubDecoder struct {
+	decoder *json.Decoder
+	pending []json.RawMessage
+	err     error
+}
+
+// securityHubEnvelope matches values which wrap findings.
+type securityHubEnvelope struct {
+	Detail *struct {
+		Findings []json.RawMessage `json:"findings"`
+	} `json:"detail"`
+	Findings []json.RawMessage `json:"Findings"`
+}
+
+// advance reads values until a finding is found.
+func (dec *SecurityHubDecoder) advance() {
+	for len(dec.pending) == 0 && dec.err == nil && dec.decoder.More() {
+		var value json.RawMessage
+		if err := dec.decoder.Decode(&value); err != nil {
+			dec.err = fmt.Errorf("failed to decode value: %w", err)
+			return
+		}
+
+		var envelope securityHubEnvelope
+		if err := json.Unmarshal(value, &envelope); err != nil {
+			// not an object, treat as an opaque record
+			dec.pending = append(dec.pending, value)
+			continue
+		}
+
+		switch {
+		case envelope.Detail != nil && envelope.Detail.Findings != nil:
+			dec.pending = envelope.Detail.Findings
+		case envelope.Findings != nil:
+			dec.pending = envelope.Findings
+		default:
+			dec.pending = append(dec.pending, value)
+		}
+	}
+}
+
+func (dec *SecurityHubDecoder) Decode(v any) error {
+	dec.advance()
+	if dec.err != nil {
+		return dec.err
+	}
+	if len(dec.pending) == 0 {
+		return io.EOF
+	}
+	finding := dec.pending[0]
+	dec.pending = dec.pending[1:]
+
+	if r, ok := v.(*json.RawMessage); ok {
+		*r = finding
+	} else if err := json.Unmarshal(finding, v); err != nil {
+		return fmt.Errorf("failed to decode finding: %w", err)
+	}
+	return nil
+}
+
+// More checks if there is more input.
+func (dec *SecurityHubDecoder) More() bool {
+	dec.advance()
+	return len(dec.pending) > 0 || dec.err != nil
+}
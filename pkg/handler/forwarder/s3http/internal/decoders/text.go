@@ -17,6 +17,7 @@ func TextDecoderFactory(r io.Reader, _ map[string]string) Decoder {
 
 type TextDecoder struct {
 	*bufio.Reader
+	offset int64
 }
 
 func (dec *TextDecoder) Decode(v any) error {
@@ -24,6 +25,7 @@ func (dec *TextDecoder) Decode(v any) error {
 	if err != nil && err != io.EOF {
 		return fmt.Errorf("failed to read text: %w", err)
 	}
+	dec.offset += int64(len(s))
 
 	var buf bytes.Buffer
 	buf.WriteString(`{"text":` + strconv.Quote(s) + `}`)
@@ -38,3 +40,8 @@ func (dec *TextDecoder) More() bool {
 	_, err := dec.Peek(1)
 	return err != io.EOF
 }
+
+// ResumeOffset returns the byte offset following the last decoded line.
+func (dec *TextDecoder) ResumeOffset() int64 {
+	return dec.offset
+}
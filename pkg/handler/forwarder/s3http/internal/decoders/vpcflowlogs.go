@@ -0,0 +1,40 @@
+package decoders
+
+import "strings"
+
+// vpcFlowLogNumbers lists VPC flow log fields with numeric values. All
+// other fields are encoded as strings.
+var vpcFlowLogNumbers = map[string]bool{
+	"version":      true,
+	"srcport":      true,
+	"dstport":      true,
+	"protocol":     true,
+	"packets":      true,
+	"bytes":        true,
+	"start":        true,
+	"end":          true,
+	"tcp-flags":    true,
+	"traffic-path": true,
+}
+
+// VPCFlowLogDecoderFactory handles VPC flow logs. Field names are read from
+// the header, which lists the fields of the default or custom log format.
+var VPCFlowLogDecoderFactory = LineDecoderFactory(VPCFlowLogParser)
+
+func VPCFlowLogParser(map[string]string) LineParser {
+	var fields []Field
+	return func(line string) ([]byte, error) {
+		values := strings.Fields(line)
+		if fields == nil {
+			fields = make([]Field, len(values))
+			for i, name := range values {
+				fields[i] = Field{Name: name}
+				if vpcFlowLogNumbers[name] {
+					fields[i].Type = FieldNumber
+				}
+			}
+			return nil, nil
+		}
+		return encodeRecord(fields, values), nil
+	}
+}
@@ -0,0 +1,37 @@
+package decoders
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrMissingFields = errors.New("missing #Fields directive")
+
+// W3CDecoderFactory handles the W3C extended log file format, as used by
+// CloudFront standard logs. Field names are read from the "#Fields:"
+// directive, and values are separated by tabs. Lines without tabs are split
+// on spaces.
+var W3CDecoderFactory = LineDecoderFactory(W3CParser)
+
+func W3CParser(map[string]string) LineParser {
+	var fields []Field
+	return func(line string) ([]byte, error) {
+		if directive, ok := strings.CutPrefix(line, "#"); ok {
+			if names, ok := strings.CutPrefix(directive, "Fields:"); ok {
+				fields = Fields(strings.Fields(names)...)
+			}
+			return nil, nil
+		}
+		if fields == nil {
+			return nil, ErrMissingFields
+		}
+
+		var values []string
+		if strings.Contains(line, "\t") {
+			values = strings.Split(line, "\t")
+		} else {
+			values = splitFields(line)
+		}
+		return encodeRecord(fields, values), nil
+	}
+}
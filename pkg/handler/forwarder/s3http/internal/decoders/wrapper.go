@@ -1,10 +1,13 @@
 package decoders
 
 import (
+	"compress/bzip2"
 	"fmt"
 	"io"
 	"log"
 
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
 	gzip "github.com/klauspost/pgzip"
 )
 
@@ -12,27 +15,57 @@ var wrappers = map[string]Wrapper{
 	"": func(fn DecoderFactory) DecoderFactory {
 		return fn
 	},
-	"gzip": GzipWrapper,
+	"gzip":   GzipWrapper,
+	"zstd":   ZstdWrapper,
+	"bzip2":  Bzip2Wrapper,
+	"snappy": SnappyWrapper,
 }
 
 type Wrapper func(DecoderFactory) DecoderFactory
 
-func GzipWrapper(fn DecoderFactory) DecoderFactory {
-	return func(r io.Reader, params map[string]string) Decoder {
-		gr, err := gzip.NewReader(r)
+// All wrappers decode concatenated streams, as produced by appending
+// separately compressed members to a single object.
+var (
+	GzipWrapper = decompressWrapper("gzip", func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+	ZstdWrapper = decompressWrapper("zstd", func(r io.Reader) (io.ReadCloser, error) {
+		zr, err := zstd.NewReader(r)
 		if err != nil {
-			return &errorDecoder{fmt.Errorf("failed to read gzip: %w", err)}
+			return nil, err
 		}
+		return zr.IOReadCloser(), nil
+	})
+	Bzip2Wrapper = decompressWrapper("bzip2", func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	})
+	// SnappyWrapper handles the Snappy framing format. Unframed blocks are
+	// not supported, since they cannot be streamed.
+	SnappyWrapper = decompressWrapper("snappy", func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(snappy.NewReader(r)), nil
+	})
+)
 
-		pr, pw := io.Pipe()
-		go func() {
-			_, copyErr := io.Copy(pw, gr)
-			if closeErr := gr.Close(); closeErr != nil {
-				log.Printf("failed to close gzip reader: %v", closeErr)
+// decompressWrapper returns a wrapper which decompresses input in a separate
+// goroutine.
+func decompressWrapper(name string, newReader func(io.Reader) (io.ReadCloser, error)) Wrapper {
+	return func(fn DecoderFactory) DecoderFactory {
+		return func(r io.Reader, params map[string]string) Decoder {
+			dr, err := newReader(r)
+			if err != nil {
+				return &errorDecoder{fmt.Errorf("failed to read %s: %w", name, err)}
 			}
-			pw.CloseWithError(copyErr)
-		}()
 
-		return fn(pr, params)
+			pr, pw := io.Pipe()
+			go func() {
+				_, copyErr := io.Copy(pw, dr)
+				if closeErr := dr.Close(); closeErr != nil {
+					log.Printf("failed to close %s reader: %v", name, closeErr)
+				}
+				pw.CloseWithError(copyErr)
+			}()
+
+			return fn(pr, params)
+		}
 	}
 }
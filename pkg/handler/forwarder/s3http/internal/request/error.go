@@ -0,0 +1,108 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBodySnippet bounds how much of a response body is retained in an error.
+const maxBodySnippet = 512
+
+// Error describes a failed request. Errors without a status code occurred
+// before a response was received, e.g. due to a connection reset.
+type Error struct {
+	Err        error
+	StatusCode int
+	// RetryAfter is the delay requested by the destination, if any.
+	RetryAfter time.Duration
+	// Body contains the start of the response body.
+	Body string
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("failed to execute request: %s", e.Err)
+	}
+	msg := fmt.Sprintf("%s: %s", e.Err, strings.ToLower(http.StatusText(e.StatusCode)))
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusCode returns the response status code, if any.
+func (e *Error) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// Retryable verifies if the request may succeed if submitted again.
+// Requests which were rejected as malformed, unauthorized or too large will
+// fail regardless.
+func (e *Error) Retryable() bool {
+	switch {
+	case e.StatusCode == 0:
+		return true
+	case e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryDelay returns the delay requested by the destination before retrying.
+// Delays are ignored for requests which cannot succeed on retry.
+func (e *Error) RetryDelay() time.Duration {
+	if !e.Retryable() {
+		return 0
+	}
+	return e.RetryAfter
+}
+
+// newStatusError builds an error from an unsuccessful response, consuming
+// at most maxBodySnippet bytes of the body.
+func newStatusError(resp *http.Response, now time.Time) *Error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodySnippet))
+	return &Error{
+		Err:        ErrStatus,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), now),
+		// the snippet may split a multi-byte character
+		Body: strings.ToValidUTF8(string(bytes.TrimSpace(data)), ""),
+	}
+}
+
+// parseRetryAfter parses a Retry-After header, which contains either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+		if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// IsTooLarge verifies if a request was rejected due to the size of its body.
+func IsTooLarge(err error) bool {
+	var requestErr *Error
+	return errors.As(err, &requestErr) && requestErr.StatusCode == http.StatusRequestEntityTooLarge
+}
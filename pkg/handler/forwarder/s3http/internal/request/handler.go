@@ -8,7 +8,7 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"strings"
+	"time"
 )
 
 // Handler processes batches of data towards same URI.
@@ -19,7 +19,8 @@ type Handler struct {
 	Client    Doer
 }
 
-// Handle a batch of data.
+// Handle a batch of data. Failed requests return an *Error, which reports
+// whether the request may be retried.
 func (h *Handler) Handle(ctx context.Context, body io.Reader) error {
 	if h.GzipLevel != 0 {
 		var buf bytes.Buffer
@@ -47,21 +48,26 @@ func (h *Handler) Handle(ctx context.Context, body io.Reader) error {
 
 	resp, err := h.Client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		return &Error{Err: err}
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
 			log.Printf("failed to close response body: %v", closeErr)
 		}
 	}()
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
 
+	var statusErr *Error
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
 	default:
-		return fmt.Errorf("%w: %s", ErrStatus, strings.ToLower(http.StatusText(resp.StatusCode)))
+		statusErr = newStatusError(resp, time.Now())
+	}
+
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil && statusErr == nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if statusErr != nil {
+		return statusErr
 	}
 	return nil
 }
@@ -3,11 +3,15 @@ package request_test
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/request"
 )
@@ -50,3 +54,132 @@ func TestHandler(t *testing.T) {
 		t.Fatal("failed to handle gzipped request:", err)
 	}
 }
+
+func TestHandlerErrors(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name            string
+		StatusCode      int
+		Header          map[string]string
+		Body            string
+		ExpectRetryable bool
+		ExpectRetry     time.Duration
+		ExpectBody      string
+	}{
+		{
+			Name:            "too many requests",
+			StatusCode:      http.StatusTooManyRequests,
+			Header:          map[string]string{"Retry-After": "7"},
+			ExpectRetryable: true,
+			ExpectRetry:     7 * time.Second,
+		},
+		{
+			Name:            "unavailable",
+			StatusCode:      http.StatusServiceUnavailable,
+			Header:          map[string]string{"Retry-After": "not a date"},
+			Body:            "down for maintenance\n",
+			ExpectRetryable: true,
+			ExpectBody:      "down for maintenance",
+		},
+		{
+			// retrying cannot succeed, so the delay is disregarded
+			Name:        "bad request",
+			StatusCode:  http.StatusBadRequest,
+			Header:      map[string]string{"Retry-After": "5"},
+			Body:        `{"error": "malformed"}`,
+			ExpectRetry: 5 * time.Second,
+			ExpectBody:  `{"error": "malformed"}`,
+		},
+		{
+			Name:       "unauthorized",
+			StatusCode: http.StatusUnauthorized,
+		},
+		{
+			Name:       "too large",
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Body:       strings.Repeat("x", 1024),
+			ExpectBody: strings.Repeat("x", 512),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				for k, v := range tc.Header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tc.StatusCode)
+				_, _ = io.WriteString(w, tc.Body)
+			}))
+			defer s.Close()
+
+			h := &request.Handler{
+				URL:    s.URL,
+				Client: s.Client(),
+			}
+
+			err := h.Handle(context.Background(), strings.NewReader("test"))
+			if !errors.Is(err, request.ErrStatus) {
+				t.Fatalf("expected status error, got %v", err)
+			}
+
+			var requestErr *request.Error
+			if !errors.As(err, &requestErr) {
+				t.Fatalf("expected request error, got %T", err)
+			}
+			if requestErr.StatusCode != tc.StatusCode {
+				t.Errorf("expected status %d, got %d", tc.StatusCode, requestErr.StatusCode)
+			}
+			if requestErr.Retryable() != tc.ExpectRetryable {
+				t.Errorf("expected retryable to be %t", tc.ExpectRetryable)
+			}
+			if requestErr.RetryAfter != tc.ExpectRetry {
+				t.Errorf("expected retry after %s, got %s", tc.ExpectRetry, requestErr.RetryAfter)
+			}
+			expectDelay := tc.ExpectRetry
+			if !tc.ExpectRetryable {
+				expectDelay = 0
+			}
+			if d := requestErr.RetryDelay(); d != expectDelay {
+				t.Errorf("expected retry delay %s, got %s", expectDelay, d)
+			}
+			if request.IsTooLarge(err) != (tc.StatusCode == http.StatusRequestEntityTooLarge) {
+				t.Error("unexpected result for IsTooLarge")
+			}
+			if diff := cmp.Diff(requestErr.Body, tc.ExpectBody); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHandlerConnectionError(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// drop the connection without responding
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			panic(err)
+		}
+		_ = conn.Close()
+	}))
+	defer s.Close()
+
+	h := &request.Handler{
+		URL:    s.URL,
+		Client: s.Client(),
+	}
+
+	err := h.Handle(context.Background(), strings.NewReader("test"))
+	var requestErr *request.Error
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("expected request error, got %v", err)
+	}
+	if requestErr.StatusCode != 0 || !requestErr.Retryable() {
+		t.Errorf("expected retryable connection error, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+// Package sniff infers how to decode an object from its leading bytes.
+package sniff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// PeekSize is the number of leading bytes inspected.
+const PeekSize = 8 * 1024
+
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeJSONArray   = "application/x-json-array"
+	ContentTypeJSONRecords = "application/x-json-records"
+	ContentTypeNDJSON      = "application/x-ndjson"
+	ContentTypeCSV         = "text/csv"
+	ContentTypeParquet     = "application/vnd.apache.parquet"
+	ContentTypeText        = "text/plain"
+
+	ContentEncodingGzip = "gzip"
+	ContentEncodingZstd = "zstd"
+)
+
+var (
+	magicGzip     = []byte{0x1f, 0x8b, 0x08}
+	magicZstd     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicParquet  = []byte("PAR1")
+	byteOrderMark = []byte("\xef\xbb\xbf")
+)
+
+// Result of inspecting an object. Empty values could not be inferred.
+type Result struct {
+	ContentType     string
+	ContentEncoding string
+}
+
+// Peek reads up to PeekSize bytes from r, and rewinds it.
+func Peek(r io.ReadSeeker) ([]byte, error) {
+	data := make([]byte, PeekSize)
+	n, err := io.ReadFull(r, data)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// Detect infers content type and encoding from the leading bytes of an
+// object. Compressed data is decompressed before inferring the content type.
+// The data may be truncated at any point.
+func Detect(data []byte) (result Result) {
+	switch {
+	case bytes.HasPrefix(data, magicGzip):
+		result.ContentEncoding = ContentEncodingGzip
+		data = gunzip(data)
+	case bytes.HasPrefix(data, magicZstd):
+		result.ContentEncoding = ContentEncodingZstd
+		data = unzstd(data)
+	}
+	result.ContentType = detectContentType(data)
+	return result
+}
+
+func detectContentType(data []byte) string {
+	if bytes.HasPrefix(data, magicParquet) {
+		return ContentTypeParquet
+	}
+
+	data = bytes.TrimPrefix(data, byteOrderMark)
+	if !isText(data) {
+		return ""
+	}
+
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return ""
+	}
+
+	switch trimmed[0] {
+	case '[':
+		if isJSON(trimmed) {
+			return ContentTypeJSONArray
+		}
+	case '{':
+		if contentType := detectJSONObject(trimmed); contentType != "" {
+			return contentType
+		}
+	}
+
+	if isCSV(data) {
+		return ContentTypeCSV
+	}
+	return ContentTypeText
+}
+
+// isJSON verifies data is a valid, possibly truncated, JSON document.
+func isJSON(data []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var tokens int
+	for {
+		_, err := dec.Token()
+		switch {
+		case err == nil:
+			tokens++
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return tokens > 0
+		default:
+			return false
+		}
+	}
+}
+
+// detectJSONObject distinguishes a single JSON object, a stream of newline
+// delimited objects, and an object wrapping an array of records.
+func detectJSONObject(data []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return ""
+	}
+	if tok, err := dec.Token(); err == nil && tok == "Records" {
+		if tok, err := dec.Token(); err == nil && tok == json.Delim('[') {
+			return ContentTypeJSONRecords
+		}
+	}
+
+	if !isJSON(data) {
+		return ""
+	}
+
+	dec = json.NewDecoder(bytes.NewReader(data))
+	var v json.RawMessage
+	if err := dec.Decode(&v); err != nil {
+		// object exceeds the inspected data
+		return ContentTypeJSON
+	}
+	rest := data[dec.InputOffset():]
+	if len(bytes.TrimSpace(rest)) == 0 {
+		return ContentTypeJSON
+	}
+	next := bytes.TrimLeft(rest, " \t\r\n")
+	if separator := rest[:len(rest)-len(next)]; bytes.ContainsRune(separator, '\n') && bytes.HasPrefix(next, []byte("{")) {
+		return ContentTypeNDJSON
+	}
+	return ""
+}
+
+// isCSV verifies data contains a header followed by at least one row with
+// the same number of fields. The header must not contain numbers.
+func isCSV(data []byte) bool {
+	// discard trailing partial line
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[:i+1]
+	} else {
+		return false
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	records, err := r.ReadAll()
+	if err != nil || len(records) < 2 {
+		return false
+	}
+
+	header := records[0]
+	if len(header) < 2 {
+		return false
+	}
+	for _, field := range header {
+		if field == "" {
+			return false
+		}
+		if _, err := strconv.ParseFloat(field, 64); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isText verifies data is UTF-8 without control characters, ignoring a
+// truncated trailing rune.
+func isText(data []byte) bool {
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 {
+			return len(data) < utf8.UTFMax && !utf8.FullRune(data)
+		}
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+		if r == 0x7f {
+			return false
+		}
+		data = data[size:]
+	}
+	return true
+}
+
+func gunzip(data []byte) []byte {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return readPartial(gr)
+}
+
+func unzstd(data []byte) []byte {
+	zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	return readPartial(zr)
+}
+
+// readPartial reads up to PeekSize bytes, tolerating truncated input.
+func readPartial(r io.Reader) []byte {
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, io.LimitReader(r, PeekSize))
+	return buf.Bytes()
+}
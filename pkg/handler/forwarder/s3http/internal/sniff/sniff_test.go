@@ -0,0 +1,153 @@
+package sniff_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/internal/sniff"
+)
+
+func gzipped(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func zstded(t *testing.T, s string) string {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	return string(enc.EncodeAll([]byte(s), nil))
+}
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	large := `{"message": "` + strings.Repeat("a", sniff.PeekSize) + `"}`
+
+	var lines strings.Builder
+	for i := range 10000 {
+		fmt.Fprintf(&lines, "line %d of text\n", i)
+	}
+
+	testcases := []struct {
+		Name   string
+		Input  string
+		Expect sniff.Result
+	}{
+		{
+			Name:   "ndjson",
+			Input:  "{\"a\": 1}\n{\"a\": 2}\n{\"a\":",
+			Expect: sniff.Result{ContentType: sniff.ContentTypeNDJSON},
+		},
+		{
+			Name:   "json",
+			Input:  `{"a": {"b": [1, 2, 3]}}`,
+			Expect: sniff.Result{ContentType: sniff.ContentTypeJSON},
+		},
+		{
+			Name:   "truncated json",
+			Input:  large[:sniff.PeekSize],
+			Expect: sniff.Result{ContentType: sniff.ContentTypeJSON},
+		},
+		{
+			Name:   "json array",
+			Input:  " [{\"a\": 1}, {\"a\": 2}",
+			Expect: sniff.Result{ContentType: sniff.ContentTypeJSONArray},
+		},
+		{
+			Name:   "records",
+			Input:  `{"Records": [{"eventVersion": "1.08"}, {"eventVersion"`,
+			Expect: sniff.Result{ContentType: sniff.ContentTypeJSONRecords},
+		},
+		{
+			Name:   "csv",
+			Input:  "\xef\xbb\xbfname,age,city\nalice,30,paris\nbob,4",
+			Expect: sniff.Result{ContentType: sniff.ContentTypeCSV},
+		},
+		{
+			Name:   "csv without header",
+			Input:  "1,2,3\n4,5,6\n",
+			Expect: sniff.Result{ContentType: sniff.ContentTypeText},
+		},
+		{
+			Name:   "text",
+			Input:  "[INFO] starting\n{not json}\n",
+			Expect: sniff.Result{ContentType: sniff.ContentTypeText},
+		},
+		{
+			Name:   "truncated rune",
+			Input:  "caf\xc3",
+			Expect: sniff.Result{ContentType: sniff.ContentTypeText},
+		},
+		{
+			Name:   "parquet",
+			Input:  "PAR1\x15\x00\x15",
+			Expect: sniff.Result{ContentType: sniff.ContentTypeParquet},
+		},
+		{
+			Name:  "binary",
+			Input: "\x00\x01\x02\x03",
+		},
+		{
+			Name:  "empty",
+			Input: "",
+		},
+		{
+			Name:   "gzip ndjson",
+			Input:  gzipped(t, "{\"a\": 1}\n{\"a\": 2}\n"),
+			Expect: sniff.Result{ContentType: sniff.ContentTypeNDJSON, ContentEncoding: sniff.ContentEncodingGzip},
+		},
+		{
+			Name:   "truncated gzip",
+			Input:  gzipped(t, lines.String())[:1024],
+			Expect: sniff.Result{ContentType: sniff.ContentTypeText, ContentEncoding: sniff.ContentEncodingGzip},
+		},
+		{
+			Name:   "zstd csv",
+			Input:  zstded(t, "a,b\n1,2\n"),
+			Expect: sniff.Result{ContentType: sniff.ContentTypeCSV, ContentEncoding: sniff.ContentEncodingZstd},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(sniff.Detect([]byte(tc.Input)), tc.Expect); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPeek(t *testing.T) {
+	t.Parallel()
+
+	r := strings.NewReader(strings.Repeat("a", 2*sniff.PeekSize))
+	data, err := sniff.Peek(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != sniff.PeekSize {
+		t.Errorf("unexpected length %d", len(data))
+	}
+	if r.Len() != 2*sniff.PeekSize {
+		t.Error("expected reader to be rewound")
+	}
+}
@@ -0,0 +1,338 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+)
+
+var (
+	ErrInvalidSourceRole   = errors.New("invalid source role")
+	ErrMissingSourceClient = errors.New("missing source client constructor")
+
+	errUploadTooLarge = errors.New("object exceeds single upload limit")
+)
+
+// SourceRole maps source buckets to a role assumed to read from them.
+type SourceRole struct {
+	Bucket     string // glob pattern matched against the bucket name
+	RoleARN    string
+	ExternalID string
+}
+
+// NewSourceRoles builds source roles from lists of role ARNs and bucket
+// patterns of equal length, where the Nth pattern selects the Nth role.
+// External IDs are optional. A single external ID applies to every role,
+// otherwise one must be provided per role, with empty values for roles
+// which do not require one.
+func NewSourceRoles(roleARNs, buckets, externalIDs []string) ([]*SourceRole, error) {
+	if len(buckets) != len(roleARNs) {
+		return nil, fmt.Errorf("%w: got %d bucket patterns for %d roles", ErrInvalidSourceRole, len(buckets), len(roleARNs))
+	}
+	if n := len(externalIDs); n > 1 && n != len(roleARNs) {
+		return nil, fmt.Errorf("%w: got %d external IDs for %d roles", ErrInvalidSourceRole, n, len(roleARNs))
+	}
+
+	roles := make([]*SourceRole, len(roleARNs))
+	for i, roleARN := range roleARNs {
+		roles[i] = &SourceRole{
+			Bucket:  buckets[i],
+			RoleARN: roleARN,
+		}
+		switch len(externalIDs) {
+		case 0:
+		case 1:
+			roles[i].ExternalID = externalIDs[0]
+		default:
+			roles[i].ExternalID = externalIDs[i]
+		}
+		if err := roles[i].Validate(); err != nil {
+			return nil, fmt.Errorf("source role %d: %w", i, err)
+		}
+	}
+	return roles, nil
+}
+
+func (r *SourceRole) Validate() error {
+	if r.Bucket == "" {
+		return fmt.Errorf("%w: missing bucket pattern", ErrInvalidSourceRole)
+	}
+	if _, err := arn.Parse(r.RoleARN); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSourceRole, err)
+	}
+	return nil
+}
+
+// compileGlob converts a glob pattern into an anchored regular expression.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, `.*`)
+	expr = strings.ReplaceAll(expr, `\?`, `.`)
+	return regexp.Compile("^" + expr + "$")
+}
+
+// ObjectAttributesAPIClient retrieves object attributes used by override
+// rules.
+type ObjectAttributesAPIClient interface {
+	s3.HeadObjectAPIClient
+	GetObjectTagging(context.Context, *s3.GetObjectTaggingInput, ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+}
+
+// SourceClient reads source objects.
+type SourceClient interface {
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	s3.HeadBucketAPIClient
+	ObjectAttributesAPIClient
+}
+
+// NewSourceClientFunc returns a client for reading from buckets in region.
+// The client must assume role, or use default credentials if role is nil.
+type NewSourceClientFunc func(role *SourceRole, region string) SourceClient
+
+type sourceClientKey struct {
+	roleARN string
+	region  string
+}
+
+// SourceClients reads source objects using credentials and region specific
+// to each bucket. Clients are cached per role and region.
+type SourceClients struct {
+	roles     []*SourceRole
+	patterns  []*regexp.Regexp
+	newClient NewSourceClientFunc
+
+	// LookupRegion returns the region of a bucket.
+	LookupRegion func(ctx context.Context, bucket string) (string, error)
+
+	mu      sync.Mutex
+	clients map[sourceClientKey]SourceClient
+	regions map[string]string
+}
+
+// NewSourceClients initializes source clients. Roles are matched in order.
+func NewSourceClients(roles []*SourceRole, newClient NewSourceClientFunc) (*SourceClients, error) {
+	if newClient == nil {
+		return nil, ErrMissingSourceClient
+	}
+
+	s := &SourceClients{
+		roles:     roles,
+		newClient: newClient,
+		clients:   make(map[sourceClientKey]SourceClient),
+		regions:   make(map[string]string),
+	}
+
+	for i, role := range roles {
+		if err := role.Validate(); err != nil {
+			return nil, fmt.Errorf("source role %d: %w", i, err)
+		}
+		re, err := compileGlob(role.Bucket)
+		if err != nil {
+			return nil, fmt.Errorf("source role %d: %w: %w", i, ErrInvalidSourceRole, err)
+		}
+		s.patterns = append(s.patterns, re)
+	}
+
+	s.LookupRegion = func(ctx context.Context, bucket string) (string, error) {
+		// bucket region lookups are unauthenticated
+		return manager.GetBucketRegion(ctx, s.client(nil, ""), bucket)
+	}
+	return s, nil
+}
+
+// Role returns the role assumed to read from bucket, if any.
+func (s *SourceClients) Role(bucket string) *SourceRole {
+	for i, re := range s.patterns {
+		if re.MatchString(bucket) {
+			return s.roles[i]
+		}
+	}
+	return nil
+}
+
+func (s *SourceClients) client(role *SourceRole, region string) SourceClient {
+	var key sourceClientKey
+	if role != nil {
+		key.roleARN = role.RoleARN
+	}
+	key.region = region
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client, ok := s.clients[key]
+	if !ok {
+		client = s.newClient(role, region)
+		s.clients[key] = client
+	}
+	return client
+}
+
+func (s *SourceClients) region(ctx context.Context, bucket string) (string, error) {
+	s.mu.Lock()
+	region, ok := s.regions[bucket]
+	s.mu.Unlock()
+	if ok {
+		return region, nil
+	}
+
+	region, err := s.LookupRegion(ctx, bucket)
+	if err != nil {
+		return "", fmt.Errorf("failed to get region for bucket %q: %w", bucket, err)
+	}
+
+	logr.FromContextOrDiscard(ctx).V(4).Info("resolved source bucket region", "bucket", bucket, "region", region)
+
+	s.mu.Lock()
+	s.regions[bucket] = region
+	s.mu.Unlock()
+	return region, nil
+}
+
+// Client returns a client for reading from bucket.
+func (s *SourceClients) Client(ctx context.Context, bucket string) (SourceClient, error) {
+	region, err := s.region(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return s.client(s.Role(bucket), region), nil
+}
+
+// GetObject retrieves an object using the client for the requested bucket.
+func (s *SourceClients) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	client, err := s.Client(ctx, aws.ToString(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	return client.GetObject(ctx, params, optFns...)
+}
+
+// HeadObject retrieves object metadata using the client for the requested
+// bucket.
+func (s *SourceClients) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	client, err := s.Client(ctx, aws.ToString(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	return client.HeadObject(ctx, params, optFns...)
+}
+
+// GetObjectTagging retrieves object tags using the client for the requested
+// bucket.
+func (s *SourceClients) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	client, err := s.Client(ctx, aws.ToString(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	return client.GetObjectTagging(ctx, params, optFns...)
+}
+
+// sourceObject returns the source object attributes available to override
+// rules. Attributes are retrieved lazily, using bucket specific credentials
+// if configured.
+func (h *Handler) sourceObject(sourceURL *url.URL, copyRecord *CopyRecord) *override.Object {
+	object := &override.Object{Size: copyRecord.Size, EventTime: copyRecord.EventTime, Now: h.Now}
+
+	var client ObjectAttributesAPIClient
+	if h.Sources != nil {
+		client = h.Sources
+	} else if c, ok := h.S3Client.(ObjectAttributesAPIClient); ok {
+		client = c
+	} else {
+		return object
+	}
+
+	bucket, key := sourceURL.Host, strings.TrimPrefix(sourceURL.Path, "/")
+	var versionID *string
+	if copyRecord.VersionID != "" {
+		versionID = aws.String(copyRecord.VersionID)
+	}
+
+	object.HeadObject = func(ctx context.Context) (*s3.HeadObjectOutput, error) {
+		return client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionID,
+		})
+	}
+	object.GetObjectTagging = func(ctx context.Context) (*s3.GetObjectTaggingOutput, error) {
+		return client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionID,
+		})
+	}
+	return object
+}
+
+// getObject reads a source object, using bucket specific credentials if
+// configured. Otherwise the destination client is used.
+func (h *Handler) getObject(ctx context.Context, client S3Client, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if h.Sources != nil {
+		return h.Sources.GetObject(ctx, input)
+	}
+	return client.GetObject(ctx, input)
+}
+
+// copyThrough copies an object by reading it with source credentials and
+// writing it with destination credentials. It returns the number of bytes
+// written. The object is streamed, and therefore limited to the size of a
+// single PutObject request.
+func (h *Handler) copyThrough(ctx context.Context, client S3Client, copyInput *s3.CopyObjectInput, copyRecord *CopyRecord) (int64, error) {
+	bucket, key, err := parseCopySource(aws.ToString(copyInput.CopySource))
+	if err != nil {
+		return 0, err
+	}
+
+	getInput := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if copyRecord.VersionID != "" {
+		getInput.VersionId = aws.String(copyRecord.VersionID)
+	}
+
+	getResp, err := h.getObject(ctx, client, getInput)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer getResp.Body.Close()
+
+	// objects are uploaded in a single request, since the body is streamed
+	// rather than buffered
+	if n := aws.ToInt64(getResp.ContentLength); n > maxCopyObjectSize {
+		return 0, fmt.Errorf("%w: %d bytes", errUploadTooLarge, n)
+	}
+
+	putInput := &s3.PutObjectInput{
+		Bucket:          copyInput.Bucket,
+		Key:             copyInput.Key,
+		Body:            getResp.Body,
+		ContentLength:   getResp.ContentLength,
+		ContentType:     getResp.ContentType,
+		ContentEncoding: getResp.ContentEncoding,
+		Metadata:        getResp.Metadata,
+	}
+	if copyInput.MetadataDirective == types.MetadataDirectiveReplace {
+		putInput.ContentType = copyInput.ContentType
+		putInput.ContentEncoding = copyInput.ContentEncoding
+		putInput.Metadata = copyInput.Metadata
+	}
+
+	if _, err := client.PutObject(ctx, putInput); err != nil {
+		return 0, fmt.Errorf("failed to put object: %w", err)
+	}
+	return aws.ToInt64(getResp.ContentLength), nil
+}
@@ -0,0 +1,308 @@
+package forwarder_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+func TestNewSourceRoles(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name        string
+		RoleARNs    []string
+		Buckets     []string
+		ExternalIDs []string
+		Expect      []*forwarder.SourceRole
+		ExpectError error
+	}{
+		{
+			Name: "empty",
+		},
+		{
+			Name:     "without external id",
+			RoleARNs: []string{"arn:aws:iam::123456789012:role/reader"},
+			Buckets:  []string{"logs-*"},
+			Expect: []*forwarder.SourceRole{
+				{Bucket: "logs-*", RoleARN: "arn:aws:iam::123456789012:role/reader"},
+			},
+		},
+		{
+			Name:        "shared external id",
+			RoleARNs:    []string{"arn:aws:iam::111111111111:role/reader", "arn:aws:iam::222222222222:role/reader"},
+			Buckets:     []string{"a-*", "b-*"},
+			ExternalIDs: []string{"secret"},
+			Expect: []*forwarder.SourceRole{
+				{Bucket: "a-*", RoleARN: "arn:aws:iam::111111111111:role/reader", ExternalID: "secret"},
+				{Bucket: "b-*", RoleARN: "arn:aws:iam::222222222222:role/reader", ExternalID: "secret"},
+			},
+		},
+		{
+			Name:        "external id per role",
+			RoleARNs:    []string{"arn:aws:iam::111111111111:role/reader", "arn:aws:iam::222222222222:role/reader"},
+			Buckets:     []string{"a-*", "b-*"},
+			ExternalIDs: []string{"", "secret"},
+			Expect: []*forwarder.SourceRole{
+				{Bucket: "a-*", RoleARN: "arn:aws:iam::111111111111:role/reader"},
+				{Bucket: "b-*", RoleARN: "arn:aws:iam::222222222222:role/reader", ExternalID: "secret"},
+			},
+		},
+		{
+			Name:        "missing bucket pattern",
+			RoleARNs:    []string{"arn:aws:iam::123456789012:role/reader"},
+			ExpectError: forwarder.ErrInvalidSourceRole,
+		},
+		{
+			Name:        "empty bucket pattern",
+			RoleARNs:    []string{"arn:aws:iam::123456789012:role/reader"},
+			Buckets:     []string{""},
+			ExpectError: forwarder.ErrInvalidSourceRole,
+		},
+		{
+			Name:        "invalid role",
+			RoleARNs:    []string{"reader"},
+			Buckets:     []string{"logs-*"},
+			ExpectError: forwarder.ErrInvalidSourceRole,
+		},
+		{
+			Name:        "mismatched external ids",
+			RoleARNs:    []string{"arn:aws:iam::111111111111:role/reader", "arn:aws:iam::222222222222:role/reader", "arn:aws:iam::333333333333:role/reader"},
+			Buckets:     []string{"a-*", "b-*", "c-*"},
+			ExternalIDs: []string{"a", "b"},
+			ExpectError: forwarder.ErrInvalidSourceRole,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			got, err := forwarder.NewSourceRoles(tc.RoleARNs, tc.Buckets, tc.ExternalIDs)
+			if diff := cmp.Diff(err, tc.ExpectError, cmpopts.EquateErrors()); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(got, tc.Expect, cmpopts.EquateEmpty()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+type sourceClientKey struct {
+	Role   string
+	Region string
+}
+
+// newSourceClients returns source clients which record the role and region
+// each object was read with.
+func newSourceClients(t *testing.T, roles []*forwarder.SourceRole, regions map[string]string) (*forwarder.SourceClients, map[string]sourceClientKey, *int) {
+	t.Helper()
+
+	var (
+		mu      sync.Mutex
+		reads   = make(map[string]sourceClientKey)
+		created int
+	)
+
+	sources, err := forwarder.NewSourceClients(roles, func(role *forwarder.SourceRole, region string) forwarder.SourceClient {
+		mu.Lock()
+		created++
+		mu.Unlock()
+
+		key := sourceClientKey{Region: region}
+		if role != nil {
+			key.Role = role.RoleARN
+		}
+		return &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				reads[aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key)] = key
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewBufferString("data")),
+					ContentLength: aws.Int64(4),
+					ContentType:   aws.String("text/plain"),
+				}, nil
+			},
+			HeadObjectFunc: func(_ context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				reads[aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key)] = key
+				return &s3.HeadObjectOutput{
+					ContentType: aws.String("text/plain"),
+				}, nil
+			},
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources.LookupRegion = func(_ context.Context, bucket string) (string, error) {
+		return regions[bucket], nil
+	}
+	return sources, reads, &created
+}
+
+func TestSourceClients(t *testing.T) {
+	t.Parallel()
+
+	roles := []*forwarder.SourceRole{
+		{Bucket: "logs-a-*", RoleARN: "arn:aws:iam::111111111111:role/reader"},
+		{Bucket: "logs-*", RoleARN: "arn:aws:iam::222222222222:role/reader"},
+	}
+	regions := map[string]string{
+		"logs-a-east": "us-east-1",
+		"logs-a-west": "us-west-2",
+		"logs-b":      "us-west-2",
+		"local":       "us-west-2",
+	}
+
+	sources, reads, created := newSourceClients(t, roles, regions)
+
+	for _, source := range []string{"logs-a-east/1", "logs-a-east/2", "logs-a-west/1", "logs-b/1", "local/1", "other-logs-a-east/1"} {
+		bucket, key, _ := bytes.Cut([]byte(source), []byte("/"))
+		if _, err := sources.GetObject(context.Background(), &s3.GetObjectInput{
+			Bucket: aws.String(string(bucket)),
+			Key:    aws.String(string(key)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect := map[string]sourceClientKey{
+		"logs-a-east/1":       {Role: "arn:aws:iam::111111111111:role/reader", Region: "us-east-1"},
+		"logs-a-east/2":       {Role: "arn:aws:iam::111111111111:role/reader", Region: "us-east-1"},
+		"logs-a-west/1":       {Role: "arn:aws:iam::111111111111:role/reader", Region: "us-west-2"},
+		"logs-b/1":            {Role: "arn:aws:iam::222222222222:role/reader", Region: "us-west-2"},
+		"local/1":             {Region: "us-west-2"},
+		"other-logs-a-east/1": {},
+	}
+	if diff := cmp.Diff(reads, expect); diff != "" {
+		t.Error("unexpected reads", diff)
+	}
+
+	// one client per role and region
+	if *created != 5 {
+		t.Errorf("expected 5 clients, got %d", *created)
+	}
+}
+
+func TestHandlerSourceRoles(t *testing.T) {
+	t.Parallel()
+
+	sources, reads, _ := newSourceClients(t, []*forwarder.SourceRole{
+		{Bucket: "remote", RoleARN: "arn:aws:iam::111111111111:role/reader"},
+	}, nil)
+
+	var (
+		mu     sync.Mutex
+		copied []string
+		puts   = make(map[string]string)
+	)
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination/prefix",
+		Sources:        sources,
+		S3Client: &awstest.S3Client{
+			CopyObjectFunc: func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				copied = append(copied, aws.ToString(input.CopySource))
+				return &s3.CopyObjectOutput{}, nil
+			},
+			PutObjectFunc: func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				data, err := io.ReadAll(input.Body)
+				if err != nil {
+					return nil, err
+				}
+				if n := aws.ToInt64(input.ContentLength); n != int64(len(data)) {
+					t.Errorf("expected content length %d, got %d", len(data), n)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				puts[aws.ToString(input.Key)] = aws.ToString(input.ContentType) + ":" + string(data)
+				return &s3.PutObjectOutput{}, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.ProcessRecord(context.Background(), &forwarder.SQSMessage{
+		SQSMessage: events.SQSMessage{
+			Body: `{"copy": [{"uri": "s3://remote/a.log"}, {"uri": "s3://local/b.log"}]}`,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// objects read through a role are read and written by the forwarder
+	if diff := cmp.Diff(puts, map[string]string{"prefix/a.log": "text/plain:data"}); diff != "" {
+		t.Error("unexpected puts", diff)
+	}
+	if diff := cmp.Diff(reads, map[string]sourceClientKey{"remote/a.log": {Role: "arn:aws:iam::111111111111:role/reader"}}); diff != "" {
+		t.Error("unexpected reads", diff)
+	}
+
+	// all other objects are copied server side
+	if diff := cmp.Diff(copied, []string{"local/b.log"}); diff != "" {
+		t.Error("unexpected copies", diff)
+	}
+}
+
+func TestHandlerSourceRolesTooLarge(t *testing.T) {
+	t.Parallel()
+
+	sources, err := forwarder.NewSourceClients([]*forwarder.SourceRole{
+		{Bucket: "remote", RoleARN: "arn:aws:iam::111111111111:role/reader"},
+	}, func(_ *forwarder.SourceRole, _ string) forwarder.SourceClient {
+		return &awstest.S3Client{
+			GetObjectFunc: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewBufferString("data")),
+					ContentLength: aws.Int64(6 * 1024 * 1024 * 1024),
+				}, nil
+			},
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources.LookupRegion = func(_ context.Context, _ string) (string, error) {
+		return "", nil
+	}
+
+	h, err := forwarder.New(&forwarder.Config{
+		DestinationURI: "s3://destination/prefix",
+		Sources:        sources,
+		S3Client: &awstest.S3Client{
+			PutObjectFunc: func(_ context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				t.Error("objects above the single upload limit must not be put")
+				return &s3.PutObjectOutput{}, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.ProcessRecord(context.Background(), &forwarder.SQSMessage{
+		SQSMessage: events.SQSMessage{
+			Body: `{"copy": [{"uri": "s3://remote/a.log"}]}`,
+		},
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -4,17 +4,25 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/go-logr/logr"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/observeinc/aws-sam-apps/pkg/handler"
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
-	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/dedup"
 	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/s3http/checkpoint"
 	forwardertracing "github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/tracing"
 	"github.com/observeinc/aws-sam-apps/pkg/logging"
 	"github.com/observeinc/aws-sam-apps/pkg/tracing"
@@ -30,16 +38,27 @@ type S3Client interface {
 	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	CopyObject(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
 	s3.HeadBucketAPIClient
+	forwarder.ObjectAttributesAPIClient
 }
 
 type Config struct {
-	DestinationURI       string           `env:"DESTINATION_URI,required"`
-	MaxFileSize          int64            `env:"MAX_FILE_SIZE"`
-	ContentTypeOverrides []*override.Rule `env:"CONTENT_TYPE_OVERRIDES"`
-	PresetOverrides      []string         `env:"PRESET_OVERRIDES,default=aws/v1,infer/v1"`
-	SourceBucketNames    []string         `env:"SOURCE_BUCKET_NAMES"`
-	SourceObjectKeys     []string         `env:"SOURCE_OBJECT_KEYS"`
-	MaxConcurrentTasks   int              `env:"MAX_CONCURRENT_TASKS"`
+	DestinationURI        string        `env:"DESTINATION_URI,required"`
+	MaxFileSize           int64         `env:"MAX_FILE_SIZE"`
+	OversizedMode         string        `env:"OVERSIZED_MODE,default=skip"`
+	SourceBucketNames     []string      `env:"SOURCE_BUCKET_NAMES"`
+	SourceObjectKeys      []string      `env:"SOURCE_OBJECT_KEYS"`
+	SourceRoleARNs        []string      `env:"SOURCE_ROLE_ARNS"`
+	SourceRoleBucketNames []string      `env:"SOURCE_ROLE_BUCKET_NAMES"`
+	SourceRoleExternalIDs []string      `env:"SOURCE_ROLE_EXTERNAL_IDS"`
+	MaxConcurrentTasks    int           `env:"MAX_CONCURRENT_TASKS"`
+	QueueURL              string        `env:"QUEUE_URL"`
+	MaxReceiveCount       int           `env:"MAX_RECEIVE_COUNT"`
+	DedupTableName        string        `env:"DEDUP_TABLE_NAME"`
+	DedupTTL              time.Duration `env:"DEDUP_TTL,default=24h"`
+
+	Destinations []*forwarder.DestinationConfig `env:"DESTINATION_URIS"`
+
+	OverrideConfig
 
 	Logging *logging.Config
 
@@ -47,7 +66,11 @@ type Config struct {
 	OTELTracesExporter       string `env:"OTEL_TRACES_EXPORTER,default=none"`
 	OTELExporterOTLPEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
 
-	S3HTTPGzipLevel *int `env:"S3_HTTP_GZIP_LEVEL,default=1"`
+	S3HTTPGzipLevel       *int          `env:"S3_HTTP_GZIP_LEVEL,default=1"`
+	S3HTTPCheckpointURI   string        `env:"S3_HTTP_CHECKPOINT_URI"`
+	S3HTTPMaxBatchRecords int           `env:"S3_HTTP_MAX_BATCH_RECORDS"`
+	S3HTTPMaxBatchLatency time.Duration `env:"S3_HTTP_MAX_BATCH_LATENCY"`
+	S3HTTPCoalesce        bool          `env:"S3_HTTP_COALESCE"`
 
 	// The following variables are not configurable via environment
 	HTTPInsecureSkipVerify bool     `json:"-"`
@@ -105,31 +128,71 @@ func New(ctx context.Context, cfg *Config) (*Lambda, error) {
 		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
 	}
 
-	customOverrides := &override.Set{
-		Logger: logger.WithValues("set", "custom"),
-		Rules:  cfg.ContentTypeOverrides,
+	var awsS3Client = cfg.AWSS3Client
+	if awsS3Client == nil {
+		awsS3Client = s3.NewFromConfig(awsCfg)
 	}
-	if err := customOverrides.Validate(); err != nil {
-		return nil, fmt.Errorf("failed to validate override set: %w", err)
+
+	remoteSets, err := cfg.RemoteSets(ctx, logger, cfg.Destinations, awsS3Client, ssm.NewFromConfig(awsCfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rule sets: %w", err)
 	}
 
-	presets, err := override.LoadPresets(logger, cfg.PresetOverrides...)
+	overrides, err := cfg.OverrideSets(logger, cfg.Destinations, remoteSets...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load presets: %w", err)
+		return nil, err
 	}
 
-	var awsS3Client = cfg.AWSS3Client
-	if awsS3Client == nil {
-		awsS3Client = s3.NewFromConfig(awsCfg)
+	sourceRoles, err := forwarder.NewSourceRoles(cfg.SourceRoleARNs, cfg.SourceRoleBucketNames, cfg.SourceRoleExternalIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source roles: %w", err)
+	}
+
+	stsClient := sts.NewFromConfig(awsCfg)
+	sources, err := forwarder.NewSourceClients(sourceRoles, func(role *forwarder.SourceRole, region string) forwarder.SourceClient {
+		if role == nil && (region == "" || region == awsCfg.Region) {
+			return awsS3Client
+		}
+		sourceCfg := awsCfg.Copy()
+		if region != "" {
+			sourceCfg.Region = region
+		}
+		if role != nil {
+			logger.V(4).Info("loading source client", "role", role.RoleARN, "region", region)
+			sourceCfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, role.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+				if role.ExternalID != "" {
+					o.ExternalID = aws.String(role.ExternalID)
+				}
+			}))
+		}
+		return s3.NewFromConfig(sourceCfg)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source clients: %w", err)
 	}
 
-	var s3Client forwarder.S3Client = awsS3Client
-	if strings.HasPrefix(cfg.DestinationURI, "https") {
-		logger.V(4).Info("loading http client")
-		s3Client, err = s3http.New(&s3http.Config{
-			DestinationURI:     cfg.DestinationURI,
-			GetObjectAPIClient: awsS3Client,
+	var checkpointStore checkpoint.Store
+	if cfg.S3HTTPCheckpointURI != "" {
+		checkpointStore, err = checkpoint.New(cfg.S3HTTPCheckpointURI, s3.NewFromConfig(awsCfg), dynamodb.NewFromConfig(awsCfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load checkpoint store: %w", err)
+		}
+	}
+
+	newS3Client := func(destinationURI string) (forwarder.S3Client, error) {
+		if !strings.HasPrefix(destinationURI, "https") {
+			return awsS3Client, nil
+		}
+		logger.V(4).Info("loading http client", "destination", destinationURI)
+		client, err := s3http.New(&s3http.Config{
+			DestinationURI:     destinationURI,
+			GetObjectAPIClient: sources,
 			GzipLevel:          cfg.S3HTTPGzipLevel,
+			CheckpointStore:    checkpointStore,
+			MaxBatchRecords:    cfg.S3HTTPMaxBatchRecords,
+			MaxBatchLatency:    cfg.S3HTTPMaxBatchLatency,
+			Coalesce:           cfg.S3HTTPCoalesce,
+			TracerProvider:     tracerProvider,
 			HTTPClient: tracing.NewHTTPClient(&tracing.HTTPClientConfig{
 				TracerProvider:     tracerProvider,
 				Logger:             &logger,
@@ -139,16 +202,58 @@ func New(ctx context.Context, cfg *Config) (*Lambda, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to load http client: %w", err)
 		}
+		return client, nil
+	}
+
+	s3Client, err := newS3Client(cfg.DestinationURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load destination client: %w", err)
+	}
+
+	destinations := make([]*forwarder.DestinationConfig, 0, len(cfg.Destinations))
+	for _, d := range cfg.Destinations {
+		destinationClient, err := newS3Client(d.URI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load destination client: %w", err)
+		}
+		destinations = append(destinations, &forwarder.DestinationConfig{
+			Name:     d.Name,
+			URI:      d.URI,
+			S3Client: destinationClient,
+		})
+	}
+
+	var queue forwarder.Queue
+	if cfg.QueueURL != "" {
+		queue, err = forwarder.NewQueue(sqs.NewFromConfig(awsCfg), cfg.QueueURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load queue: %w", err)
+		}
+	}
+
+	var dedupStore dedup.Store
+	if cfg.DedupTableName != "" {
+		dedupStore, err = dedup.NewDynamoDBStore(dynamodb.NewFromConfig(awsCfg), cfg.DedupTableName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load deduplication store: %w", err)
+		}
 	}
 
 	f, err := forwarder.New(&forwarder.Config{
 		DestinationURI:     cfg.DestinationURI,
 		MaxFileSize:        cfg.MaxFileSize,
+		OversizedMode:      forwarder.OversizedMode(cfg.OversizedMode),
 		S3Client:           s3Client,
-		Override:           append(override.Sets{customOverrides}, presets...),
+		Destinations:       destinations,
+		Queue:              queue,
+		DedupStore:         dedupStore,
+		DedupTTL:           cfg.DedupTTL,
+		Override:           overrides,
 		SourceBucketNames:  cfg.SourceBucketNames,
 		SourceObjectKeys:   cfg.SourceObjectKeys,
+		Sources:            sources,
 		MaxConcurrentTasks: cfg.MaxConcurrentTasks,
+		MaxReceiveCount:    cfg.MaxReceiveCount,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create handler: %w", err)
@@ -166,14 +271,40 @@ func New(ctx context.Context, cfg *Config) (*Lambda, error) {
 		f.S3Client = s3.NewFromConfig(regionCfg)
 	}
 
+	for _, d := range f.Destinations {
+		region, err := forwarder.GetDestinationRegion(ctx, awsS3Client, d.URI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get destination region: %w", err)
+		}
+
+		if region != "" && awsCfg.Region != region {
+			logger.V(4).Info("modifying s3 client region", "destination", d.URI.String(), "region", region)
+			regionCfg := awsCfg.Copy()
+			regionCfg.Region = region
+			d.S3Client = s3.NewFromConfig(regionCfg)
+		}
+	}
+
 	mux := &handler.Mux{
 		Logger: logger,
 	}
 
-	if err := mux.Register(f.Handle); err != nil {
+	if err := mux.Register(f.Handle, f.HandleEventBridge, f.HandleS3Batch); err != nil {
 		return nil, fmt.Errorf("failed to register functions: %w", err)
 	}
 
+	// rule sets are refreshed for the lifetime of the process, which
+	// only makes progress while the execution environment is thawed.
+	refreshCtx, cancelRefresh := context.WithCancel(logr.NewContext(context.Background(), logger))
+	for _, r := range remoteSets {
+		go r.Run(refreshCtx)
+	}
+	shutdown := l.Shutdown
+	l.Shutdown = func() {
+		cancelRefresh()
+		shutdown()
+	}
+
 	l.Entrypoint = tracing.NewLambdaHandler(mux, tracerProvider)
 	return l, nil
 }
@@ -0,0 +1,101 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder/override"
+)
+
+// OverrideConfig contains the override rules applied by the forwarder.
+type OverrideConfig struct {
+	ContentTypeOverrides []*override.Rule            `env:"CONTENT_TYPE_OVERRIDES"`
+	PresetOverrides      []string                    `env:"PRESET_OVERRIDES,default=aws/v1,infer/v1"`
+	DestinationOverrides []*override.DestinationRule `env:"DESTINATION_OVERRIDES"`
+	KeyOverrides         []*override.KeyRule         `env:"KEY_OVERRIDES"`
+	RuleSetURIs          []string                    `env:"RULE_SET_URIS"`
+	RuleSetTTL           time.Duration               `env:"RULE_SET_TTL,default=5m"`
+}
+
+// RemoteSets loads the rule sets referenced by RuleSetURIs. Every rule set
+// must load successfully, and may only route objects to one of destinations,
+// both initially and on every refresh.
+func (c *OverrideConfig) RemoteSets(ctx context.Context, logger logr.Logger, destinations []*forwarder.DestinationConfig, s3Client override.GetObjectAPIClient, ssmClient override.GetParameterAPIClient) ([]*override.RemoteSet, error) {
+	destinationNames := make([]string, 0, len(destinations))
+	for _, d := range destinations {
+		if d.Name != "" {
+			destinationNames = append(destinationNames, d.Name)
+		}
+	}
+
+	remoteSets := make([]*override.RemoteSet, 0, len(c.RuleSetURIs))
+	for _, uri := range c.RuleSetURIs {
+		load, err := override.NewLoader(uri, s3Client, ssmClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure rule set: %w", err)
+		}
+		remoteSet := &override.RemoteSet{
+			Set: &override.Set{
+				Name:   uri,
+				Logger: logger.WithValues("set", uri),
+			},
+			Load:         load,
+			TTL:          c.RuleSetTTL,
+			Destinations: destinationNames,
+		}
+		if err := remoteSet.Refresh(ctx); err != nil {
+			return nil, err
+		}
+		remoteSets = append(remoteSets, remoteSet)
+	}
+	return remoteSets, nil
+}
+
+// OverrideSets returns the custom rules followed by remote rule sets and
+// presets. Destination overrides must refer to one of destinations.
+func (c *OverrideConfig) OverrideSets(logger logr.Logger, destinations []*forwarder.DestinationConfig, remoteSets ...*override.RemoteSet) (override.Sets, error) {
+	destinationNames := make(map[string]struct{}, len(destinations))
+	for _, d := range destinations {
+		destinationNames[d.Name] = struct{}{}
+	}
+
+	// Destination and key rules precede content type overrides. They do not
+	// terminate rule evaluation, so content types are still resolved for
+	// routed objects.
+	rules := make([]*override.Rule, 0, len(c.DestinationOverrides)+len(c.KeyOverrides)+len(c.ContentTypeOverrides))
+	for _, r := range c.DestinationOverrides {
+		if d := r.Override.Destination; d != nil {
+			if _, ok := destinationNames[*d]; !ok {
+				return nil, fmt.Errorf("destination override refers to unknown destination %q", *d)
+			}
+		}
+		rules = append(rules, &r.Rule)
+	}
+	for _, r := range c.KeyOverrides {
+		rules = append(rules, &r.Rule)
+	}
+	rules = append(rules, c.ContentTypeOverrides...)
+
+	customOverrides := &override.Set{
+		Name:   "custom",
+		Logger: logger.WithValues("set", "custom"),
+		Rules:  rules,
+	}
+	if err := customOverrides.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate override set: %w", err)
+	}
+
+	presets, err := override.LoadPresets(logger, c.PresetOverrides...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load presets: %w", err)
+	}
+	sets := override.Sets{customOverrides}
+	for _, r := range remoteSets {
+		sets = append(sets, r.Set)
+	}
+	return append(sets, presets...), nil
+}
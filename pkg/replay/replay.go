@@ -0,0 +1,178 @@
+// Package replay resubmits failed copy records to the forwarder.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+)
+
+// defaultBatchSize keeps messages well below the SQS message size limit.
+const defaultBatchSize = 100
+
+var (
+	ErrMissingQueue     = errors.New("missing queue")
+	ErrInvalidBatchSize = errors.New("batch size must be positive")
+	ErrInvalidRate      = errors.New("rate must not be negative")
+	ErrInvalidTimeRange = errors.New("invalid time range")
+)
+
+// Source enumerates failed copy records. Records are provided in groups,
+// e.g. per archive file or per dead letter queue message. A group is only
+// acknowledged if fn returns true, having resubmitted every record.
+type Source interface {
+	Walk(ctx context.Context, fn func([]*forwarder.FailedCopyRecord) (bool, error)) error
+}
+
+// Filter selects which failed copy records to replay.
+type Filter struct {
+	// ErrorClasses restricts records to the provided error classes.
+	ErrorClasses []string
+	// Since and Until restrict records to a time range. Zero values
+	// indicate no bound.
+	Since time.Time
+	Until time.Time
+}
+
+func (f *Filter) Match(record *forwarder.FailedCopyRecord) bool {
+	if len(f.ErrorClasses) > 0 && !slices.Contains(f.ErrorClasses, record.ErrorClass) {
+		return false
+	}
+	if !f.Since.IsZero() && record.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !record.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+type Config struct {
+	Filter
+	BatchSize int
+	// Rate limits the number of messages sent per second.
+	// A zero value indicates no limit.
+	Rate float64
+
+	Queue  forwarder.Queue
+	DryRun bool
+	// Output receives the list of records in dry-run mode.
+	Output io.Writer
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Queue == nil && !c.DryRun {
+		errs = append(errs, ErrMissingQueue)
+	}
+
+	if c.BatchSize < 0 {
+		errs = append(errs, ErrInvalidBatchSize)
+	}
+
+	if c.Rate < 0 {
+		errs = append(errs, ErrInvalidRate)
+	}
+
+	if !c.Since.IsZero() && !c.Until.IsZero() && !c.Since.Before(c.Until) {
+		errs = append(errs, ErrInvalidTimeRange)
+	}
+	return errors.Join(errs...)
+}
+
+// Summary of a replay.
+type Summary struct {
+	Records  int64
+	Skipped  int64
+	Messages int64
+}
+
+type Replay struct {
+	Filter    Filter
+	BatchSize int
+	Limiter   *rate.Limiter
+	Queue     forwarder.Queue
+	DryRun    bool
+	Output    io.Writer
+}
+
+// Run resubmits all matching records from source.
+func (r *Replay) Run(ctx context.Context, source Source) (*Summary, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	var summary Summary
+	err := source.Walk(ctx, func(records []*forwarder.FailedCopyRecord) (bool, error) {
+		var batch []forwarder.CopyRecord
+		for _, record := range records {
+			if !r.Filter.Match(record) {
+				summary.Skipped++
+				continue
+			}
+			summary.Records++
+
+			if r.DryRun {
+				if _, err := fmt.Fprintf(r.Output, "%s\t%s\t%s\n", record.URI, record.ErrorClass, record.ErrorMessage); err != nil {
+					return false, fmt.Errorf("failed to write record: %w", err)
+				}
+				continue
+			}
+			batch = append(batch, record.CopyRecord)
+		}
+
+		for chunk := range slices.Chunk(batch, r.BatchSize) {
+			if err := r.Limiter.Wait(ctx); err != nil {
+				return false, fmt.Errorf("failed to wait for rate limiter: %w", err)
+			}
+			if err := r.Queue.Put(ctx, &forwarder.CopyEvent{Copy: chunk}); err != nil {
+				return false, fmt.Errorf("failed to enqueue records: %w", err)
+			}
+			logger.V(3).Info("resubmitted records", "count", len(chunk))
+			summary.Messages++
+		}
+
+		// only acknowledge groups for which every record was resubmitted
+		return !r.DryRun && len(records) > 0 && len(batch) == len(records), nil
+	})
+	if err != nil {
+		return &summary, fmt.Errorf("failed to read records: %w", err)
+	}
+	return &summary, nil
+}
+
+func New(cfg *Config) (*Replay, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	limit := rate.Inf
+	if cfg.Rate > 0 {
+		limit = rate.Limit(cfg.Rate)
+	}
+
+	output := cfg.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	return &Replay{
+		Filter:    cfg.Filter,
+		BatchSize: batchSize,
+		Limiter:   rate.NewLimiter(limit, 1),
+		Queue:     cfg.Queue,
+		DryRun:    cfg.DryRun,
+		Output:    output,
+	}, nil
+}
@@ -0,0 +1,299 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+	"github.com/observeinc/aws-sam-apps/pkg/replay"
+	"github.com/observeinc/aws-sam-apps/pkg/testing/awstest"
+)
+
+type fakeQueue struct {
+	Items []any
+}
+
+func (q *fakeQueue) Put(_ context.Context, items ...any) error {
+	q.Items = append(q.Items, items...)
+	return nil
+}
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	testcases := []struct {
+		replay.Config
+		ExpectError error
+	}{
+		{
+			ExpectError: replay.ErrMissingQueue,
+		},
+		{
+			Config: replay.Config{
+				DryRun: true,
+			},
+		},
+		{
+			Config: replay.Config{
+				Queue:     &fakeQueue{},
+				BatchSize: -1,
+			},
+			ExpectError: replay.ErrInvalidBatchSize,
+		},
+		{
+			Config: replay.Config{
+				Queue: &fakeQueue{},
+				Filter: replay.Filter{
+					Since: now,
+					Until: now,
+				},
+			},
+			ExpectError: replay.ErrInvalidTimeRange,
+		},
+	}
+
+	for _, tc := range testcases {
+		err := tc.Validate()
+		if diff := cmp.Diff(err, tc.ExpectError, cmpopts.EquateErrors()); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := &forwarder.FailedCopyRecord{
+		ErrorClass: "AccessDenied",
+		Timestamp:  now,
+	}
+
+	testcases := []struct {
+		replay.Filter
+		Expect bool
+	}{
+		{
+			Expect: true,
+		},
+		{
+			Filter: replay.Filter{ErrorClasses: []string{"NoSuchKey", "AccessDenied"}},
+			Expect: true,
+		},
+		{
+			Filter: replay.Filter{ErrorClasses: []string{"NoSuchKey"}},
+			Expect: false,
+		},
+		{
+			Filter: replay.Filter{Since: now, Until: now.Add(time.Hour)},
+			Expect: true,
+		},
+		{
+			Filter: replay.Filter{Since: now.Add(time.Second)},
+			Expect: false,
+		},
+		{
+			Filter: replay.Filter{Until: now},
+			Expect: false,
+		},
+	}
+
+	for i, tc := range testcases {
+		if got := tc.Match(record); got != tc.Expect {
+			t.Errorf("%d: expected %t, got %t", i, tc.Expect, got)
+		}
+	}
+}
+
+func TestArchiveSource(t *testing.T) {
+	t.Parallel()
+
+	archive := `{"uri":"s3://source/a.json","destinations":["s3://destination"],"messageId":"1","error":"denied","errorClass":"AccessDenied","attempts":4,"timestamp":"2024-01-01T00:00:00Z"}
+{"uri":"s3://source/b.json","messageId":"1","error":"timeout","errorClass":"Timeout","attempts":4,"timestamp":"2024-01-01T00:00:00Z"}
+`
+
+	source := &replay.ArchiveSource{
+		Client: &awstest.S3Client{
+			ListObjectsV2Func: func(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				if prefix := aws.ToString(input.Prefix); prefix != "prefix/errors/" {
+					t.Errorf("unexpected prefix %q", prefix)
+				}
+				return &s3.ListObjectsV2Output{
+					Contents: []s3types.Object{
+						{Key: aws.String("prefix/errors/a.ndjson")},
+						{Key: aws.String("prefix/errors/ignored.txt")},
+					},
+				}, nil
+			},
+			GetObjectFunc: func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if key := aws.ToString(input.Key); key != "prefix/errors/a.ndjson" {
+					t.Errorf("unexpected key %q", key)
+				}
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewBufferString(archive))}, nil
+			},
+		},
+		Bucket: "destination",
+		Prefix: "prefix/errors/",
+	}
+
+	queue := &fakeQueue{}
+	r, err := replay.New(&replay.Config{
+		Filter: replay.Filter{ErrorClasses: []string{"AccessDenied"}},
+		Queue:  queue,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := r.Run(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(summary, &replay.Summary{Records: 1, Skipped: 1, Messages: 1}); diff != "" {
+		t.Error("unexpected summary", diff)
+	}
+
+	expect := []any{
+		&forwarder.CopyEvent{
+			Copy: []forwarder.CopyRecord{
+				{URI: "s3://source/a.json", Destinations: []string{"s3://destination"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(queue.Items, expect); diff != "" {
+		t.Error("unexpected messages", diff)
+	}
+}
+
+func TestDeadLetterSource(t *testing.T) {
+	t.Parallel()
+
+	messages := []types.Message{
+		{
+			MessageId:     aws.String("1"),
+			ReceiptHandle: aws.String("handle-1"),
+			Body:          aws.String(`{"copy": [{"uri": "s3://source/a.json"}, {"uri": "s3://source/b.json"}]}`),
+			Attributes: map[string]string{
+				"ApproximateReceiveCount": "4",
+				"SentTimestamp":           "1704067200000",
+			},
+		},
+		{
+			// outside of time range
+			MessageId:     aws.String("2"),
+			ReceiptHandle: aws.String("handle-2"),
+			Body:          aws.String(`{"copy": [{"uri": "s3://source/c.json"}]}`),
+			Attributes: map[string]string{
+				"SentTimestamp": "1600000000000",
+			},
+		},
+		{
+			// unrecognized messages are left in place
+			MessageId:     aws.String("3"),
+			ReceiptHandle: aws.String("handle-3"),
+			Body:          aws.String(`{}`),
+		},
+	}
+
+	var deleted []string
+	received := false
+	source := &replay.DeadLetterSource{
+		Client: &awstest.SQSClient{
+			ReceiveMessageFunc: func(_ context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+				if received {
+					return &sqs.ReceiveMessageOutput{}, nil
+				}
+				received = true
+				return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+			},
+			DeleteMessageFunc: func(_ context.Context, input *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+				deleted = append(deleted, aws.ToString(input.ReceiptHandle))
+				return &sqs.DeleteMessageOutput{}, nil
+			},
+		},
+		URL: "https://sqs.us-east-1.amazonaws.com/123456789012/deadletter",
+	}
+
+	queue := &fakeQueue{}
+	r, err := replay.New(&replay.Config{
+		Filter: replay.Filter{Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Queue:  queue,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Run(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(deleted, []string{"handle-1"}); diff != "" {
+		t.Error("unexpected deletes", diff)
+	}
+
+	expect := []any{
+		&forwarder.CopyEvent{
+			Copy: []forwarder.CopyRecord{
+				{URI: "s3://source/a.json"},
+				{URI: "s3://source/b.json"},
+			},
+		},
+	}
+	if diff := cmp.Diff(queue.Items, expect); diff != "" {
+		t.Error("unexpected messages", diff)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	r, err := replay.New(&replay.Config{
+		DryRun: true,
+		Output: &output,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := &replay.DeadLetterSource{
+		Client: &awstest.SQSClient{
+			ReceiveMessageFunc: func(_ context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+				if output.Len() > 0 {
+					return &sqs.ReceiveMessageOutput{}, nil
+				}
+				return &sqs.ReceiveMessageOutput{
+					Messages: []types.Message{
+						{Body: aws.String(`{"copy": [{"uri": "s3://source/a.json"}]}`)},
+					},
+				}, nil
+			},
+			DeleteMessageFunc: func(_ context.Context, _ *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+				return nil, errors.New("dry run must not delete messages")
+			},
+		},
+	}
+
+	if _, err := r.Run(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(output.String(), "s3://source/a.json\t\t\n"); diff != "" {
+		t.Error(diff)
+	}
+}
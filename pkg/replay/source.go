@@ -0,0 +1,157 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/observeinc/aws-sam-apps/pkg/handler/forwarder"
+)
+
+// dlqVisibilityTimeout hides received messages for the remainder of a
+// replay, so that unacknowledged messages are not received twice.
+const dlqVisibilityTimeout = 15 * time.Minute
+
+type S3Client interface {
+	s3.ListObjectsV2APIClient
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// ArchiveSource reads failed copy records archived by the forwarder.
+type ArchiveSource struct {
+	Client S3Client
+	Bucket string
+	Prefix string
+}
+
+var _ Source = &ArchiveSource{}
+
+func (a *ArchiveSource) Walk(ctx context.Context, fn func([]*forwarder.FailedCopyRecord) (bool, error)) error {
+	paginator := s3.NewListObjectsV2Paginator(a.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(a.Bucket),
+		Prefix: aws.String(a.Prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get page: %w", err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if !strings.HasSuffix(key, ".ndjson") {
+				continue
+			}
+			records, err := a.read(ctx, key)
+			if err != nil {
+				return err
+			}
+			if _, err := fn(records); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *ArchiveSource) read(ctx context.Context, key string) ([]*forwarder.FailedCopyRecord, error) {
+	output, err := a.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	var records []*forwarder.FailedCopyRecord
+	dec := json.NewDecoder(output.Body)
+	for dec.More() {
+		var record forwarder.FailedCopyRecord
+		if err := dec.Decode(&record); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", key, err)
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+type SQSClient interface {
+	ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// DeadLetterSource drains messages from the forwarder dead letter queue.
+// Messages are deleted once all of their records have been resubmitted.
+//
+// Dead letter queue messages do not record why processing failed, so
+// records have no error message or class.
+type DeadLetterSource struct {
+	Client SQSClient
+	URL    string
+}
+
+var _ Source = &DeadLetterSource{}
+
+func (d *DeadLetterSource) Walk(ctx context.Context, fn func([]*forwarder.FailedCopyRecord) (bool, error)) error {
+	for {
+		output, err := d.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(d.URL),
+			MaxNumberOfMessages:         10,
+			WaitTimeSeconds:             1,
+			VisibilityTimeout:           int32(dlqVisibilityTimeout.Seconds()),
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAll},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to receive messages: %w", err)
+		}
+
+		if len(output.Messages) == 0 {
+			return nil
+		}
+
+		for _, message := range output.Messages {
+			ack, err := fn(recordsFromMessage(&message))
+			if err != nil {
+				return err
+			}
+			if !ack {
+				continue
+			}
+			if _, err := d.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(d.URL),
+				ReceiptHandle: message.ReceiptHandle,
+			}); err != nil {
+				return fmt.Errorf("failed to delete message: %w", err)
+			}
+		}
+	}
+}
+
+func recordsFromMessage(message *types.Message) (records []*forwarder.FailedCopyRecord) {
+	attempts, _ := strconv.Atoi(message.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+
+	var timestamp time.Time
+	if ms, err := strconv.ParseInt(message.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+		timestamp = time.UnixMilli(ms).UTC()
+	}
+
+	for _, copyRecord := range forwarder.GetObjectCreated(&events.SQSMessage{Body: aws.ToString(message.Body)}) {
+		records = append(records, &forwarder.FailedCopyRecord{
+			CopyRecord: copyRecord,
+			MessageID:  aws.ToString(message.MessageId),
+			Attempts:   attempts,
+			Timestamp:  timestamp,
+		})
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package awstest
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+type DynamoDBClient struct {
+	GetItemFunc func(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItemFunc func(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+
+	DeleteItemFunc func(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+func (c *DynamoDBClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if c.GetItemFunc == nil {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return c.GetItemFunc(ctx, params, optFns...)
+}
+
+func (c *DynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if c.PutItemFunc == nil {
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	return c.PutItemFunc(ctx, params, optFns...)
+}
+
+func (c *DynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if c.DeleteItemFunc == nil {
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+	return c.DeleteItemFunc(ctx, params, optFns...)
+}
@@ -7,9 +7,34 @@ import (
 )
 
 type SQSClient struct {
-	SendMessageFunc func(context.Context, *sqs.SendMessageInput, ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	SendMessageFunc    func(context.Context, *sqs.SendMessageInput, ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	ReceiveMessageFunc func(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageFunc  func(context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+
+	ChangeMessageVisibilityFunc func(context.Context, *sqs.ChangeMessageVisibilityInput, ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
 }
 
 func (c *SQSClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
 	return c.SendMessageFunc(ctx, params, optFns...)
 }
+
+func (c *SQSClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if c.ReceiveMessageFunc == nil {
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	return c.ReceiveMessageFunc(ctx, params, optFns...)
+}
+
+func (c *SQSClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	if c.DeleteMessageFunc == nil {
+		return &sqs.DeleteMessageOutput{}, nil
+	}
+	return c.DeleteMessageFunc(ctx, params, optFns...)
+}
+
+func (c *SQSClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	if c.ChangeMessageVisibilityFunc == nil {
+		return &sqs.ChangeMessageVisibilityOutput{}, nil
+	}
+	return c.ChangeMessageVisibilityFunc(ctx, params, optFns...)
+}
@@ -14,6 +14,18 @@ type S3Client struct {
 	CopyObjectFunc func(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
 	PutObjectFunc  func(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	HeadBucketFunc func(context.Context, *s3.HeadBucketInput, ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+
+	DeleteObjectFunc func(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+
+	ListObjectsV2Func func(context.Context, *s3.ListObjectsV2Input, ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+
+	HeadObjectFunc              func(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	CreateMultipartUploadFunc   func(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPartCopyFunc          func(context.Context, *s3.UploadPartCopyInput, ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+	CompleteMultipartUploadFunc func(context.Context, *s3.CompleteMultipartUploadInput, ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUploadFunc    func(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+
+	GetObjectTaggingFunc func(context.Context, *s3.GetObjectTaggingInput, ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
 }
 
 func (c *S3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
@@ -44,6 +56,62 @@ func (c *S3Client) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, o
 	return c.HeadBucketFunc(ctx, params, optFns...)
 }
 
+func (c *S3Client) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	if c.DeleteObjectFunc == nil {
+		return &s3.DeleteObjectOutput{}, nil
+	}
+	return c.DeleteObjectFunc(ctx, params, optFns...)
+}
+
+func (c *S3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if c.ListObjectsV2Func == nil {
+		return &s3.ListObjectsV2Output{}, nil
+	}
+	return c.ListObjectsV2Func(ctx, params, optFns...)
+}
+
+func (c *S3Client) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if c.HeadObjectFunc == nil {
+		return &s3.HeadObjectOutput{}, nil
+	}
+	return c.HeadObjectFunc(ctx, params, optFns...)
+}
+
+func (c *S3Client) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	if c.GetObjectTaggingFunc == nil {
+		return &s3.GetObjectTaggingOutput{}, nil
+	}
+	return c.GetObjectTaggingFunc(ctx, params, optFns...)
+}
+
+func (c *S3Client) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if c.CreateMultipartUploadFunc == nil {
+		return &s3.CreateMultipartUploadOutput{}, nil
+	}
+	return c.CreateMultipartUploadFunc(ctx, params, optFns...)
+}
+
+func (c *S3Client) UploadPartCopy(ctx context.Context, params *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	if c.UploadPartCopyFunc == nil {
+		return &s3.UploadPartCopyOutput{}, nil
+	}
+	return c.UploadPartCopyFunc(ctx, params, optFns...)
+}
+
+func (c *S3Client) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if c.CompleteMultipartUploadFunc == nil {
+		return &s3.CompleteMultipartUploadOutput{}, nil
+	}
+	return c.CompleteMultipartUploadFunc(ctx, params, optFns...)
+}
+
+func (c *S3Client) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	if c.AbortMultipartUploadFunc == nil {
+		return &s3.AbortMultipartUploadOutput{}, nil
+	}
+	return c.AbortMultipartUploadFunc(ctx, params, optFns...)
+}
+
 // FileGetter is a fake S3 client that grabs files from disk
 type FileGetter struct {
 	S3Client
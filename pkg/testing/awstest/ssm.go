@@ -0,0 +1,18 @@
+package awstest
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+type SSMClient struct {
+	GetParameterFunc func(context.Context, *ssm.GetParameterInput, ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+func (c *SSMClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	if c.GetParameterFunc == nil {
+		return &ssm.GetParameterOutput{}, nil
+	}
+	return c.GetParameterFunc(ctx, params, optFns...)
+}
@@ -79,6 +79,9 @@ func NewHTTPClient(cfg *HTTPClientConfig) *http.Client {
 	}
 
 	client.Logger = &leveledLogger{logger}
+	// return the final response once retries are exhausted, so that callers
+	// can inspect the status code and Retry-After header
+	client.ErrorHandler = retryablehttp.PassthroughErrorHandler
 
 	var transport http.RoundTripper = &retryablehttp.RoundTripper{Client: client}
 	if serviceName := os.Getenv("OTEL_SERVICE_NAME"); serviceName != "" {
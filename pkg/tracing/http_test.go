@@ -0,0 +1,47 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/observeinc/aws-sam-apps/pkg/tracing"
+)
+
+func TestHTTPClientPassthrough(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer s.Close()
+
+	var (
+		wait     = time.Millisecond
+		retryMax = 2
+	)
+
+	client := tracing.NewHTTPClient(&tracing.HTTPClientConfig{
+		RetryWaitMin: &wait,
+		RetryWaitMax: &wait,
+		RetryMax:     &retryMax,
+	})
+
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		t.Fatal("expected final response, got", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+}